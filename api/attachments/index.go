@@ -0,0 +1,349 @@
+package attachments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/media"
+	"go-common/storage"
+	"go-common/store"
+)
+
+const (
+	// FileField is the multipart field holding the uploaded file.
+	FileField = "file"
+	// AltTextField is the optional multipart field holding its alt text.
+	AltTextField = "alt_text"
+	// MaxAltTextLength is the longest alt text, in characters. The database
+	// enforces it too (media_attachments_alt_text_length).
+	MaxAltTextLength = 1000
+	// MaxRequestBytes is the largest request body Vercel passes to a
+	// function. The multipart framing and alt text fit in what is left over
+	// above media.MaxVideoBytes.
+	MaxRequestBytes = 4608 << 10
+	// UnattachedTTL is how long an upload may wait to be attached to a post
+	// before it is purged.
+	UnattachedTTL = 24 * time.Hour
+)
+
+// UpdateAttachmentRequest changes an attachment's alt text.
+type UpdateAttachmentRequest struct {
+	AltText string `json:"alt_text"`
+}
+
+// Handler is the entry point for the /api/attachments serverless function.
+// POST uploads an image or video as multipart/form-data and returns the
+// unattached attachment, whose ID is then passed to POST /api/posts in
+// attachment_ids. PUT ?id= sets an attachment's alt text; DELETE ?id= removes
+// an upload that has not been attached yet.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+	attachments := store.NewMediaRepo(db)
+
+	switch r.Method {
+	case http.MethodPost:
+		uploadAttachment(w, r, attachments)
+	case http.MethodPut:
+		updateAttachment(w, r, attachments)
+	case http.MethodDelete:
+		deleteAttachment(w, r, attachments)
+	default:
+		apierror.NotAllowed(w, r)
+	}
+}
+
+func uploadAttachment(w http.ResponseWriter, r *http.Request, attachments store.MediaRepo) {
+	principal, _ := auth.FromContext(r.Context())
+
+	data, altText, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	id := uuid.New()
+	prefix := "media/" + principal.UserID.String() + "/" + id.String()
+	attachment := &store.Attachment{ID: id, UserID: principal.UserID, AltText: altText}
+	var files []storedFile
+
+	switch http.DetectContentType(data) {
+	case media.VideoContentType:
+		video, err := media.ProbeVideo(data)
+		if err != nil {
+			apierror.Write(w, r, apierror.UnsupportedMedia, "Video must be an MP4 file with a video track")
+			return
+		}
+		attachment.Type, attachment.ContentType = store.AttachmentVideo, media.VideoContentType
+		attachment.Width, attachment.Height = video.Width, video.Height
+		files = []storedFile{{prefix + ".mp4", data, media.VideoContentType}}
+	default:
+		if len(data) > media.MaxImageBytes {
+			apierror.Write(w, r, apierror.PayloadTooLarge, fmt.Sprintf("Images must be at most %d MB", media.MaxImageBytes>>20))
+			return
+		}
+		img, err := media.ProcessImage(data)
+		if err != nil {
+			switch {
+			case errors.Is(err, media.ErrUnsupportedType):
+				apierror.Write(w, r, apierror.UnsupportedMedia, "Attachments must be JPEG, PNG or GIF images or MP4 videos")
+			case errors.Is(err, media.ErrTooLarge):
+				apierror.Write(w, r, apierror.PayloadTooLarge, "Image dimensions are too large")
+			default:
+				apierror.InternalError(w, r, err, "Failed to process image")
+			}
+			return
+		}
+		attachment.Type, attachment.ContentType = store.AttachmentImage, media.ImageContentType
+		attachment.Width, attachment.Height = img.Width, img.Height
+		files = []storedFile{
+			{prefix + ".jpg", img.Data, media.ImageContentType},
+			{prefix + "-thumb.jpg", img.Thumbnail, media.ImageContentType},
+		}
+	}
+
+	fileStore, err := storage.FromEnv()
+	if err != nil {
+		apierror.InternalError(w, r, err, "Media storage is not configured")
+		return
+	}
+	urls := make([]string, len(files))
+	for i, f := range files {
+		if urls[i], err = fileStore.Put(r.Context(), f.key, f.data, f.contentType); err != nil {
+			apierror.InternalError(w, r, err, "Failed to store attachment")
+			return
+		}
+	}
+	attachment.StorageKey, attachment.URL = files[0].key, urls[0]
+	if len(files) > 1 {
+		attachment.ThumbnailKey, attachment.ThumbnailURL = &files[1].key, &urls[1]
+	}
+
+	if err := attachments.Create(r.Context(), attachment); err != nil {
+		apierror.InternalError(w, r, err, "Failed to save attachment")
+		return
+	}
+
+	// Uploads abandoned before the post was written are never shown. Each
+	// upload deletes the caller's stale ones, so the work does not grow with
+	// the table; a failure only delays the cleanup.
+	if purged, err := attachments.PurgeUnattached(r.Context(), principal.UserID, time.Now().Add(-UnattachedTTL)); err != nil {
+		log.Printf("[ERROR] Failed to purge unattached uploads: %v", err)
+	} else if len(purged) > 0 {
+		deleteFiles(r.Context(), fileStore, purged)
+		log.Printf("[INFO] Purged %d unattached uploads", len(purged))
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+type storedFile struct {
+	key         string
+	data        []byte
+	contentType string
+}
+
+// deleteFiles deletes the stored files of attachments whose rows are gone.
+// Failures are only logged: nothing refers to the files any more.
+func deleteFiles(ctx context.Context, fileStore storage.Storage, attachments []store.Attachment) {
+	var keys []string
+	for _, a := range attachments {
+		keys = append(keys, a.StorageKeys()...)
+	}
+	if err := storage.DeleteAll(ctx, fileStore, keys); err != nil {
+		log.Printf("[ERROR] Failed to delete attachment files: %v", err)
+	}
+}
+
+// readUpload returns the FileField and AltTextField parts of a multipart
+// request, enforcing media.MaxVideoBytes without buffering anything larger.
+// Images are held to media.MaxImageBytes once their type is known.
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+		apierror.Write(w, r, apierror.UnsupportedMedia, "Upload attachments as multipart/form-data")
+		return nil, "", false
+	}
+	// Bound the whole body too, so other parts cannot be used to stream
+	// unlimited data through the function.
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBytes)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeBodyError(w, r, err)
+		return nil, "", false
+	}
+
+	var data []byte
+	var altText string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeBodyError(w, r, err)
+			return nil, "", false
+		}
+
+		switch part.FormName() {
+		case FileField:
+			data, err = io.ReadAll(io.LimitReader(part, media.MaxVideoBytes+1))
+			if err == nil && len(data) > media.MaxVideoBytes {
+				apierror.Write(w, r, apierror.PayloadTooLarge, fmt.Sprintf("Attachments must be at most %d MB", media.MaxVideoBytes>>20))
+				return nil, "", false
+			}
+		case AltTextField:
+			var text []byte
+			text, err = io.ReadAll(io.LimitReader(part, 4*MaxAltTextLength+1))
+			altText = string(text)
+		}
+		if err != nil {
+			writeBodyError(w, r, err)
+			return nil, "", false
+		}
+	}
+
+	if data == nil {
+		apierror.Write(w, r, apierror.ValidationFailed, "The "+FileField+" file is required")
+		return nil, "", false
+	}
+	altText, err = normalizeAltText(altText)
+	if err != nil {
+		apierror.Write(w, r, apierror.ValidationFailed, err.Error())
+		return nil, "", false
+	}
+	return data, altText, true
+}
+
+// writeBodyError reports an error reading the multipart body, telling a
+// request over MaxRequestBytes apart from a malformed one.
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.As(err, new(*http.MaxBytesError)) {
+		apierror.Write(w, r, apierror.PayloadTooLarge, "Request body is too large")
+		return
+	}
+	apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid multipart body")
+}
+
+// normalizeAltText trims alt text and checks its length.
+func normalizeAltText(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !utf8.ValidString(s) {
+		return "", errors.New("Alt text must be valid UTF-8")
+	}
+	if utf8.RuneCountInString(s) > MaxAltTextLength {
+		return "", fmt.Errorf("Alt text must be at most %d characters", MaxAltTextLength)
+	}
+	return s, nil
+}
+
+// ownAttachment loads the attachment named by the id parameter, writing an
+// error unless it exists and belongs to the caller.
+func ownAttachment(w http.ResponseWriter, r *http.Request, attachments store.MediaRepo) (*store.Attachment, bool) {
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter, "A valid attachment id is required")
+		return nil, false
+	}
+	attachment, err := attachments.ByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.AttachmentNotFound, "Attachment not found")
+			return nil, false
+		}
+		apierror.InternalError(w, r, err, "Database error")
+		return nil, false
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	if attachment.UserID != principal.UserID {
+		apierror.Write(w, r, apierror.Forbidden, "You are not authorized to change this attachment")
+		return nil, false
+	}
+	return attachment, true
+}
+
+func updateAttachment(w http.ResponseWriter, r *http.Request, attachments store.MediaRepo) {
+	attachment, ok := ownAttachment(w, r, attachments)
+	if !ok {
+		return
+	}
+
+	var req UpdateAttachmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
+		return
+	}
+	altText, err := normalizeAltText(req.AltText)
+	if err != nil {
+		apierror.Write(w, r, apierror.ValidationFailed, err.Error())
+		return
+	}
+
+	if err := attachments.UpdateAltText(r.Context(), attachment.ID, altText); err != nil {
+		apierror.InternalError(w, r, err, "Failed to update attachment")
+		return
+	}
+	attachment.AltText = altText
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// deleteAttachment removes an unattached upload and its stored files.
+// Attachments of a post go when the post is deleted.
+func deleteAttachment(w http.ResponseWriter, r *http.Request, attachments store.MediaRepo) {
+	attachment, ok := ownAttachment(w, r, attachments)
+	if !ok {
+		return
+	}
+	if attachment.PostID != nil {
+		apierror.Write(w, r, apierror.ValidationFailed, "Attachments of a post are deleted with the post")
+		return
+	}
+
+	fileStore, err := storage.FromEnv()
+	if err != nil {
+		apierror.InternalError(w, r, err, "Media storage is not configured")
+		return
+	}
+	if err := attachments.Delete(r.Context(), attachment.ID); err != nil {
+		apierror.InternalError(w, r, err, "Failed to delete attachment")
+		return
+	}
+	deleteFiles(r.Context(), fileStore, []store.Attachment{*attachment})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Attachment deleted successfully"})
+}
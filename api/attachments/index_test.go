@@ -0,0 +1,125 @@
+package attachments
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-common/apitest"
+	"go-common/media"
+	"go-common/storage"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+// upload posts data as the file field of a multipart body, with alt text.
+func upload(t *testing.T, token string, data []byte, altText string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField(AltTextField, altText)
+	fw, err := mw.CreateFormFile(FileField, "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/attachments", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	Handler(rec, req)
+	return rec
+}
+
+func pngImage(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadAttachment(t *testing.T) {
+	db := apitest.DB(t)
+	dir := t.TempDir()
+	t.Setenv(storage.EnvDriver, "local")
+	t.Setenv(storage.EnvLocalDir, dir)
+	owner := apitest.CreateUser(t, db, "owner")
+	other := apitest.CreateUser(t, db, "other")
+
+	rec := upload(t, owner.Token, pngImage(t, 3000, 1000), "  A wide, empty picture ")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var a store.Attachment
+	apitest.Decode(t, rec, &a)
+	if a.Type != store.AttachmentImage || a.Width != media.MaxImageSide || a.Height != media.MaxImageSide/3 {
+		t.Errorf("attachment = %s %dx%d", a.Type, a.Width, a.Height)
+	}
+	if a.ThumbnailURL == nil || a.PostID != nil || a.AltText != "A wide, empty picture" {
+		t.Errorf("thumbnail, post, alt = %v, %v, %q", a.ThumbnailURL, a.PostID, a.AltText)
+	}
+
+	target := "/api/attachments?id=" + a.ID.String()
+	alt := UpdateAttachmentRequest{AltText: "Nothing to see"}
+	if rec := apitest.Do(t, Handler, http.MethodPut, target, other.Token, alt); rec.Code != http.StatusForbidden {
+		t.Errorf("someone else's alt text: status = %d, want 403", rec.Code)
+	}
+	if rec := apitest.Do(t, Handler, http.MethodPut, target, owner.Token, alt); rec.Code != http.StatusOK {
+		t.Errorf("alt text: status = %d: %s", rec.Code, rec.Body)
+	}
+	files := []string{filepath.Join(dir, a.StorageKey), filepath.Join(dir, *a.ThumbnailKey)}
+	for _, f := range files {
+		if _, err := os.Stat(f); err != nil {
+			t.Fatalf("stored file: %v", err)
+		}
+	}
+	if rec := apitest.Do(t, Handler, http.MethodDelete, target, owner.Token, nil); rec.Code != http.StatusOK {
+		t.Errorf("delete: status = %d: %s", rec.Code, rec.Body)
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s left behind after delete: %v", f, err)
+		}
+	}
+	if rec := apitest.Do(t, Handler, http.MethodDelete, target, owner.Token, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("delete again: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestUploadAttachmentRejects(t *testing.T) {
+	db := apitest.DB(t)
+	t.Setenv(storage.EnvDriver, "local")
+	t.Setenv(storage.EnvLocalDir, t.TempDir())
+	user := apitest.CreateUser(t, db, "uploader")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		altText string
+		want    int
+	}{
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "", http.StatusUnsupportedMediaType},
+		{"image too large", append(pngImage(t, 1, 1), make([]byte, media.MaxImageBytes)...), "", http.StatusRequestEntityTooLarge},
+		{"request too large", pngImage(t, 1, 1), string(make([]byte, MaxRequestBytes)), http.StatusRequestEntityTooLarge},
+		{"alt text too long", pngImage(t, 1, 1), string(bytes.Repeat([]byte("a"), MaxAltTextLength+1)), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := upload(t, user.Token, tt.data, tt.altText); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
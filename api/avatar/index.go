@@ -0,0 +1,247 @@
+package avatar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/media"
+	"go-common/storage"
+	"go-common/store"
+)
+
+const (
+	// FormField is the multipart field holding the uploaded image.
+	FormField = "avatar"
+	// MaxRequestBytes is the largest request body Vercel passes to a
+	// function. The multipart framing fits in what is left over above
+	// media.MaxAvatarBytes.
+	MaxRequestBytes = 4608 << 10
+)
+
+// AvatarResponse reports the caller's avatar after the call. AvatarURL is the
+// large variant; Variants maps every variant name to its URL.
+type AvatarResponse struct {
+	AvatarURL *string           `json:"avatar_url"`
+	Variants  map[string]string `json:"variants,omitempty"`
+}
+
+// Handler is the entry point for the /api/avatar serverless function. POST
+// uploads a new avatar as multipart/form-data; DELETE removes it.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodPost:
+		uploadAvatar(w, r)
+	case http.MethodDelete:
+		deleteAvatar(w, r)
+	default:
+		apierror.NotAllowed(w, r)
+	}
+}
+
+func uploadAvatar(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	data, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	variants, err := media.ProcessAvatar(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			apierror.Write(w, r, apierror.UnsupportedMedia, "Avatar must be a JPEG, PNG or GIF image")
+		case errors.Is(err, media.ErrTooLarge):
+			apierror.Write(w, r, apierror.PayloadTooLarge, "Avatar image dimensions are too large")
+		default:
+			apierror.InternalError(w, r, err, "Failed to process avatar")
+		}
+		return
+	}
+
+	files, err := storage.FromEnv()
+	if err != nil {
+		apierror.InternalError(w, r, err, "Avatar storage is not configured")
+		return
+	}
+	// A fresh name per upload, so caches never serve the previous avatar.
+	prefix := "avatars/" + principal.UserID.String() + "/" + uuid.NewString()
+	resp := AvatarResponse{Variants: make(map[string]string, len(variants))}
+	for _, v := range variants {
+		url, err := files.Put(r.Context(), variantKey(prefix, v.Name), v.Data, v.ContentType)
+		if err != nil {
+			apierror.InternalError(w, r, err, "Failed to store avatar")
+			return
+		}
+		resp.Variants[v.Name] = url
+	}
+	avatarURL := resp.Variants[media.AvatarSizes[0].Name]
+	resp.AvatarURL = &avatarURL
+
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+	profiles := store.NewProfileRepo(db)
+	profile, err := profiles.ByID(r.Context(), principal.UserID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to load profile")
+		return
+	}
+	if err := profiles.Update(r.Context(), principal.UserID, map[string]interface{}{"avatar_url": avatarURL}); err != nil {
+		apierror.InternalError(w, r, err, "Failed to update profile")
+		return
+	}
+	deleteFiles(r.Context(), files, principal.UserID, profile.AvatarURL)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// readUpload returns the contents of the FormField part of a multipart
+// request, enforcing media.MaxAvatarBytes without buffering anything larger.
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+		apierror.Write(w, r, apierror.UnsupportedMedia, "Upload the avatar as multipart/form-data")
+		return nil, false
+	}
+	// Bound the whole body too, so other parts cannot be used to stream
+	// unlimited data through the function.
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBytes)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeBodyError(w, r, err)
+		return nil, false
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			apierror.Write(w, r, apierror.ValidationFailed, "The "+FormField+" file is required")
+			return nil, false
+		}
+		if err != nil {
+			writeBodyError(w, r, err)
+			return nil, false
+		}
+		if part.FormName() != FormField {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, media.MaxAvatarBytes+1))
+		if err != nil {
+			writeBodyError(w, r, err)
+			return nil, false
+		}
+		if len(data) > media.MaxAvatarBytes {
+			apierror.Write(w, r, apierror.PayloadTooLarge, fmt.Sprintf("Avatar must be at most %d MB", media.MaxAvatarBytes>>20))
+			return nil, false
+		}
+		return data, true
+	}
+}
+
+// writeBodyError reports an error reading the multipart body, telling a
+// request over MaxRequestBytes apart from a malformed one.
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.As(err, new(*http.MaxBytesError)) {
+		apierror.Write(w, r, apierror.PayloadTooLarge, "Request body is too large")
+		return
+	}
+	apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid multipart body")
+}
+
+// deleteAvatar clears the caller's avatar and deletes its stored files.
+func deleteAvatar(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	files, err := storage.FromEnv()
+	if err != nil {
+		apierror.InternalError(w, r, err, "Avatar storage is not configured")
+		return
+	}
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+	profiles := store.NewProfileRepo(db)
+	profile, err := profiles.ByID(r.Context(), principal.UserID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to load profile")
+		return
+	}
+	if err := profiles.Update(r.Context(), principal.UserID, map[string]interface{}{"avatar_url": nil}); err != nil {
+		apierror.InternalError(w, r, err, "Failed to update profile")
+		return
+	}
+	deleteFiles(r.Context(), files, principal.UserID, profile.AvatarURL)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AvatarResponse{})
+}
+
+// variantKey is the storage key of one variant of the avatar stored under
+// prefix.
+func variantKey(prefix, name string) string {
+	return prefix + "-" + name + ".jpg"
+}
+
+// avatarKeys returns the storage keys of every variant of the avatar at url.
+// It returns nil for a URL this function did not store, such as a picture
+// from the sign-in provider.
+func avatarKeys(userID uuid.UUID, url string) []string {
+	dir := "avatars/" + userID.String() + "/"
+	i := strings.LastIndex(url, dir)
+	if i < 0 {
+		return nil
+	}
+	prefix, ok := strings.CutSuffix(url[i:], variantKey("", media.AvatarSizes[0].Name))
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(media.AvatarSizes))
+	for _, size := range media.AvatarSizes {
+		keys = append(keys, variantKey(prefix, size.Name))
+	}
+	return keys
+}
+
+// deleteFiles deletes the stored files of an avatar the profile no longer
+// points at. Failures are only logged: nothing refers to the files any more.
+func deleteFiles(ctx context.Context, files storage.Storage, userID uuid.UUID, url *string) {
+	if url == nil {
+		return
+	}
+	if err := storage.DeleteAll(ctx, files, avatarKeys(userID, *url)); err != nil {
+		log.Printf("[ERROR] Failed to delete avatar files: %v", err)
+	}
+}
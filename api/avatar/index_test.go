@@ -0,0 +1,168 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/media"
+	"go-common/storage"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+// upload posts data as the avatar field of a multipart body.
+func upload(t *testing.T, token, field string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile(field, "avatar.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/avatar", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	Handler(rec, req)
+	return rec
+}
+
+func TestUploadAvatar(t *testing.T) {
+	db := apitest.DB(t)
+	dir := t.TempDir()
+	t.Setenv(storage.EnvDriver, "local")
+	t.Setenv(storage.EnvLocalDir, dir)
+	user := apitest.CreateUser(t, db, "avatar")
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 120, 80))); err != nil {
+		t.Fatal(err)
+	}
+
+	// variantFiles uploads the image and returns the paths of its variants.
+	variantFiles := func() (AvatarResponse, []string) {
+		t.Helper()
+		rec := upload(t, user.Token, FormField, img.Bytes())
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		var got AvatarResponse
+		apitest.Decode(t, rec, &got)
+		if got.AvatarURL == nil || len(got.Variants) != len(media.AvatarSizes) {
+			t.Fatalf("response = %+v", got)
+		}
+		var paths []string
+		for name, url := range got.Variants {
+			key := strings.TrimPrefix(url, storage.DefaultPublicURL+"/")
+			path := filepath.Join(dir, filepath.FromSlash(key))
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("%s variant not stored: %v", name, err)
+			}
+			paths = append(paths, path)
+		}
+		return got, paths
+	}
+	assertGone := func(paths []string) {
+		t.Helper()
+		for _, path := range paths {
+			if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s left behind: %v", path, err)
+			}
+		}
+	}
+
+	_, replaced := variantFiles()
+	got, current := variantFiles()
+	assertGone(replaced)
+
+	profile, err := store.NewProfileRepo(db).ByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.AvatarURL == nil || *profile.AvatarURL != *got.AvatarURL {
+		t.Errorf("profile avatar_url = %v, want %s", profile.AvatarURL, *got.AvatarURL)
+	}
+
+	rec := apitest.Do(t, Handler, http.MethodDelete, "/api/avatar", user.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, body %s", rec.Code, rec.Body)
+	}
+	profile, _ = store.NewProfileRepo(db).ByID(context.Background(), user.ID)
+	if profile.AvatarURL != nil {
+		t.Errorf("avatar_url after delete = %s", *profile.AvatarURL)
+	}
+	assertGone(current)
+}
+
+func TestAvatarKeys(t *testing.T) {
+	user := uuid.MustParse("7d1f8c3e-2b4a-4f6e-9c1d-5e8a7b3f2c10")
+	other := uuid.New()
+	stored := "https://example.supabase.co/storage/v1/object/public/media/avatars/" + user.String() + "/abc-large.jpg"
+
+	tests := []struct {
+		name string
+		url  string
+		want []string
+	}{
+		{"stored", stored, []string{"avatars/" + user.String() + "/abc-large.jpg", "avatars/" + user.String() + "/abc-small.jpg"}},
+		{"another user's", strings.Replace(stored, user.String(), other.String(), 1), nil},
+		{"not a variant", strings.TrimSuffix(stored, "-large.jpg") + ".png", nil},
+		{"from the sign-in provider", "https://lh3.googleusercontent.com/a/photo.jpg", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := avatarKeys(user, tt.url); !slices.Equal(got, tt.want) {
+				t.Errorf("avatarKeys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadAvatarRejects(t *testing.T) {
+	db := apitest.DB(t)
+	t.Setenv(storage.EnvDriver, "local")
+	t.Setenv(storage.EnvLocalDir, t.TempDir())
+	user := apitest.CreateUser(t, db, "avatar")
+
+	tests := []struct {
+		name  string
+		field string
+		data  []byte
+		want  int
+	}{
+		{"not an image", FormField, []byte("<svg></svg>"), http.StatusUnsupportedMediaType},
+		{"too large", FormField, bytes.Repeat([]byte{0}, media.MaxAvatarBytes+1), http.StatusRequestEntityTooLarge},
+		{"request too large", "picture", make([]byte, MaxRequestBytes), http.StatusRequestEntityTooLarge},
+		{"missing field", "picture", []byte("x"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := upload(t, user.Token, tt.field, tt.data); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d, body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/avatar", user.Token, map[string]string{"avatar": "x"})
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("json body status = %d, want %d", rec.Code, http.StatusUnsupportedMediaType)
+	}
+}
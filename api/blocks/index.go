@@ -0,0 +1,191 @@
+package blocks
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/store"
+)
+
+// The relationship kinds selected with ?type=. Blocks are the default.
+const (
+	TypeBlock = "block"
+	TypeMute  = "mute"
+)
+
+// BlockRequest names the user to block, unblock, mute or unmute.
+type BlockRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// BlockResponse reports the relationship after the call. Active says whether
+// the caller now blocks (or mutes) the user.
+type BlockResponse struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Type    string    `json:"type"`
+	Active  bool      `json:"active"`
+	Changed bool      `json:"changed"`
+}
+
+// Handler is the entry point for the /api/blocks serverless function. It
+// manages the caller's blocks, or their mutes with ?type=mute.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	kind := r.URL.Query().Get("type")
+	if kind == "" {
+		kind = TypeBlock
+	}
+	if kind != TypeBlock && kind != TypeMute {
+		apierror.Write(w, r, apierror.InvalidParameter, "Type must be 'block' or 'mute'")
+		return
+	}
+
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list(w, r, db, kind)
+	case http.MethodPost:
+		create(w, r, db, kind)
+	case http.MethodDelete:
+		remove(w, r, db, kind)
+	default:
+		apierror.NotAllowed(w, r)
+	}
+}
+
+// list serves GET /api/blocks[?type=...&limit=...&cursor=...], newest first.
+func list(w http.ResponseWriter, r *http.Request, db *gorm.DB, kind string) {
+	principal, _ := auth.FromContext(r.Context())
+
+	page, err := store.ParsePage(r.URL.Query().Get("limit"), r.URL.Query().Get("cursor"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidCursor, "Invalid cursor")
+		return
+	}
+
+	var result any
+	if kind == TypeMute {
+		var mutes []store.Mute
+		mutes, err = store.NewMuteRepo(db).List(r.Context(), principal.UserID, page)
+		result = store.NewPageResult(mutes, page, store.MuteCursor)
+	} else {
+		var blocks []store.Block
+		blocks, err = store.NewBlockRepo(db).List(r.Context(), principal.UserID, page)
+		result = store.NewPageResult(blocks, page, store.BlockCursor)
+	}
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch "+kind+"s")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// create blocks or mutes the user. Doing it again is not an error: it answers
+// 200 instead of 201 with "changed": false. Blocking also removes any follows
+// and follow requests between the two users.
+func create(w http.ResponseWriter, r *http.Request, db *gorm.DB, kind string) {
+	principal, _ := auth.FromContext(r.Context())
+	userID, ok := decodeUser(w, r)
+	if !ok {
+		return
+	}
+
+	if userID == principal.UserID {
+		apierror.Write(w, r, apierror.CannotBlockSelf, "You cannot "+kind+" yourself")
+		return
+	}
+	if _, err := store.NewProfileRepo(db).ByID(r.Context(), userID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.ProfileNotFound, "User not found")
+			return
+		}
+		apierror.InternalError(w, r, err, "Database query error")
+		return
+	}
+
+	var created bool
+	var err error
+	if kind == TypeMute {
+		created, err = store.NewMuteRepo(db).Create(r.Context(), &store.Mute{MuterID: principal.UserID, MutedID: userID})
+	} else {
+		created, err = store.NewBlockRepo(db).Create(r.Context(), &store.Block{BlockerID: principal.UserID, BlockedID: userID})
+	}
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to "+kind+" user")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(BlockResponse{UserID: userID, Type: kind, Active: true, Changed: created})
+}
+
+// remove unblocks or unmutes the user. Undoing something that was not done
+// answers 200 with "changed": false.
+func remove(w http.ResponseWriter, r *http.Request, db *gorm.DB, kind string) {
+	principal, _ := auth.FromContext(r.Context())
+	userID, ok := decodeUser(w, r)
+	if !ok {
+		return
+	}
+
+	var removed bool
+	var err error
+	if kind == TypeMute {
+		removed, err = store.NewMuteRepo(db).Delete(r.Context(), principal.UserID, userID)
+	} else {
+		removed, err = store.NewBlockRepo(db).Delete(r.Context(), principal.UserID, userID)
+	}
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to un"+kind+" user")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(BlockResponse{UserID: userID, Type: kind, Active: false, Changed: removed})
+}
+
+func decodeUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	var req BlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
+		return uuid.Nil, false
+	}
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter, "Invalid user_id")
+		return uuid.Nil, false
+	}
+	return userID, true
+}
@@ -0,0 +1,97 @@
+package blocks
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+// Blocking removes the follows in both directions and is idempotent.
+func TestBlock(t *testing.T) {
+	db := apitest.DB(t)
+	blocker := apitest.CreateUser(t, db, "blocker")
+	blocked := apitest.CreateUser(t, db, "blocked")
+	apitest.Follow(t, db, blocker.ID, blocked.ID)
+	apitest.Follow(t, db, blocked.ID, blocker.ID)
+	body := BlockRequest{UserID: blocked.ID.String()}
+
+	steps := []struct {
+		name        string
+		method      string
+		want        int
+		wantChanged bool
+		wantBlocked bool
+	}{
+		{"block", http.MethodPost, http.StatusCreated, true, true},
+		{"block again", http.MethodPost, http.StatusOK, false, true},
+		{"unblock", http.MethodDelete, http.StatusOK, true, false},
+		{"unblock again", http.MethodDelete, http.StatusOK, false, false},
+	}
+	for _, step := range steps {
+		rec := apitest.Do(t, Handler, step.method, "/api/blocks", blocker.Token, body)
+		if rec.Code != step.want {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, rec.Code, step.want, rec.Body)
+		}
+		var resp BlockResponse
+		apitest.Decode(t, rec, &resp)
+		if resp.Changed != step.wantChanged || resp.Active != step.wantBlocked {
+			t.Errorf("%s: changed/active = %v/%v, want %v/%v",
+				step.name, resp.Changed, resp.Active, step.wantChanged, step.wantBlocked)
+		}
+
+		blockedNow, err := store.NewBlockRepo(db).Between(context.Background(), blocker.ID, blocked.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blockedNow != step.wantBlocked {
+			t.Fatalf("%s: blocked = %v, want %v", step.name, blockedNow, step.wantBlocked)
+		}
+	}
+
+	var follows int64
+	err := db.Model(&store.Follow{}).
+		Where("follower_id IN (?, ?) AND following_id IN (?, ?)", blocker.ID, blocked.ID, blocker.ID, blocked.ID).
+		Count(&follows).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if follows != 0 {
+		t.Errorf("%d follows survived the block, want 0", follows)
+	}
+}
+
+func TestBlockValidation(t *testing.T) {
+	db := apitest.DB(t)
+	user := apitest.CreateUser(t, db, "validator")
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   any
+		want   int
+	}{
+		{"self-block", http.MethodPost, "/api/blocks", BlockRequest{UserID: user.ID.String()}, http.StatusUnprocessableEntity},
+		{"self-mute", http.MethodPost, "/api/blocks?type=mute", BlockRequest{UserID: user.ID.String()}, http.StatusUnprocessableEntity},
+		{"unknown user", http.MethodPost, "/api/blocks", BlockRequest{UserID: uuid.NewString()}, http.StatusNotFound},
+		{"invalid user_id", http.MethodPost, "/api/blocks", BlockRequest{UserID: "nope"}, http.StatusBadRequest},
+		{"unknown type", http.MethodGet, "/api/blocks?type=hide", nil, http.StatusBadRequest},
+		{"wrong method", http.MethodPut, "/api/blocks", nil, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, tt.method, tt.target, user.Token, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
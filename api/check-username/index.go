@@ -2,112 +2,83 @@ package checkusername
 
 import (
 	"encoding/json"
-	"log"
+	"math/rand"
 	"net/http"
-	"os"
-	"sync"
 	"time"
 
-	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"go-common/apierror"
+	"go-common/ratelimit"
+	"go-common/store"
+	"go-common/username"
 )
 
-var (
-	db   *gorm.DB
-	once sync.Once
-)
+// MaxSuggestions is the most alternatives offered for an unavailable name.
+const MaxSuggestions = 3
 
-// Profile represents the user profile model
+// limit keeps anyone from enumerating usernames through this endpoint, which
+// needs no sign-in.
+var limit = ratelimit.Limit{Name: "check-username", Requests: 30, Window: time.Minute}
 
-type Profile struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	Username  string    `gorm:"unique;not null" json:"username"`
-	FullName  *string   `json:"full_name"`
-	UpdatedAt *time.Time `json:"updated_at"`
-	AvatarURL *string   `json:"avatar_url"`
-	Website   *string   `json:"website"`
-}
-
-func Connect() (*gorm.DB, error) {
-	var err error
-	once.Do(func() {
-		if os.Getenv("VERCEL_ENV") == "" {
-			err = godotenv.Load()
-			if err != nil {
-				log.Println("Warning: .env file not found, relying on environment variables")
-			}
-		}
-		dsn := os.Getenv("DIRECT_URL") // Try DIRECT_URL first
-		if dsn == "" {
-			dsn = os.Getenv("DATABASE_URL") // Fallback to DATABASE_URL
-		}
-		if dsn == "" {
-			log.Fatal("FATAL: Neither DIRECT_URL nor DATABASE_URL environment variable is set")
-		}
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			PrepareStmt: false, // Disable prepared statement caching for serverless environment
-		})
-		if err != nil {
-			log.Fatalf("FATAL: Failed to connect to database: %v", err)
-		}
-
-		sqlDB, err := db.DB()
-		if err != nil {
-			log.Fatalf("FATAL: Failed to get underlying sql.DB: %v", err)
-		}
-		sqlDB.SetMaxIdleConns(1) // Keep very few idle connections
-		sqlDB.SetMaxOpenConns(1) // Limit total open connections
-		sqlDB.SetConnMaxLifetime(time.Minute) // Short lifetime
-
-		log.Println("Database connection successful and pool established.")
-	})
-	if err != nil {
-		return nil, err
-	}
-	return db, nil
-}
-
-func GetDB() (*gorm.DB, error) {
-	if db == nil {
-		return Connect()
-	}
-	return db,
-	nil
+// CheckResponse says whether a username can be chosen. When it cannot,
+// Reason is one of the username.Reason codes, Message explains it to people
+// and Suggestions holds available alternatives, if any were found.
+type CheckResponse struct {
+	Available   bool     `json:"available"`
+	Reason      string   `json:"reason,omitempty"`
+	Message     string   `json:"message,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
 }
 
+// Handler is the entry point for the /api/check-username serverless function.
+// It takes the requested username and, optionally, a full_name to base
+// suggestions on.
 func Handler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	username := r.URL.Query().Get("username")
-	if username == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Username is required"})
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+	if !limit.Allow(w, r, db) {
 		return
 	}
 
-	db, err := GetDB()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Database connection error"})
+	name := r.URL.Query().Get("username")
+	if name == "" {
+		apierror.Write(w, r, apierror.InvalidParameter, "Username is required")
 		return
 	}
 
-	var profile Profile
-	result := db.Where("username = ?", username).First(&profile)
+	profiles := store.NewProfileRepo(db)
+	resp := CheckResponse{Available: true}
+	if err := username.Validate(name); err != nil {
+		resp = CheckResponse{Reason: username.Reason(err), Message: err.Error()}
+	} else {
+		taken, err := profiles.UsernameTaken(r.Context(), name)
+		if err != nil {
+			apierror.InternalError(w, r, err, "Database query error")
+			return
+		}
+		if taken {
+			resp = CheckResponse{Reason: username.ReasonTaken, Message: "Username is already taken"}
+		}
+	}
 
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(map[string]bool{"available": true})
+	if !resp.Available {
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+		candidates := username.Candidates(name, r.URL.Query().Get("full_name"), rnd)
+		available, err := profiles.AvailableUsernames(r.Context(), candidates)
+		if err != nil {
+			apierror.InternalError(w, r, err, "Database query error")
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Database query error"})
-		return
+		if len(available) > MaxSuggestions {
+			available = available[:MaxSuggestions]
+		}
+		resp.Suggestions = available
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]bool{"available": false})
+	json.NewEncoder(w).Encode(resp)
 }
@@ -0,0 +1,101 @@
+package checkusername
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/username"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+func TestCheckUsername(t *testing.T) {
+	db := apitest.DB(t)
+	taken := apitest.CreateUser(t, db, "taken")
+
+	tests := []struct {
+		name          string
+		username      string
+		want          int
+		wantAvailable bool
+		wantReason    string
+	}{
+		{"taken", taken.Username, http.StatusOK, false, username.ReasonTaken},
+		{"taken in another case", strings.ToUpper(taken.Username), http.StatusOK, false, username.ReasonTaken},
+		{"available", "free_" + uuid.NewString()[:8], http.StatusOK, true, ""},
+		{"reserved", "settings", http.StatusOK, false, username.ReasonReserved},
+		{"too short", "ab", http.StatusOK, false, username.ReasonTooShort},
+		{"invalid", "no spaces", http.StatusOK, false, username.ReasonInvalidChars},
+		{"profanity", "shithead", http.StatusOK, false, username.ReasonProfanity},
+		{"missing", "", http.StatusBadRequest, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, http.MethodGet, "/api/check-username?username="+url.QueryEscape(tt.username), "", nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var resp CheckResponse
+			apitest.Decode(t, rec, &resp)
+			if resp.Available != tt.wantAvailable || resp.Reason != tt.wantReason {
+				t.Errorf("available, reason = %v, %q, want %v, %q", resp.Available, resp.Reason, tt.wantAvailable, tt.wantReason)
+			}
+			if !resp.Available && tt.wantReason != username.ReasonProfanity && len(resp.Suggestions) == 0 {
+				t.Error("no suggestions for an unavailable name")
+			}
+		})
+	}
+}
+
+// Suggestions are never taken, and draw on the full name.
+func TestCheckUsernameSuggestions(t *testing.T) {
+	db := apitest.DB(t)
+	taken := apitest.CreateUser(t, db, "taken")
+	fullName := "Zed " + uuid.NewString()[:6]
+
+	target := "/api/check-username?username=" + url.QueryEscape(taken.Username) + "&full_name=" + url.QueryEscape(fullName)
+	rec := apitest.Do(t, Handler, http.MethodGet, target, "", nil)
+	var resp CheckResponse
+	apitest.Decode(t, rec, &resp)
+	if len(resp.Suggestions) == 0 || len(resp.Suggestions) > MaxSuggestions {
+		t.Fatalf("suggestions = %v", resp.Suggestions)
+	}
+	for _, s := range resp.Suggestions {
+		if strings.EqualFold(s, taken.Username) {
+			t.Errorf("suggested the taken name %q", s)
+		}
+	}
+}
+
+func TestCheckUsernameRateLimit(t *testing.T) {
+	apitest.DB(t)
+	// The test database is new for every run, so no earlier run has used up
+	// this address's allowance.
+	const ip = "198.51.100.77"
+	check := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/check-username?username=anyone", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		rec := httptest.NewRecorder()
+		Handler(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < limit.Requests; i++ {
+		if code := check(); code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i+1, code)
+		}
+	}
+	if code := check(); code != http.StatusTooManyRequests {
+		t.Errorf("request over the limit: status = %d, want 429", code)
+	}
+}
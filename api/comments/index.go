@@ -0,0 +1,286 @@
+package comments
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/notify"
+	"go-common/store"
+)
+
+// CreateCommentRequest is the body of POST /api/comments. ParentID makes the
+// comment a reply to another comment on the same post.
+type CreateCommentRequest struct {
+	PostID   string  `json:"post_id"`
+	ParentID *string `json:"parent_id"`
+	Content  string  `json:"content"`
+}
+
+// UpdateCommentRequest is the body of PUT /api/comments?id=...
+type UpdateCommentRequest struct {
+	Content string `json:"content"`
+}
+
+// Handler is the entry point for the /api/comments serverless function.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+	comments := store.NewCommentRepo(db)
+	posts := store.NewPostRepo(db)
+	follows := store.NewFollowRepo(db)
+
+	switch r.Method {
+	case http.MethodGet:
+		listComments(w, r, comments, posts, follows, store.NewLikeRepo(db))
+	case http.MethodPost:
+		createComment(w, r, comments, posts, follows, notify.New(db))
+	case http.MethodPut:
+		updateComment(w, r, comments)
+	case http.MethodDelete:
+		deleteComment(w, r, comments)
+	default:
+		apierror.NotAllowed(w, r)
+	}
+}
+
+// listComments serves GET /api/comments?post_id=...[&parent_id=...][&limit=...&cursor=...].
+func listComments(w http.ResponseWriter, r *http.Request, comments store.CommentRepo, posts store.PostRepo, follows store.FollowRepo, likes store.LikeRepo) {
+	postID, err := uuid.Parse(r.URL.Query().Get("post_id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter, "Invalid post_id")
+		return
+	}
+	if _, ok := visiblePost(w, r, posts, follows, postID); !ok {
+		return
+	}
+
+	var parentID *uuid.UUID
+	if s := r.URL.Query().Get("parent_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			apierror.Write(w, r, apierror.InvalidParameter, "Invalid parent_id")
+			return
+		}
+		parentID = &id
+	}
+
+	page, err := store.ParsePage(r.URL.Query().Get("limit"), r.URL.Query().Get("cursor"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidCursor, "Invalid cursor")
+		return
+	}
+
+	list, err := comments.ByPost(r.Context(), postID, parentID, page)
+	if err == nil {
+		principal, _ := auth.FromContext(r.Context())
+		err = likes.FillComments(r.Context(), principal.UserID, list)
+	}
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch comments")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(store.NewPageResult(list, page, store.CommentCursor))
+}
+
+func createComment(w http.ResponseWriter, r *http.Request, comments store.CommentRepo, posts store.PostRepo, follows store.FollowRepo, notifier *notify.Notifier) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var req CreateCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
+		return
+	}
+
+	postID, err := uuid.Parse(req.PostID)
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter, "Invalid post_id")
+		return
+	}
+
+	if req.Content == "" {
+		apierror.Write(w, r, apierror.ValidationFailed, "Comment content cannot be empty")
+		return
+	}
+
+	if _, ok := visiblePost(w, r, posts, follows, postID); !ok {
+		return
+	}
+
+	comment := &store.Comment{ID: uuid.New(), PostID: postID, UserID: principal.UserID, Content: req.Content}
+
+	if req.ParentID != nil {
+		parentID, err := uuid.Parse(*req.ParentID)
+		if err != nil {
+			apierror.Write(w, r, apierror.InvalidParameter, "Invalid parent_id")
+			return
+		}
+		parent, err := comments.ByID(r.Context(), parentID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				apierror.Write(w, r, apierror.CommentNotFound, "Parent comment not found")
+				return
+			}
+			apierror.InternalError(w, r, err, "Database query error")
+			return
+		}
+		if parent.PostID != postID {
+			apierror.Write(w, r, apierror.ValidationFailed, "Parent comment belongs to a different post")
+			return
+		}
+		comment.ParentID = &parent.ID
+	}
+
+	if err := comments.Create(r.Context(), comment); err != nil {
+		apierror.InternalError(w, r, err, "Failed to create comment")
+		return
+	}
+	notifier.Commented(r.Context(), comment)
+
+	// To return the created comment with user info
+	created, err := comments.ByID(r.Context(), comment.ID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to retrieve created comment")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func updateComment(w http.ResponseWriter, r *http.Request, comments store.CommentRepo) {
+	comment, ok := ownComment(w, r, comments, "edit")
+	if !ok {
+		return
+	}
+
+	var req UpdateCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
+		return
+	}
+
+	if req.Content == "" {
+		apierror.Write(w, r, apierror.ValidationFailed, "Comment content cannot be empty")
+		return
+	}
+
+	if err := comments.UpdateContent(r.Context(), comment.ID, req.Content); err != nil {
+		apierror.InternalError(w, r, err, "Failed to update comment")
+		return
+	}
+
+	updated, err := comments.ByID(r.Context(), comment.ID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to retrieve updated comment")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updated)
+}
+
+func deleteComment(w http.ResponseWriter, r *http.Request, comments store.CommentRepo) {
+	comment, ok := ownComment(w, r, comments, "delete")
+	if !ok {
+		return
+	}
+
+	// Replies are removed with their parent by the ON DELETE CASCADE on parent_id.
+	if err := comments.Delete(r.Context(), comment.ID); err != nil {
+		apierror.InternalError(w, r, err, "Failed to delete comment")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Comment deleted successfully"})
+}
+
+// visiblePost loads the post with the given ID and checks that the caller may
+// see it. Posts of private accounts the caller does not follow, and of
+// accounts on either side of a block, get the same 404 as posts that do not
+// exist. It writes the error response and returns false otherwise.
+func visiblePost(w http.ResponseWriter, r *http.Request, posts store.PostRepo, follows store.FollowRepo, postID uuid.UUID) (*store.Post, bool) {
+	post, err := posts.ByID(r.Context(), postID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.PostNotFound, "Post not found")
+			return nil, false
+		}
+		apierror.InternalError(w, r, err, "Database query error")
+		return nil, false
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	visible, err := follows.CanView(r.Context(), principal.UserID, &post.User)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Database query error")
+		return nil, false
+	}
+	if !visible {
+		apierror.Write(w, r, apierror.PostNotFound, "Post not found")
+		return nil, false
+	}
+
+	return post, true
+}
+
+// ownComment loads the comment named by the "id" query parameter and checks
+// that the caller wrote it, the same ownership rule posts applies to edits and
+// deletes. It writes the error response and returns false otherwise.
+func ownComment(w http.ResponseWriter, r *http.Request, comments store.CommentRepo, action string) (*store.Comment, bool) {
+	commentIDStr := r.URL.Query().Get("id")
+	if commentIDStr == "" {
+		apierror.Write(w, r, apierror.InvalidParameter, "Comment ID is required")
+		return nil, false
+	}
+
+	commentID, err := uuid.Parse(commentIDStr)
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter, "Invalid Comment ID format")
+		return nil, false
+	}
+
+	comment, err := comments.ByID(r.Context(), commentID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.CommentNotFound, "Comment not found")
+			return nil, false
+		}
+		apierror.InternalError(w, r, err, "Database query error")
+		return nil, false
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	if comment.UserID != principal.UserID {
+		apierror.Write(w, r, apierror.Forbidden, "You are not authorized to "+action+" this comment")
+		return nil, false
+	}
+
+	return comment, true
+}
@@ -0,0 +1,239 @@
+package comments
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+type commentPage struct {
+	Items      []store.Comment `json:"items"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+func postComment(t *testing.T, user apitest.User, postID uuid.UUID, parentID *uuid.UUID, content string) store.Comment {
+	t.Helper()
+	req := CreateCommentRequest{PostID: postID.String(), Content: content}
+	if parentID != nil {
+		s := parentID.String()
+		req.ParentID = &s
+	}
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/comments", user.Token, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("comment %q: status = %d: %s", content, rec.Code, rec.Body)
+	}
+	var c store.Comment
+	apitest.Decode(t, rec, &c)
+	return c
+}
+
+func fetchComments(t *testing.T, user apitest.User, target string) commentPage {
+	t.Helper()
+	rec := apitest.Do(t, Handler, http.MethodGet, target, user.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list %s: status = %d: %s", target, rec.Code, rec.Body)
+	}
+	var page commentPage
+	apitest.Decode(t, rec, &page)
+	return page
+}
+
+// Top-level comments and replies are listed separately, oldest first, with
+// each top-level comment carrying its reply count.
+func TestCommentThreading(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	commenter := apitest.CreateUser(t, db, "commenter")
+	post := apitest.CreatePost(t, db, author.ID, "a post")
+
+	first := postComment(t, commenter, post.ID, nil, "first")
+	second := postComment(t, author, post.ID, nil, "second")
+	reply1 := postComment(t, author, post.ID, &first.ID, "reply 1")
+	reply2 := postComment(t, commenter, post.ID, &first.ID, "reply 2")
+	if reply1.ParentID == nil || *reply1.ParentID != first.ID {
+		t.Fatalf("reply parent = %v, want %s", reply1.ParentID, first.ID)
+	}
+
+	top := fetchComments(t, commenter, "/api/comments?post_id="+post.ID.String())
+	if len(top.Items) != 2 || top.Items[0].ID != first.ID || top.Items[1].ID != second.ID {
+		t.Fatalf("top-level comments = %+v, want first and second, oldest first", top.Items)
+	}
+	if top.Items[0].ReplyCount != 2 || top.Items[1].ReplyCount != 0 {
+		t.Errorf("reply counts = %d/%d, want 2/0", top.Items[0].ReplyCount, top.Items[1].ReplyCount)
+	}
+
+	replies := fetchComments(t, commenter, "/api/comments?post_id="+post.ID.String()+"&parent_id="+first.ID.String())
+	if len(replies.Items) != 2 || replies.Items[0].ID != reply1.ID || replies.Items[1].ID != reply2.ID {
+		t.Fatalf("replies = %+v, want reply 1 and reply 2, oldest first", replies.Items)
+	}
+
+	// A reply must be on the same post as its parent.
+	other := apitest.CreatePost(t, db, author.ID, "another post")
+	parent := first.ID.String()
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/comments", commenter.Token,
+		CreateCommentRequest{PostID: other.ID.String(), ParentID: &parent, Content: "misplaced"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("reply on another post: status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+
+	// Deleting a comment removes its replies with it.
+	rec = apitest.Do(t, Handler, http.MethodDelete, "/api/comments?id="+first.ID.String(), commenter.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d: %s", rec.Code, rec.Body)
+	}
+	var left int64
+	if err := db.Model(&store.Comment{}).Where("id IN ?", []uuid.UUID{first.ID, reply1.ID, reply2.ID}).Count(&left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("%d of the deleted thread's comments remain, want 0", left)
+	}
+}
+
+func TestCommentPagination(t *testing.T) {
+	db := apitest.DB(t)
+	user := apitest.CreateUser(t, db, "pager")
+	post := apitest.CreatePost(t, db, user.ID, "a busy post")
+
+	var want []uuid.UUID
+	for _, content := range []string{"one", "two", "three"} {
+		want = append(want, postComment(t, user, post.ID, nil, content).ID)
+	}
+
+	var got []uuid.UUID
+	target := "/api/comments?limit=2&post_id=" + post.ID.String()
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("pagination did not terminate")
+		}
+		page := fetchComments(t, user, target)
+		for _, c := range page.Items {
+			got = append(got, c.ID)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		target = "/api/comments?limit=2&post_id=" + post.ID.String() + "&cursor=" + *page.NextCursor
+	}
+	if len(got) != len(want) {
+		t.Fatalf("paged through %d comments, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("comment %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+// Only a comment's author can edit or delete it.
+func TestCommentOwnership(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	other := apitest.CreateUser(t, db, "other")
+	post := apitest.CreatePost(t, db, author.ID, "a post")
+	c := postComment(t, author, post.ID, nil, "original")
+	target := "/api/comments?id=" + c.ID.String()
+
+	if rec := apitest.Do(t, Handler, http.MethodPut, target, other.Token, UpdateCommentRequest{Content: "hijacked"}); rec.Code != http.StatusForbidden {
+		t.Errorf("edit by another user: status = %d, want 403", rec.Code)
+	}
+	if rec := apitest.Do(t, Handler, http.MethodDelete, target, other.Token, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("delete by another user: status = %d, want 403", rec.Code)
+	}
+
+	rec := apitest.Do(t, Handler, http.MethodPut, target, author.Token, UpdateCommentRequest{Content: "edited"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("edit: status = %d: %s", rec.Code, rec.Body)
+	}
+	var updated store.Comment
+	apitest.Decode(t, rec, &updated)
+	if updated.Content != "edited" {
+		t.Errorf("content = %q, want %q", updated.Content, "edited")
+	}
+}
+
+func TestCommentValidation(t *testing.T) {
+	db := apitest.DB(t)
+	user := apitest.CreateUser(t, db, "validator")
+	post := apitest.CreatePost(t, db, user.ID, "a post")
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   any
+		want   int
+	}{
+		{"empty content", http.MethodPost, "/api/comments", CreateCommentRequest{PostID: post.ID.String()}, http.StatusBadRequest},
+		{"invalid post_id", http.MethodPost, "/api/comments", CreateCommentRequest{PostID: "nope", Content: "hi"}, http.StatusBadRequest},
+		{"unknown post", http.MethodPost, "/api/comments", CreateCommentRequest{PostID: uuid.NewString(), Content: "hi"}, http.StatusNotFound},
+		{"list without post_id", http.MethodGet, "/api/comments", nil, http.StatusBadRequest},
+		{"edit unknown comment", http.MethodPut, "/api/comments?id=" + uuid.NewString(), UpdateCommentRequest{Content: "hi"}, http.StatusNotFound},
+		{"delete without id", http.MethodDelete, "/api/comments", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, tt.method, tt.target, user.Token, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+// Comments on posts of private accounts are only readable and writable by
+// approved followers, and neither side of a block can use them.
+func TestCommentsOnHiddenPosts(t *testing.T) {
+	db := apitest.DB(t)
+	private := apitest.CreateUser(t, db, "private")
+	follower := apitest.CreateUser(t, db, "follower")
+	stranger := apitest.CreateUser(t, db, "stranger")
+	blocker := apitest.CreateUser(t, db, "blocker")
+	if err := db.Model(&store.Profile{}).Where("id = ?", private.ID).Update("is_private", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	apitest.Follow(t, db, follower.ID, private.ID)
+	block := &store.Block{BlockerID: blocker.ID, BlockedID: stranger.ID}
+	if _, err := store.NewBlockRepo(db).Create(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+	privatePost := apitest.CreatePost(t, db, private.ID, "followers only")
+	blockerPost := apitest.CreatePost(t, db, blocker.ID, "not for stranger")
+
+	tests := []struct {
+		name string
+		user apitest.User
+		post store.Post
+		want bool
+	}{
+		{"author", private, privatePost, true},
+		{"follower", follower, privatePost, true},
+		{"stranger", stranger, privatePost, false},
+		{"blocked", stranger, blockerPost, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantList, wantCreate := http.StatusOK, http.StatusCreated
+			if !tt.want {
+				wantList, wantCreate = http.StatusNotFound, http.StatusNotFound
+			}
+			rec := apitest.Do(t, Handler, http.MethodGet, "/api/comments?post_id="+tt.post.ID.String(), tt.user.Token, nil)
+			if rec.Code != wantList {
+				t.Errorf("list: status = %d, want %d: %s", rec.Code, wantList, rec.Body)
+			}
+			rec = apitest.Do(t, Handler, http.MethodPost, "/api/comments", tt.user.Token,
+				CreateCommentRequest{PostID: tt.post.ID.String(), Content: "hello"})
+			if rec.Code != wantCreate {
+				t.Errorf("comment: status = %d, want %d: %s", rec.Code, wantCreate, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package followrequests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/notify"
+	"go-common/store"
+)
+
+// DecisionResponse reports the outcome of approving or rejecting a request.
+// Follow is set when the request was approved.
+type DecisionResponse struct {
+	RequestID   uuid.UUID     `json:"request_id"`
+	RequesterID uuid.UUID     `json:"requester_id"`
+	Approved    bool          `json:"approved"`
+	Follow      *store.Follow `json:"follow,omitempty"`
+}
+
+// Handler is the entry point for the /api/follow-requests serverless function.
+// It lets the owner of a private account list, approve and reject the
+// requests to follow them.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		listRequests(w, r, store.NewFollowRequestRepo(db))
+	case http.MethodPost:
+		approveRequest(w, r, db)
+	case http.MethodDelete:
+		rejectRequest(w, r, store.NewFollowRequestRepo(db))
+	default:
+		apierror.NotAllowed(w, r)
+	}
+}
+
+// listRequests serves GET /api/follow-requests[?limit=...&cursor=...], the
+// caller's pending requests, newest first.
+func listRequests(w http.ResponseWriter, r *http.Request, requests store.FollowRequestRepo) {
+	principal, _ := auth.FromContext(r.Context())
+
+	page, err := store.ParsePage(r.URL.Query().Get("limit"), r.URL.Query().Get("cursor"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidCursor, "Invalid cursor")
+		return
+	}
+
+	list, err := requests.Pending(r.Context(), principal.UserID, page)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch follow requests")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(store.NewPageResult(list, page, store.FollowRequestCursor))
+}
+
+// approveRequest serves POST /api/follow-requests?id=..., turning the request
+// into a follow.
+func approveRequest(w http.ResponseWriter, r *http.Request, db *gorm.DB) {
+	req, ok := findRequest(w, r, store.NewFollowRequestRepo(db))
+	if !ok {
+		return
+	}
+
+	follow, err := store.NewFollowRequestRepo(db).Approve(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.FollowRequestNotFound, "Follow request not found")
+			return
+		}
+		apierror.InternalError(w, r, err, "Failed to approve follow request")
+		return
+	}
+	notify.New(db).Followed(r.Context(), follow.FollowerID, follow.FollowingID)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DecisionResponse{
+		RequestID:   req.ID,
+		RequesterID: req.RequesterID,
+		Approved:    true,
+		Follow:      follow,
+	})
+}
+
+// rejectRequest serves DELETE /api/follow-requests?id=....
+func rejectRequest(w http.ResponseWriter, r *http.Request, requests store.FollowRequestRepo) {
+	req, ok := findRequest(w, r, requests)
+	if !ok {
+		return
+	}
+
+	if _, err := requests.Delete(r.Context(), req.RequesterID, req.TargetID); err != nil {
+		apierror.InternalError(w, r, err, "Failed to reject follow request")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DecisionResponse{RequestID: req.ID, RequesterID: req.RequesterID})
+}
+
+// findRequest loads the request named by ?id=, which must have been made to
+// the caller.
+func findRequest(w http.ResponseWriter, r *http.Request, requests store.FollowRequestRepo) (*store.FollowRequest, bool) {
+	principal, _ := auth.FromContext(r.Context())
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter, "Invalid follow request ID")
+		return nil, false
+	}
+
+	req, err := requests.ByID(r.Context(), principal.UserID, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.FollowRequestNotFound, "Follow request not found")
+			return nil, false
+		}
+		apierror.InternalError(w, r, err, "Database query error")
+		return nil, false
+	}
+	return req, true
+}
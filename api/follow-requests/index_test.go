@@ -0,0 +1,88 @@
+package followrequests
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+type requestPage struct {
+	Items      []store.FollowRequest `json:"items"`
+	NextCursor *string               `json:"next_cursor"`
+}
+
+func TestApproveAndReject(t *testing.T) {
+	db := apitest.DB(t)
+	owner := apitest.CreateUser(t, db, "owner")
+	approved := apitest.CreateUser(t, db, "approved")
+	rejected := apitest.CreateUser(t, db, "rejected")
+
+	requests := store.NewFollowRequestRepo(db)
+	byRequester := map[string]*store.FollowRequest{}
+	for _, u := range []apitest.User{approved, rejected} {
+		req := &store.FollowRequest{RequesterID: u.ID, TargetID: owner.ID}
+		if _, err := requests.Create(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+		byRequester[u.Username] = req
+	}
+
+	rec := apitest.Do(t, Handler, http.MethodGet, "/api/follow-requests", owner.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: status = %d: %s", rec.Code, rec.Body)
+	}
+	var page requestPage
+	apitest.Decode(t, rec, &page)
+	if len(page.Items) != 2 {
+		t.Fatalf("list: %d requests, want 2", len(page.Items))
+	}
+
+	// Only the owner may decide on a request.
+	target := "/api/follow-requests?id=" + byRequester[approved.Username].ID.String()
+	rec = apitest.Do(t, Handler, http.MethodPost, target, rejected.Token, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("approve as someone else: status = %d, want 404", rec.Code)
+	}
+
+	rec = apitest.Do(t, Handler, http.MethodPost, target, owner.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("approve: status = %d: %s", rec.Code, rec.Body)
+	}
+	following, err := store.NewFollowRepo(db).IsFollowing(context.Background(), approved.ID, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !following {
+		t.Error("approve did not create the follow")
+	}
+	rec = apitest.Do(t, Handler, http.MethodPost, target, owner.Token, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("approve twice: status = %d, want 404", rec.Code)
+	}
+
+	target = "/api/follow-requests?id=" + byRequester[rejected.Username].ID.String()
+	rec = apitest.Do(t, Handler, http.MethodDelete, target, owner.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reject: status = %d: %s", rec.Code, rec.Body)
+	}
+	following, err = store.NewFollowRepo(db).IsFollowing(context.Background(), rejected.ID, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if following {
+		t.Error("reject created a follow")
+	}
+
+	rec = apitest.Do(t, Handler, http.MethodGet, "/api/follow-requests", owner.Token, nil)
+	page = requestPage{}
+	apitest.Decode(t, rec, &page)
+	if len(page.Items) != 0 {
+		t.Errorf("after deciding: %d requests pending, want 0", len(page.Items))
+	}
+}
@@ -2,202 +2,201 @@ package follow
 
 import (
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
 	"net/http"
-	"os"
-	"strings"
-	"sync"
-	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-)
 
-var (
-	db   *gorm.DB
-	once sync.Once
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/notify"
+	"go-common/store"
 )
 
-// Follow struct matches the public.follows table
-type Follow struct {
-	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	FollowerID  uuid.UUID `gorm:"type:uuid;not null" json:"follower_id"`
-	FollowingID uuid.UUID `gorm:"type:uuid;not null" json:"following_id"`
-	CreatedAt   time.Time `json:"created_at"`
-}
-
-func (Follow) TableName() string {
-    return "public.follows"
-}
-
 // FollowRequest defines the structure for incoming follow/unfollow requests
 type FollowRequest struct {
 	FollowingID string `json:"following_id"`
 }
 
-// Connect initializes the database connection
-func Connect() (*gorm.DB, error) {
-	var err error
-	once.Do(func() {
-		if os.Getenv("VERCEL_ENV") == "" {
-			err = godotenv.Load()
-			if err != nil {
-				log.Println("Warning: .env file not found, relying on environment variables")
-			}
-		}
-		dsn := os.Getenv("DIRECT_URL") // Use DIRECT_URL for direct connection
-		if dsn == "" {
-			dsn = os.Getenv("DATABASE_URL") // Fallback to DATABASE_URL if DIRECT_URL is not set
-		}
-		if dsn == "" {
-			log.Fatal("FATAL: Neither DIRECT_URL nor DATABASE_URL environment variable is set")
-		}
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			PrepareStmt: false, // Disable prepared statement caching for serverless environment
-		})
-		if err != nil {
-			log.Fatalf("FATAL: Failed to connect to database: %v", err)
-		}
+// FollowResponse reports the relationship after the call. FollowersCount is
+// the followed user's follower count and FollowingCount the caller's
+// following count. Following a private account makes a request instead
+// (Requested), which the owner approves through /api/follow-requests.
+type FollowResponse struct {
+	Follow         *store.Follow        `json:"follow,omitempty"`
+	Request        *store.FollowRequest `json:"request,omitempty"`
+	FollowingID    uuid.UUID            `json:"following_id"`
+	IsFollowing    bool                 `json:"is_following"`
+	Requested      bool                 `json:"requested"`
+	Changed        bool                 `json:"changed"`
+	FollowersCount int64                `json:"followers_count"`
+	FollowingCount int64                `json:"following_count"`
+}
 
-		sqlDB, err := db.DB()
-		if err != nil {
-			log.Fatalf("FATAL: Failed to get underlying sql.DB: %v", err)
-		}
-		sqlDB.SetMaxIdleConns(1) // Keep very few idle connections
-		sqlDB.SetMaxOpenConns(1) // Limit total open connections
-		sqlDB.SetConnMaxLifetime(time.Minute) // Short lifetime
+// Handler is the entry point for the Vercel serverless function
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-		log.Println("Database connection successful and pool established.")
-	})
-	if err != nil {
-		return nil, err
-	}
-	return db, nil
+	auth.Middleware(route)(w, r)
 }
 
-// GetDB returns the database connection pool
-func GetDB() (*gorm.DB, error) {
-	if db == nil {
-		return Connect()
+func route(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		apierror.NotAllowed(w, r)
+		return
 	}
-	return db, nil
-}
 
-// Handler is the entry point for the Vercel serverless function
-func Handler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	var req FollowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
+		return
+	}
+
+	followingID, err := uuid.Parse(req.FollowingID)
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter, "Invalid following_id")
+		return
+	}
 
-	followerID, err := validateToken(r)
+	db, err := store.GetDB()
 	if err != nil {
-		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		apierror.DatabaseError(w, r, err)
 		return
 	}
 
+	principal, _ := auth.FromContext(r.Context())
+	follow := &store.Follow{FollowerID: principal.UserID, FollowingID: followingID}
+
 	switch r.Method {
 	case http.MethodPost:
-		createFollow(w, r, followerID)
+		createFollow(w, r, db, follow)
 	case http.MethodDelete:
-		deleteFollow(w, r, followerID)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		deleteFollow(w, r, db, follow)
 	}
 }
 
-func createFollow(w http.ResponseWriter, r *http.Request, followerID uuid.UUID) {
-	var req FollowRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+// createFollow follows the target. Following someone again is not an error:
+// it answers 200 with the existing row instead of 201. Following a private
+// account answers 202 with a pending request. Users who share a block cannot
+// follow each other.
+func createFollow(w http.ResponseWriter, r *http.Request, db *gorm.DB, follow *store.Follow) {
+	if follow.FollowerID == follow.FollowingID {
+		apierror.Write(w, r, apierror.CannotFollowSelf, "You cannot follow yourself")
 		return
 	}
 
-	followingID, err := uuid.Parse(req.FollowingID)
+	target, err := store.NewProfileRepo(db).ByID(r.Context(), follow.FollowingID)
 	if err != nil {
-		http.Error(w, "Invalid following_id", http.StatusBadRequest)
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.ProfileNotFound, "User not found")
+			return
+		}
+		apierror.InternalError(w, r, err, "Database query error")
 		return
 	}
 
-	db, err := GetDB()
+	blocked, err := store.NewBlockRepo(db).Between(r.Context(), follow.FollowerID, follow.FollowingID)
 	if err != nil {
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
+		apierror.InternalError(w, r, err, "Database query error")
 		return
 	}
-
-	follow := Follow{FollowerID: followerID, FollowingID: followingID}
-	if err := db.Create(&follow).Error; err != nil {
-		http.Error(w, "Failed to create follow relationship", http.StatusInternalServerError)
+	if blocked {
+		apierror.Write(w, r, apierror.Blocked, "You cannot follow this user")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(follow)
-}
-
-func deleteFollow(w http.ResponseWriter, r *http.Request, followerID uuid.UUID) {
-	var req FollowRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	follows := store.NewFollowRepo(db)
+	if target.IsPrivate {
+		following, err := follows.IsFollowing(r.Context(), follow.FollowerID, follow.FollowingID)
+		if err != nil {
+			apierror.InternalError(w, r, err, "Database query error")
+			return
+		}
+		if !following {
+			requestFollow(w, r, db, follows, follow)
+			return
+		}
 	}
 
-	followingID, err := uuid.Parse(req.FollowingID)
+	created, err := follows.Create(r.Context(), follow)
 	if err != nil {
-		http.Error(w, "Invalid following_id", http.StatusBadRequest)
+		apierror.InternalError(w, r, err, "Failed to create follow relationship")
 		return
 	}
 
-	db, err := GetDB()
-	if err != nil {
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		return
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		notify.New(db).Followed(r.Context(), follow.FollowerID, follow.FollowingID)
 	}
+	writeState(w, r, follows, follow, true, created, status)
+}
 
-	if err := db.Where("follower_id = ? AND following_id = ?", followerID, followingID).Delete(&Follow{}).Error; err != nil {
-		http.Error(w, "Failed to delete follow relationship", http.StatusInternalServerError)
+// requestFollow asks a private account to approve a follow. Asking again
+// answers 200 with the existing request instead of 202.
+func requestFollow(w http.ResponseWriter, r *http.Request, db *gorm.DB, follows store.FollowRepo, follow *store.Follow) {
+	req := &store.FollowRequest{RequesterID: follow.FollowerID, TargetID: follow.FollowingID}
+	created, err := store.NewFollowRequestRepo(db).Create(r.Context(), req)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to request follow")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Successfully unfollowed"})
+	status := http.StatusOK
+	if created {
+		status = http.StatusAccepted
+		notify.New(db).FollowRequested(r.Context(), req.RequesterID, req.TargetID)
+	}
+	writeRequested(w, r, follows, follow, req, created, status)
 }
 
-func validateToken(r *http.Request) (uuid.UUID, error) {
-	jwtSecret := os.Getenv("SUPABASE_JWT_SECRET")
-	if jwtSecret == "" {
-		return uuid.Nil, fmt.Errorf("server configuration error")
+// deleteFollow unfollows the target, or cancels a pending request to follow
+// it. Unfollowing someone you do not follow answers 200 with
+// "changed": false.
+func deleteFollow(w http.ResponseWriter, r *http.Request, db *gorm.DB, follow *store.Follow) {
+	follows := store.NewFollowRepo(db)
+	removed, err := follows.Delete(r.Context(), follow.FollowerID, follow.FollowingID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to delete follow relationship")
+		return
 	}
+	cancelled, err := store.NewFollowRequestRepo(db).Delete(r.Context(), follow.FollowerID, follow.FollowingID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to cancel follow request")
+		return
+	}
+	writeState(w, r, follows, follow, false, removed || cancelled, http.StatusOK)
+}
 
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return uuid.Nil, fmt.Errorf("missing Authorization header")
+func writeState(w http.ResponseWriter, r *http.Request, follows store.FollowRepo, follow *store.Follow, following, changed bool, status int) {
+	resp := FollowResponse{FollowingID: follow.FollowingID, IsFollowing: following, Changed: changed}
+	if following {
+		resp.Follow = follow
 	}
+	writeResponse(w, r, follows, follow, resp, status)
+}
 
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(jwtSecret), nil
-	})
+func writeRequested(w http.ResponseWriter, r *http.Request, follows store.FollowRepo, follow *store.Follow, req *store.FollowRequest, changed bool, status int) {
+	resp := FollowResponse{FollowingID: follow.FollowingID, Request: req, Requested: true, Changed: changed}
+	writeResponse(w, r, follows, follow, resp, status)
+}
 
+// writeResponse fills in the counts and sends resp.
+func writeResponse(w http.ResponseWriter, r *http.Request, follows store.FollowRepo, follow *store.Follow, resp FollowResponse, status int) {
+	target, err := follows.Counts(r.Context(), follow.FollowingID)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid token: %w", err)
+		apierror.InternalError(w, r, err, "Failed to count follows")
+		return
 	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		sub, ok := claims["sub"].(string)
-		if !ok {
-			return uuid.Nil, fmt.Errorf("invalid token claims: 'sub' is missing or not a string")
-		}
-		userID, err := uuid.Parse(sub)
-		if err != nil {
-			return uuid.Nil, fmt.Errorf("invalid user ID in token")
-		}
-		return userID, nil
+	caller, err := follows.Counts(r.Context(), follow.FollowerID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to count follows")
+		return
 	}
+	resp.FollowersCount = target.Followers
+	resp.FollowingCount = caller.Following
 
-	return uuid.Nil, fmt.Errorf("invalid token")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
 }
@@ -0,0 +1,171 @@
+package follow
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+// Following or unfollowing twice leaves the same single relationship (or
+// none) as doing it once, and the repeat reports that nothing changed.
+func TestFollowIdempotency(t *testing.T) {
+	db := apitest.DB(t)
+	follower := apitest.CreateUser(t, db, "follower")
+	followed := apitest.CreateUser(t, db, "followed")
+	body := FollowRequest{FollowingID: followed.ID.String()}
+
+	var firstID uuid.UUID
+	steps := []struct {
+		name        string
+		method      string
+		want        int
+		wantChanged bool
+		wantRows    int64
+	}{
+		{"follow", http.MethodPost, http.StatusCreated, true, 1},
+		{"follow again", http.MethodPost, http.StatusOK, false, 1},
+		{"unfollow", http.MethodDelete, http.StatusOK, true, 0},
+		{"unfollow again", http.MethodDelete, http.StatusOK, false, 0},
+		{"follow after unfollowing", http.MethodPost, http.StatusCreated, true, 1},
+	}
+	for i, step := range steps {
+		rec := apitest.Do(t, Handler, step.method, "/api/follow", follower.Token, body)
+		if rec.Code != step.want {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, rec.Code, step.want, rec.Body)
+		}
+		var resp FollowResponse
+		apitest.Decode(t, rec, &resp)
+		if resp.Changed != step.wantChanged {
+			t.Errorf("%s: changed = %v, want %v", step.name, resp.Changed, step.wantChanged)
+		}
+		if resp.FollowersCount != step.wantRows || resp.FollowingCount != step.wantRows {
+			t.Errorf("%s: counts = %d/%d, want %d", step.name, resp.FollowersCount, resp.FollowingCount, step.wantRows)
+		}
+		// The repeated follow returns the row the first one created.
+		switch i {
+		case 0:
+			if resp.Follow == nil {
+				t.Fatalf("%s: no follow in response", step.name)
+			}
+			firstID = resp.Follow.ID
+		case 1:
+			if resp.Follow == nil || resp.Follow.ID != firstID {
+				t.Errorf("%s: returned follow %+v, want id %s", step.name, resp.Follow, firstID)
+			}
+		}
+
+		var rows int64
+		err := db.Model(&store.Follow{}).
+			Where("follower_id = ? AND following_id = ?", follower.ID, followed.ID).
+			Count(&rows).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows != step.wantRows {
+			t.Fatalf("%s: %d follow rows, want %d", step.name, rows, step.wantRows)
+		}
+	}
+}
+
+func TestFollowValidation(t *testing.T) {
+	db := apitest.DB(t)
+	user := apitest.CreateUser(t, db, "validator")
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		body   any
+		want   int
+	}{
+		{"anonymous follow", http.MethodPost, "", FollowRequest{FollowingID: user.ID.String()}, http.StatusUnauthorized},
+		{"invalid following_id", http.MethodPost, user.Token, FollowRequest{FollowingID: "nope"}, http.StatusBadRequest},
+		{"self-follow", http.MethodPost, user.Token, FollowRequest{FollowingID: user.ID.String()}, http.StatusUnprocessableEntity},
+		{"unknown user", http.MethodPost, user.Token, FollowRequest{FollowingID: uuid.NewString()}, http.StatusNotFound},
+		{"invalid unfollow id", http.MethodDelete, user.Token, FollowRequest{FollowingID: "nope"}, http.StatusBadRequest},
+		{"wrong method", http.MethodGet, user.Token, nil, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, tt.method, "/api/follow", tt.token, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+// Following a private account files a request instead, and unfollowing
+// cancels it.
+func TestFollowPrivateAccount(t *testing.T) {
+	db := apitest.DB(t)
+	requester := apitest.CreateUser(t, db, "requester")
+	private := apitest.CreateUser(t, db, "private")
+	if err := db.Model(&store.Profile{}).Where("id = ?", private.ID).Update("is_private", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	body := FollowRequest{FollowingID: private.ID.String()}
+
+	steps := []struct {
+		name          string
+		method        string
+		want          int
+		wantRequested bool
+		wantChanged   bool
+		wantPending   bool
+	}{
+		{"request", http.MethodPost, http.StatusAccepted, true, true, true},
+		{"request again", http.MethodPost, http.StatusOK, true, false, true},
+		{"cancel", http.MethodDelete, http.StatusOK, false, true, false},
+	}
+	for _, step := range steps {
+		rec := apitest.Do(t, Handler, step.method, "/api/follow", requester.Token, body)
+		if rec.Code != step.want {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, rec.Code, step.want, rec.Body)
+		}
+		var resp FollowResponse
+		apitest.Decode(t, rec, &resp)
+		if resp.IsFollowing || resp.FollowersCount != 0 {
+			t.Errorf("%s: following = %v with %d followers, want no follow", step.name, resp.IsFollowing, resp.FollowersCount)
+		}
+		if resp.Requested != step.wantRequested || resp.Changed != step.wantChanged {
+			t.Errorf("%s: requested/changed = %v/%v, want %v/%v",
+				step.name, resp.Requested, resp.Changed, step.wantRequested, step.wantChanged)
+		}
+
+		pending, err := store.NewFollowRequestRepo(db).Exists(context.Background(), requester.ID, private.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pending != step.wantPending {
+			t.Fatalf("%s: pending = %v, want %v", step.name, pending, step.wantPending)
+		}
+	}
+}
+
+// Users who share a block cannot follow each other, in either direction.
+func TestFollowBlocked(t *testing.T) {
+	db := apitest.DB(t)
+	blocker := apitest.CreateUser(t, db, "blocker")
+	blocked := apitest.CreateUser(t, db, "blocked")
+	block := &store.Block{BlockerID: blocker.ID, BlockedID: blocked.ID}
+	if _, err := store.NewBlockRepo(db).Create(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []struct{ from, to apitest.User }{{blocker, blocked}, {blocked, blocker}} {
+		body := FollowRequest{FollowingID: u.to.ID.String()}
+		rec := apitest.Do(t, Handler, http.MethodPost, "/api/follow", u.from.Token, body)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s follows %s: status = %d, want 403", u.from.Username, u.to.Username, rec.Code)
+		}
+	}
+}
@@ -2,130 +2,108 @@ package followers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
-	"os"
-	"sync"
-	"time"
 
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-)
 
-var (
-	db   *gorm.DB
-	once sync.Once
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/store"
 )
 
-// Profile struct matches the public.profiles table
-type Profile struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	Username  string    `gorm:"unique;not null" json:"username"`
-	FullName  string    `json:"full_name"`
-	AvatarURL string    `json:"avatar_url"`
-}
-
-func (Profile) TableName() string {
-	return "public.profiles"
+// ListResponse is one page of a followers or following list together with
+// the length of the whole list.
+type ListResponse struct {
+	store.PageResult[store.FollowListEntry]
+	Total int64 `json:"total"`
 }
 
-// Follow struct matches the public.follows table
-type Follow struct {
-	FollowerID uuid.UUID `gorm:"type:uuid;not null" json:"follower_id"`
-}
-
-func (Follow) TableName() string {
-	return "public.follows"
-}
-
-// Connect initializes the database connection
-func Connect() (*gorm.DB, error) {
-	var err error
-	once.Do(func() {
-		if os.Getenv("VERCEL_ENV") == "" {
-			err = godotenv.Load()
-			if err != nil {
-				log.Println("Warning: .env file not found, relying on environment variables")
-			}
-		}
-		dsn := os.Getenv("DIRECT_URL") // Use DIRECT_URL for direct connection
-		if dsn == "" {
-			dsn = os.Getenv("DATABASE_URL") // Fallback to DATABASE_URL if DIRECT_URL is not set
-		}
-		if dsn == "" {
-			log.Fatal("FATAL: Neither DIRECT_URL nor DATABASE_URL environment variable is set")
-		}
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			PrepareStmt: false, // Disable prepared statement caching for serverless environment
-		})
-		if err != nil {
-			log.Fatalf("FATAL: Failed to connect to database: %v", err)
-		}
+// Handler is the entry point for the /api/followers serverless function. It
+// serves GET /api/followers?user_id=...[&direction=following&limit=...&cursor=...]:
+// the user's followers, or with direction=following the users they follow,
+// most recently followed first. Authentication is optional; when present,
+// each entry says whether the caller follows it and is followed by it.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-		sqlDB, err := db.DB()
-		if err != nil {
-			log.Fatalf("FATAL: Failed to get underlying sql.DB: %v", err)
-		}
-		sqlDB.SetMaxIdleConns(1)              // Keep very few idle connections
-		sqlDB.SetMaxOpenConns(1)              // Limit total open connections
-		sqlDB.SetConnMaxLifetime(time.Minute) // Short lifetime
+	if r.Method != http.MethodGet {
+		apierror.NotAllowed(w, r)
+		return
+	}
 
-		log.Println("Database connection successful and pool established.")
-	})
+	query := r.URL.Query()
+	userID, err := uuid.Parse(query.Get("user_id"))
 	if err != nil {
-		return nil, err
+		apierror.Write(w, r, apierror.InvalidParameter, "A valid user_id query parameter is required")
+		return
 	}
-	return db, nil
-}
 
-// GetDB returns the database connection pool
-func GetDB() (*gorm.DB, error) {
-	if db == nil {
-		return Connect()
+	dir := store.FollowDirection(query.Get("direction"))
+	if dir == "" {
+		dir = store.Followers
+	}
+	if dir != store.Followers && dir != store.Following {
+		apierror.Write(w, r, apierror.InvalidParameter, "Direction must be 'followers' or 'following'")
+		return
 	}
-	return db, nil
-}
-
-// Handler is the entry point for the Vercel serverless function
-func Handler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	page, err := store.ParsePage(query.Get("limit"), query.Get("cursor"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidCursor, "Invalid cursor")
 		return
 	}
 
-	userIDStr := r.URL.Query().Get("user_id")
-	if userIDStr == "" {
-		http.Error(w, "user_id query parameter is required", http.StatusBadRequest)
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
+	owner, err := store.NewProfileRepo(db).ByID(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "Invalid user_id format", http.StatusBadRequest)
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.ProfileNotFound, "User not found")
+			return
+		}
+		apierror.InternalError(w, r, err, "Database query error")
 		return
 	}
 
-	db, err := GetDB()
+	// Anyone may list a public account's follows; a private account's are
+	// shown only to its approved followers.
+	var viewerID uuid.UUID
+	if principal, err := auth.Authenticate(r); err == nil {
+		viewerID = principal.UserID
+	}
+	follows := store.NewFollowRepo(db)
+	visible, err := follows.CanView(r.Context(), viewerID, owner)
 	if err != nil {
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
+		apierror.InternalError(w, r, err, "Database query error")
+		return
+	}
+	if !visible {
+		apierror.Write(w, r, apierror.PrivateAccount, "This account is private")
 		return
 	}
 
-	var followerIDs []uuid.UUID
-	db.Model(&Follow{}).Where("following_id = ?", userID).Pluck("follower_id", &followerIDs)
+	entries, err := follows.List(r.Context(), userID, viewerID, dir, page)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch "+string(dir))
+		return
+	}
+	counts, err := follows.Counts(r.Context(), userID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to count follows")
+		return
+	}
 
-	var followers []Profile
-	if len(followerIDs) > 0 {
-		if err := db.Where("id IN ?", followerIDs).Find(&followers).Error; err != nil {
-			http.Error(w, "Failed to fetch follower profiles", http.StatusInternalServerError)
-			return
-		}
+	resp := ListResponse{PageResult: store.NewPageResult(entries, page, store.FollowListCursor)}
+	resp.Total = counts.Followers
+	if dir == store.Following {
+		resp.Total = counts.Following
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(followers)
+	json.NewEncoder(w).Encode(resp)
 }
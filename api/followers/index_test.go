@@ -0,0 +1,88 @@
+package followers
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+// The list is paged most recently followed first, reports the full total and
+// annotates each entry for the caller.
+func TestListFollowers(t *testing.T) {
+	db := apitest.DB(t)
+	owner := apitest.CreateUser(t, db, "owner")
+	viewer := apitest.CreateUser(t, db, "viewer")
+	var want []uuid.UUID
+	for _, name := range []string{"first", "second", "third"} {
+		u := apitest.CreateUser(t, db, name)
+		apitest.Follow(t, db, u.ID, owner.ID)
+		want = append([]uuid.UUID{u.ID}, want...)
+	}
+	// The viewer follows the newest follower, who follows the viewer back.
+	apitest.Follow(t, db, viewer.ID, want[0])
+	apitest.Follow(t, db, want[0], viewer.ID)
+
+	var got []uuid.UUID
+	target := "/api/followers?limit=2&user_id=" + owner.ID.String()
+	for target != "" {
+		rec := apitest.Do(t, Handler, http.MethodGet, target, viewer.Token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var resp ListResponse
+		apitest.Decode(t, rec, &resp)
+		if resp.Total != 3 {
+			t.Errorf("total = %d, want 3", resp.Total)
+		}
+		for _, e := range resp.Items {
+			got = append(got, e.ID)
+			newest := e.ID == want[0]
+			if e.FollowedByMe != newest || e.FollowsMe != newest {
+				t.Errorf("%s: followed_by_me/follows_me = %v/%v, want %v",
+					e.Username, e.FollowedByMe, e.FollowsMe, newest)
+			}
+		}
+		target = ""
+		if resp.NextCursor != nil {
+			target = "/api/followers?limit=2&user_id=" + owner.ID.String() + "&cursor=" + url.QueryEscape(*resp.NextCursor)
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d followers, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("follower %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestListFollowing(t *testing.T) {
+	db := apitest.DB(t)
+	owner := apitest.CreateUser(t, db, "owner")
+	followed := apitest.CreateUser(t, db, "followed")
+	apitest.Follow(t, db, owner.ID, followed.ID)
+
+	rec := apitest.Do(t, Handler, http.MethodGet, "/api/followers?direction=following&user_id="+owner.ID.String(), "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp ListResponse
+	apitest.Decode(t, rec, &resp)
+	if resp.Total != 1 || len(resp.Items) != 1 || resp.Items[0].ID != followed.ID {
+		t.Errorf("following = %+v, want only %s", resp, followed.Username)
+	}
+
+	rec = apitest.Do(t, Handler, http.MethodGet, "/api/followers?direction=sideways&user_id="+owner.ID.String(), "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad direction: status = %d, want 400", rec.Code)
+	}
+}
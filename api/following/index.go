@@ -1,131 +0,0 @@
-package following
-
-import (
-	"encoding/json"
-	"log"
-	"net/http"
-	"os"
-	"sync"
-	"time"
-
-	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-)
-
-var (
-	db   *gorm.DB
-	once sync.Once
-)
-
-// Profile struct matches the public.profiles table
-type Profile struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	Username  string    `gorm:"unique;not null" json:"username"`
-	FullName  string    `json:"full_name"`
-	AvatarURL string    `json:"avatar_url"`
-}
-
-func (Profile) TableName() string {
-	return "public.profiles"
-}
-
-// Follow struct matches the public.follows table
-type Follow struct {
-	FollowingID uuid.UUID `gorm:"type:uuid;not null" json:"following_id"`
-}
-
-func (Follow) TableName() string {
-	return "public.follows"
-}
-
-// Connect initializes the database connection
-func Connect() (*gorm.DB, error) {
-	var err error
-	once.Do(func() {
-		if os.Getenv("VERCEL_ENV") == "" {
-			err = godotenv.Load()
-			if err != nil {
-				log.Println("Warning: .env file not found, relying on environment variables")
-			}
-		}
-		dsn := os.Getenv("DIRECT_URL") // Use DIRECT_URL for direct connection
-		if dsn == "" {
-			dsn = os.Getenv("DATABASE_URL") // Fallback to DATABASE_URL if DIRECT_URL is not set
-		}
-		if dsn == "" {
-			log.Fatal("FATAL: Neither DIRECT_URL nor DATABASE_URL environment variable is set")
-		}
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			PrepareStmt: false, // Disable prepared statement caching for serverless environment
-		})
-		if err != nil {
-			log.Fatalf("FATAL: Failed to connect to database: %v", err)
-		}
-
-		sqlDB, err := db.DB()
-		if err != nil {
-			log.Fatalf("FATAL: Failed to get underlying sql.DB: %v", err)
-		}
-		sqlDB.SetMaxIdleConns(1)              // Keep very few idle connections
-		sqlDB.SetMaxOpenConns(1)              // Limit total open connections
-		sqlDB.SetConnMaxLifetime(time.Minute) // Short lifetime
-
-		log.Println("Database connection successful and pool established.")
-	})
-	if err != nil {
-		return nil, err
-	}
-	return db, nil
-}
-
-// GetDB returns the database connection pool
-func GetDB() (*gorm.DB, error) {
-	if db == nil {
-		return Connect()
-	}
-	return db, nil
-}
-
-// Handler is the entry point for the Vercel serverless function
-func Handler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	userIDStr := r.URL.Query().Get("user_id")
-	if userIDStr == "" {
-		http.Error(w, "user_id query parameter is required", http.StatusBadRequest)
-		return
-	}
-
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		http.Error(w, "Invalid user_id format", http.StatusBadRequest)
-		return
-	}
-
-	db, err := GetDB()
-	if err != nil {
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		return
-	}
-
-	var followingIDs []uuid.UUID
-	db.Model(&Follow{}).Where("follower_id = ?", userID).Pluck("following_id", &followingIDs)
-
-	var following []Profile
-	if len(followingIDs) > 0 {
-		if err := db.Where("id IN ?", followingIDs).Find(&following).Error; err != nil {
-			http.Error(w, "Failed to fetch following profiles", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(following)
-}
@@ -0,0 +1,199 @@
+package likes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/notify"
+	"go-common/store"
+)
+
+// LikeRequest names the post or the comment to like or unlike. Exactly one of
+// the two fields must be set.
+type LikeRequest struct {
+	PostID    *string `json:"post_id"`
+	CommentID *string `json:"comment_id"`
+}
+
+// LikeResponse reports the state of the target after the call.
+type LikeResponse struct {
+	PostID    *uuid.UUID `json:"post_id,omitempty"`
+	CommentID *uuid.UUID `json:"comment_id,omitempty"`
+	LikedByMe bool       `json:"liked_by_me"`
+	LikeCount int64      `json:"like_count"`
+	Changed   bool       `json:"changed"`
+}
+
+// Handler is the entry point for the /api/likes serverless function.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		apierror.NotAllowed(w, r)
+		return
+	}
+
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	like, ok := decodeTarget(w, r)
+	if !ok {
+		return
+	}
+	like.UserID = principal.UserID
+
+	switch r.Method {
+	case http.MethodPost:
+		createLike(w, r, db, like)
+	case http.MethodDelete:
+		deleteLike(w, r, db, like)
+	}
+}
+
+// createLike likes the target. A repeat like is not an error: it answers 200
+// with the current state instead of 201.
+func createLike(w http.ResponseWriter, r *http.Request, db *gorm.DB, like *store.Like) {
+	if !targetExists(w, r, db, like) {
+		return
+	}
+
+	likes := store.NewLikeRepo(db)
+	created, err := likes.Like(r.Context(), like)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to like")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		notify.New(db).Liked(r.Context(), like)
+	}
+	writeState(w, r, likes, like, true, created, status)
+}
+
+// deleteLike removes the caller's like. Unliking something that was not liked
+// answers 200 with "changed": false.
+func deleteLike(w http.ResponseWriter, r *http.Request, db *gorm.DB, like *store.Like) {
+	likes := store.NewLikeRepo(db)
+	removed, err := likes.Unlike(r.Context(), like)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to unlike")
+		return
+	}
+	writeState(w, r, likes, like, false, removed, http.StatusOK)
+}
+
+func writeState(w http.ResponseWriter, r *http.Request, likes store.LikeRepo, like *store.Like, liked, changed bool, status int) {
+	count, err := likes.Count(r.Context(), like)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to count likes")
+		return
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(LikeResponse{
+		PostID:    like.PostID,
+		CommentID: like.CommentID,
+		LikedByMe: liked,
+		LikeCount: count,
+		Changed:   changed,
+	})
+}
+
+func decodeTarget(w http.ResponseWriter, r *http.Request) (*store.Like, bool) {
+	var req LikeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
+		return nil, false
+	}
+
+	if (req.PostID == nil) == (req.CommentID == nil) {
+		apierror.Write(w, r, apierror.ValidationFailed, "Exactly one of post_id and comment_id is required")
+		return nil, false
+	}
+
+	like := &store.Like{}
+	if req.PostID != nil {
+		id, err := uuid.Parse(*req.PostID)
+		if err != nil {
+			apierror.Write(w, r, apierror.InvalidParameter, "Invalid post_id")
+			return nil, false
+		}
+		like.PostID = &id
+	} else {
+		id, err := uuid.Parse(*req.CommentID)
+		if err != nil {
+			apierror.Write(w, r, apierror.InvalidParameter, "Invalid comment_id")
+			return nil, false
+		}
+		like.CommentID = &id
+	}
+	return like, true
+}
+
+// targetExists checks that the liked post, or the post of the liked comment,
+// exists and that the caller may see it. Posts of private accounts the caller
+// does not follow, and of accounts on either side of a block, are reported as
+// not found. It writes the error response and returns false otherwise.
+func targetExists(w http.ResponseWriter, r *http.Request, db *gorm.DB, like *store.Like) bool {
+	postID := like.PostID
+	if like.CommentID != nil {
+		comment, err := store.NewCommentRepo(db).ByID(r.Context(), *like.CommentID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				apierror.Write(w, r, apierror.CommentNotFound, "Comment not found")
+				return false
+			}
+			apierror.InternalError(w, r, err, "Database query error")
+			return false
+		}
+		postID = &comment.PostID
+	}
+
+	post, err := store.NewPostRepo(db).ByID(r.Context(), *postID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.PostNotFound, "Post not found")
+			return false
+		}
+		apierror.InternalError(w, r, err, "Database query error")
+		return false
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	visible, err := store.NewFollowRepo(db).CanView(r.Context(), principal.UserID, &post.User)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Database query error")
+		return false
+	}
+	if !visible {
+		apierror.Write(w, r, apierror.PostNotFound, "Post not found")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package likes
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+// Liking or unliking twice leaves the same single like (or none) as doing it
+// once, and the repeat reports that nothing changed.
+func TestLikeIdempotency(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	fan := apitest.CreateUser(t, db, "fan")
+	post := apitest.CreatePost(t, db, author.ID, "likeable")
+	comment := store.Comment{ID: uuid.New(), PostID: post.ID, UserID: author.ID, Content: "also likeable"}
+	if err := store.NewCommentRepo(db).Create(context.Background(), &comment); err != nil {
+		t.Fatal(err)
+	}
+	postID, commentID := post.ID.String(), comment.ID.String()
+
+	targets := []struct {
+		name   string
+		body   LikeRequest
+		column string
+		id     uuid.UUID
+	}{
+		{"post", LikeRequest{PostID: &postID}, "post_id", post.ID},
+		{"comment", LikeRequest{CommentID: &commentID}, "comment_id", comment.ID},
+	}
+	steps := []struct {
+		name        string
+		method      string
+		want        int
+		wantLiked   bool
+		wantChanged bool
+		wantCount   int64
+	}{
+		{"like", http.MethodPost, http.StatusCreated, true, true, 1},
+		{"like again", http.MethodPost, http.StatusOK, true, false, 1},
+		{"unlike", http.MethodDelete, http.StatusOK, false, true, 0},
+		{"unlike again", http.MethodDelete, http.StatusOK, false, false, 0},
+	}
+	for _, target := range targets {
+		t.Run(target.name, func(t *testing.T) {
+			for _, step := range steps {
+				rec := apitest.Do(t, Handler, step.method, "/api/likes", fan.Token, target.body)
+				if rec.Code != step.want {
+					t.Fatalf("%s: status = %d, want %d: %s", step.name, rec.Code, step.want, rec.Body)
+				}
+				var resp LikeResponse
+				apitest.Decode(t, rec, &resp)
+				if resp.LikedByMe != step.wantLiked || resp.Changed != step.wantChanged || resp.LikeCount != step.wantCount {
+					t.Errorf("%s: liked/changed/count = %v/%v/%d, want %v/%v/%d", step.name,
+						resp.LikedByMe, resp.Changed, resp.LikeCount, step.wantLiked, step.wantChanged, step.wantCount)
+				}
+
+				var rows int64
+				err := db.Model(&store.Like{}).
+					Where("user_id = ? AND "+target.column+" = ?", fan.ID, target.id).
+					Count(&rows).Error
+				if err != nil {
+					t.Fatal(err)
+				}
+				if rows != step.wantCount {
+					t.Fatalf("%s: %d like rows, want %d", step.name, rows, step.wantCount)
+				}
+			}
+		})
+	}
+}
+
+// Only the first like of a post notifies its author.
+func TestLikeNotifiesOnce(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	fan := apitest.CreateUser(t, db, "fan")
+	post := apitest.CreatePost(t, db, author.ID, "likeable")
+	postID := post.ID.String()
+
+	for i := 0; i < 2; i++ {
+		if rec := apitest.Do(t, Handler, http.MethodPost, "/api/likes", fan.Token, LikeRequest{PostID: &postID}); rec.Code >= 300 {
+			t.Fatalf("like %d: status = %d: %s", i+1, rec.Code, rec.Body)
+		}
+	}
+
+	var notifications []store.Notification
+	err := db.Where("recipient_id = ? AND group_key = ?", author.ID, "like:post:"+post.ID.String()).
+		Find(&notifications).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || notifications[0].ActorCount != 1 {
+		t.Errorf("notifications = %+v, want one with one actor", notifications)
+	}
+}
+
+func TestLikeValidation(t *testing.T) {
+	db := apitest.DB(t)
+	user := apitest.CreateUser(t, db, "validator")
+	post := apitest.CreatePost(t, db, user.ID, "a post")
+	postID, unknown, invalid := post.ID.String(), uuid.NewString(), "nope"
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		body   any
+		want   int
+	}{
+		{"no target", http.MethodPost, user.Token, LikeRequest{}, http.StatusBadRequest},
+		{"both targets", http.MethodPost, user.Token, LikeRequest{PostID: &postID, CommentID: &postID}, http.StatusBadRequest},
+		{"invalid post_id", http.MethodPost, user.Token, LikeRequest{PostID: &invalid}, http.StatusBadRequest},
+		{"unknown post", http.MethodPost, user.Token, LikeRequest{PostID: &unknown}, http.StatusNotFound},
+		{"unknown comment", http.MethodPost, user.Token, LikeRequest{CommentID: &unknown}, http.StatusNotFound},
+		{"anonymous", http.MethodPost, "", LikeRequest{PostID: &postID}, http.StatusUnauthorized},
+		{"wrong method", http.MethodGet, user.Token, nil, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, tt.method, "/api/likes", tt.token, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+// Posts of private accounts, and their comments, can only be liked by
+// approved followers, and neither side of a block can like the other's.
+func TestLikeHiddenPosts(t *testing.T) {
+	db := apitest.DB(t)
+	private := apitest.CreateUser(t, db, "private")
+	follower := apitest.CreateUser(t, db, "follower")
+	stranger := apitest.CreateUser(t, db, "stranger")
+	blocker := apitest.CreateUser(t, db, "blocker")
+	if err := db.Model(&store.Profile{}).Where("id = ?", private.ID).Update("is_private", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	apitest.Follow(t, db, follower.ID, private.ID)
+	block := &store.Block{BlockerID: blocker.ID, BlockedID: stranger.ID}
+	if _, err := store.NewBlockRepo(db).Create(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+	privatePost := apitest.CreatePost(t, db, private.ID, "followers only")
+	blockerPost := apitest.CreatePost(t, db, blocker.ID, "not for stranger")
+	comment := store.Comment{ID: uuid.New(), PostID: privatePost.ID, UserID: private.ID, Content: "also followers only"}
+	if err := store.NewCommentRepo(db).Create(context.Background(), &comment); err != nil {
+		t.Fatal(err)
+	}
+	privateID, blockerID, commentID := privatePost.ID.String(), blockerPost.ID.String(), comment.ID.String()
+
+	tests := []struct {
+		name string
+		user apitest.User
+		body LikeRequest
+		want int
+	}{
+		{"follower likes post", follower, LikeRequest{PostID: &privateID}, http.StatusCreated},
+		{"follower likes comment", follower, LikeRequest{CommentID: &commentID}, http.StatusCreated},
+		{"stranger likes post", stranger, LikeRequest{PostID: &privateID}, http.StatusNotFound},
+		{"stranger likes comment", stranger, LikeRequest{CommentID: &commentID}, http.StatusNotFound},
+		{"blocked likes post", stranger, LikeRequest{PostID: &blockerID}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, http.MethodPost, "/api/likes", tt.user.Token, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package messages
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/store"
+)
+
+// MaxParticipants caps the size of a group conversation, creator included.
+const MaxParticipants = 50
+
+// StartConversationRequest is the body of POST /api/messages. A single other
+// participant starts (or reopens) a 1:1 conversation; more make a group.
+type StartConversationRequest struct {
+	ParticipantIDs []string `json:"participant_ids"`
+	Title          *string  `json:"title"`
+}
+
+// SendMessageRequest is the body of POST /api/messages?conversation_id=...
+type SendMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// MarkReadRequest is the optional body of PUT /api/messages?conversation_id=...
+// Without a MessageID the whole conversation is marked read.
+type MarkReadRequest struct {
+	MessageID *string `json:"message_id"`
+}
+
+// ReadReceipt is returned after marking a conversation read.
+type ReadReceipt struct {
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	LastReadAt     *time.Time `json:"last_read_at"`
+}
+
+// Handler is the entry point for the /api/messages serverless function.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+	messages := store.NewMessageRepo(db)
+	blocks := store.NewBlockRepo(db)
+	inConversation := r.URL.Query().Get("conversation_id") != ""
+
+	switch {
+	case r.Method == http.MethodGet && inConversation:
+		listMessages(w, r, messages)
+	case r.Method == http.MethodGet:
+		listConversations(w, r, messages)
+	case r.Method == http.MethodPost && inConversation:
+		sendMessage(w, r, messages, blocks)
+	case r.Method == http.MethodPost:
+		startConversation(w, r, messages, blocks)
+	case r.Method == http.MethodPut && inConversation:
+		markRead(w, r, messages)
+	case r.Method == http.MethodPut:
+		apierror.Write(w, r, apierror.InvalidParameter, "conversation_id is required")
+	default:
+		apierror.NotAllowed(w, r)
+	}
+}
+
+// listConversations serves GET /api/messages[?limit=...&cursor=...].
+func listConversations(w http.ResponseWriter, r *http.Request, messages store.MessageRepo) {
+	principal, _ := auth.FromContext(r.Context())
+
+	page, err := store.ParsePage(r.URL.Query().Get("limit"), r.URL.Query().Get("cursor"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidCursor, "Invalid cursor")
+		return
+	}
+
+	convs, err := messages.Conversations(r.Context(), principal.UserID, page)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch conversations")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(store.NewPageResult(convs, page, store.ConversationCursor))
+}
+
+// listMessages serves GET /api/messages?conversation_id=...[&limit=...&cursor=...],
+// newest message first.
+func listMessages(w http.ResponseWriter, r *http.Request, messages store.MessageRepo) {
+	conv, ok := participantConversation(w, r, messages)
+	if !ok {
+		return
+	}
+
+	page, err := store.ParsePage(r.URL.Query().Get("limit"), r.URL.Query().Get("cursor"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidCursor, "Invalid cursor")
+		return
+	}
+
+	list, err := messages.Messages(r.Context(), conv, page)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch messages")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(store.NewPageResult(list, page, store.MessageCursor))
+}
+
+// startConversation serves POST /api/messages. Nobody can start a
+// conversation with someone they blocked or who blocked them.
+func startConversation(w http.ResponseWriter, r *http.Request, messages store.MessageRepo, blocks store.BlockRepo) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var req StartConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
+		return
+	}
+
+	others := map[uuid.UUID]bool{}
+	var participantIDs []uuid.UUID
+	for _, s := range req.ParticipantIDs {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			apierror.Write(w, r, apierror.InvalidParameter, "Invalid participant ID format")
+			return
+		}
+		if id == principal.UserID || others[id] {
+			continue
+		}
+		others[id] = true
+		participantIDs = append(participantIDs, id)
+	}
+
+	if len(participantIDs) == 0 {
+		apierror.Write(w, r, apierror.ValidationFailed, "At least one other participant is required")
+		return
+	}
+	if len(participantIDs)+1 > MaxParticipants {
+		apierror.Write(w, r, apierror.ValidationFailed, "Too many participants")
+		return
+	}
+
+	blocked, err := blockedWithAny(r, blocks, principal.UserID, participantIDs)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Database query error")
+		return
+	}
+	if blocked {
+		apierror.Write(w, r, apierror.Blocked, "You cannot message this user")
+		return
+	}
+
+	creator := principal.UserID
+	conv := &store.Conversation{CreatedBy: &creator, IsGroup: len(participantIDs) > 1}
+	if conv.IsGroup {
+		conv.Title = req.Title
+	} else {
+		key := store.DirectKey(principal.UserID, participantIDs[0])
+		conv.DirectKey = &key
+	}
+
+	created, err := messages.Start(r.Context(), conv, participantIDs)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.ProfileNotFound, "One or more participants not found")
+			return
+		}
+		apierror.InternalError(w, r, err, "Failed to start conversation")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(conv)
+}
+
+// sendMessage serves POST /api/messages?conversation_id=... A block between
+// the two people in a 1:1 conversation stops either from sending to it;
+// group conversations are not affected.
+func sendMessage(w http.ResponseWriter, r *http.Request, messages store.MessageRepo, blocks store.BlockRepo) {
+	conv, ok := participantConversation(w, r, messages)
+	if !ok {
+		return
+	}
+	principal, _ := auth.FromContext(r.Context())
+
+	if !conv.IsGroup {
+		var others []uuid.UUID
+		for _, p := range conv.Participants {
+			if p.UserID != principal.UserID {
+				others = append(others, p.UserID)
+			}
+		}
+		blocked, err := blockedWithAny(r, blocks, principal.UserID, others)
+		if err != nil {
+			apierror.InternalError(w, r, err, "Database query error")
+			return
+		}
+		if blocked {
+			apierror.Write(w, r, apierror.Blocked, "You cannot message this user")
+			return
+		}
+	}
+
+	var req SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
+		return
+	}
+
+	if req.Content == "" {
+		apierror.Write(w, r, apierror.ValidationFailed, "Message content cannot be empty")
+		return
+	}
+
+	msg := &store.Message{ConversationID: conv.ID, SenderID: principal.UserID, Content: req.Content}
+	if err := messages.Send(r.Context(), msg); err != nil {
+		apierror.InternalError(w, r, err, "Failed to send message")
+		return
+	}
+
+	// To return the sent message with sender info
+	sent, err := messages.MessageByID(r.Context(), conv.ID, msg.ID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to retrieve sent message")
+		return
+	}
+	sent.ReadBy = []uuid.UUID{}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sent)
+}
+
+func markRead(w http.ResponseWriter, r *http.Request, messages store.MessageRepo) {
+	conv, ok := participantConversation(w, r, messages)
+	if !ok {
+		return
+	}
+	principal, _ := auth.FromContext(r.Context())
+
+	var req MarkReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
+		return
+	}
+
+	upTo := conv.LastMessageAt
+	if req.MessageID != nil {
+		messageID, err := uuid.Parse(*req.MessageID)
+		if err != nil {
+			apierror.Write(w, r, apierror.InvalidParameter, "Invalid message_id")
+			return
+		}
+		msg, err := messages.MessageByID(r.Context(), conv.ID, messageID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				apierror.Write(w, r, apierror.MessageNotFound, "Message not found")
+				return
+			}
+			apierror.InternalError(w, r, err, "Database query error")
+			return
+		}
+		upTo = msg.CreatedAt
+	}
+
+	if err := messages.MarkRead(r.Context(), conv.ID, principal.UserID, upTo); err != nil {
+		apierror.InternalError(w, r, err, "Failed to mark conversation as read")
+		return
+	}
+
+	// Receipts only move forward, so report the one that is now stored.
+	updated, err := messages.Conversation(r.Context(), conv.ID, principal.UserID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to retrieve read receipt")
+		return
+	}
+	receipt := ReadReceipt{ConversationID: conv.ID, UserID: principal.UserID}
+	for _, p := range updated.Participants {
+		if p.UserID == principal.UserID {
+			receipt.LastReadAt = p.LastReadAt
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// blockedWithAny reports whether userID and any of others blocked the other.
+func blockedWithAny(r *http.Request, blocks store.BlockRepo, userID uuid.UUID, others []uuid.UUID) (bool, error) {
+	for _, other := range others {
+		blocked, err := blocks.Between(r.Context(), userID, other)
+		if err != nil || blocked {
+			return blocked, err
+		}
+	}
+	return false, nil
+}
+
+// participantConversation loads the conversation named by the
+// "conversation_id" query parameter. Callers who are not participants get the
+// same 404 as for a conversation that does not exist. It writes the error
+// response and returns false otherwise.
+func participantConversation(w http.ResponseWriter, r *http.Request, messages store.MessageRepo) (*store.Conversation, bool) {
+	conversationID, err := uuid.Parse(r.URL.Query().Get("conversation_id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter, "Invalid conversation_id")
+		return nil, false
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	conv, err := messages.Conversation(r.Context(), conversationID, principal.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.ConversationNotFound, "Conversation not found")
+			return nil, false
+		}
+		apierror.InternalError(w, r, err, "Database query error")
+		return nil, false
+	}
+
+	return conv, true
+}
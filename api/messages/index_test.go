@@ -0,0 +1,243 @@
+package messages
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+type conversationPage struct {
+	Items      []store.Conversation `json:"items"`
+	NextCursor *string              `json:"next_cursor"`
+}
+
+type messagePage struct {
+	Items      []store.Message `json:"items"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+// Starting a 1:1 conversation a second time, from either side, returns the
+// one the first call created instead of a new one.
+func TestStartDirectConversationTwice(t *testing.T) {
+	db := apitest.DB(t)
+	alice := apitest.CreateUser(t, db, "alice")
+	bob := apitest.CreateUser(t, db, "bob")
+
+	steps := []struct {
+		name  string
+		from  apitest.User
+		other apitest.User
+		want  int
+	}{
+		{"start", alice, bob, http.StatusCreated},
+		{"start again", alice, bob, http.StatusOK},
+		{"start from the other side", bob, alice, http.StatusOK},
+	}
+	var first uuid.UUID
+	for i, step := range steps {
+		body := StartConversationRequest{ParticipantIDs: []string{step.other.ID.String()}}
+		rec := apitest.Do(t, Handler, http.MethodPost, "/api/messages", step.from.Token, body)
+		if rec.Code != step.want {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, rec.Code, step.want, rec.Body)
+		}
+		var conv store.Conversation
+		apitest.Decode(t, rec, &conv)
+		if i == 0 {
+			first = conv.ID
+		} else if conv.ID != first {
+			t.Errorf("%s: conversation = %s, want %s", step.name, conv.ID, first)
+		}
+		if conv.IsGroup || len(conv.Participants) != 2 {
+			t.Errorf("%s: is_group = %v with %d participants, want a 1:1", step.name, conv.IsGroup, len(conv.Participants))
+		}
+	}
+
+	var total int64
+	err := db.Model(&store.Conversation{}).
+		Where("direct_key = ?", store.DirectKey(alice.ID, bob.ID)).
+		Count(&total).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Fatalf("%d conversations between alice and bob, want 1", total)
+	}
+}
+
+// A message shows up as unread for the recipient until they mark the
+// conversation read, after which it lists them in read_by.
+func TestSendListAndMarkRead(t *testing.T) {
+	db := apitest.DB(t)
+	alice := apitest.CreateUser(t, db, "alice")
+	bob := apitest.CreateUser(t, db, "bob")
+
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/messages", alice.Token,
+		StartConversationRequest{ParticipantIDs: []string{bob.ID.String()}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("start: status = %d: %s", rec.Code, rec.Body)
+	}
+	var conv store.Conversation
+	apitest.Decode(t, rec, &conv)
+	target := "/api/messages?conversation_id=" + conv.ID.String()
+
+	var sent []store.Message
+	for _, content := range []string{"hi", "are you there?"} {
+		rec := apitest.Do(t, Handler, http.MethodPost, target, alice.Token, SendMessageRequest{Content: content})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("send %q: status = %d: %s", content, rec.Code, rec.Body)
+		}
+		var msg store.Message
+		apitest.Decode(t, rec, &msg)
+		if msg.SenderID != alice.ID || msg.Content != content {
+			t.Errorf("sent %+v, want %q from alice", msg, content)
+		}
+		sent = append(sent, msg)
+	}
+
+	unread := func(user apitest.User) int64 {
+		t.Helper()
+		rec := apitest.Do(t, Handler, http.MethodGet, "/api/messages", user.Token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list conversations: status = %d: %s", rec.Code, rec.Body)
+		}
+		var page conversationPage
+		apitest.Decode(t, rec, &page)
+		for _, c := range page.Items {
+			if c.ID == conv.ID {
+				if c.LastMessage == nil || c.LastMessage.ID != sent[len(sent)-1].ID {
+					t.Errorf("last message = %+v, want %s", c.LastMessage, sent[len(sent)-1].ID)
+				}
+				return c.UnreadCount
+			}
+		}
+		t.Fatalf("conversation %s not listed for %s", conv.ID, user.Username)
+		return 0
+	}
+	if n := unread(bob); n != 2 {
+		t.Errorf("bob's unread count = %d, want 2", n)
+	}
+	if n := unread(alice); n != 0 {
+		t.Errorf("alice's unread count = %d, want 0", n)
+	}
+
+	rec = apitest.Do(t, Handler, http.MethodGet, target, bob.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list messages: status = %d: %s", rec.Code, rec.Body)
+	}
+	var page messagePage
+	apitest.Decode(t, rec, &page)
+	if len(page.Items) != 2 || page.Items[0].ID != sent[1].ID || page.Items[1].ID != sent[0].ID {
+		t.Fatalf("messages = %+v, want both, newest first", page.Items)
+	}
+	for _, m := range page.Items {
+		if len(m.ReadBy) != 0 {
+			t.Errorf("message %q read by %v before bob read it", m.Content, m.ReadBy)
+		}
+	}
+
+	rec = apitest.Do(t, Handler, http.MethodPut, target, bob.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("mark read: status = %d: %s", rec.Code, rec.Body)
+	}
+	var receipt ReadReceipt
+	apitest.Decode(t, rec, &receipt)
+	if receipt.UserID != bob.ID || receipt.LastReadAt == nil || receipt.LastReadAt.Before(sent[1].CreatedAt) {
+		t.Errorf("receipt = %+v, want bob's covering %s", receipt, sent[1].CreatedAt)
+	}
+	if n := unread(bob); n != 0 {
+		t.Errorf("bob's unread count after reading = %d, want 0", n)
+	}
+
+	rec = apitest.Do(t, Handler, http.MethodGet, target, alice.Token, nil)
+	apitest.Decode(t, rec, &page)
+	for _, m := range page.Items {
+		if len(m.ReadBy) != 1 || m.ReadBy[0] != bob.ID {
+			t.Errorf("message %q read_by = %v, want [bob]", m.Content, m.ReadBy)
+		}
+	}
+}
+
+// Conversations the caller is not part of look the same as ones that do not
+// exist.
+func TestConversationAccess(t *testing.T) {
+	db := apitest.DB(t)
+	alice := apitest.CreateUser(t, db, "alice")
+	bob := apitest.CreateUser(t, db, "bob")
+	eve := apitest.CreateUser(t, db, "eve")
+
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/messages", alice.Token,
+		StartConversationRequest{ParticipantIDs: []string{bob.ID.String()}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("start: status = %d: %s", rec.Code, rec.Body)
+	}
+	var conv store.Conversation
+	apitest.Decode(t, rec, &conv)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		body   any
+		want   int
+	}{
+		{"outsider reads", http.MethodGet, "/api/messages?conversation_id=" + conv.ID.String(), eve.Token, nil, http.StatusNotFound},
+		{"outsider sends", http.MethodPost, "/api/messages?conversation_id=" + conv.ID.String(), eve.Token, SendMessageRequest{Content: "hi"}, http.StatusNotFound},
+		{"unknown conversation", http.MethodGet, "/api/messages?conversation_id=" + uuid.NewString(), alice.Token, nil, http.StatusNotFound},
+		{"invalid conversation_id", http.MethodGet, "/api/messages?conversation_id=nope", alice.Token, nil, http.StatusBadRequest},
+		{"empty message", http.MethodPost, "/api/messages?conversation_id=" + conv.ID.String(), alice.Token, SendMessageRequest{}, http.StatusBadRequest},
+		{"only self", http.MethodPost, "/api/messages", alice.Token, StartConversationRequest{ParticipantIDs: []string{alice.ID.String()}}, http.StatusBadRequest},
+		{"unknown participant", http.MethodPost, "/api/messages", alice.Token, StartConversationRequest{ParticipantIDs: []string{uuid.NewString()}}, http.StatusNotFound},
+		{"anonymous", http.MethodGet, "/api/messages", "", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, tt.method, tt.target, tt.token, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+// Neither side of a block can start a 1:1 conversation with the other or
+// keep writing in one they already had.
+func TestMessagesBlocked(t *testing.T) {
+	db := apitest.DB(t)
+	alice := apitest.CreateUser(t, db, "alice")
+	bob := apitest.CreateUser(t, db, "bob")
+
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/messages", alice.Token,
+		StartConversationRequest{ParticipantIDs: []string{bob.ID.String()}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("start: status = %d: %s", rec.Code, rec.Body)
+	}
+	var conv store.Conversation
+	apitest.Decode(t, rec, &conv)
+
+	block := &store.Block{BlockerID: bob.ID, BlockedID: alice.ID}
+	if _, err := store.NewBlockRepo(db).Create(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []struct{ from, to apitest.User }{{alice, bob}, {bob, alice}} {
+		rec := apitest.Do(t, Handler, http.MethodPost, "/api/messages", u.from.Token,
+			StartConversationRequest{ParticipantIDs: []string{u.to.ID.String()}})
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s starts with %s: status = %d, want 403", u.from.Username, u.to.Username, rec.Code)
+		}
+		rec = apitest.Do(t, Handler, http.MethodPost, "/api/messages?conversation_id="+conv.ID.String(), u.from.Token,
+			SendMessageRequest{Content: "still there?"})
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s sends to %s: status = %d, want 403", u.from.Username, u.to.Username, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/store"
+)
+
+// UnreadCountResponse is the badge value returned by
+// GET /api/notifications?unread_count=true.
+type UnreadCountResponse struct {
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// MarkReadResponse is returned after marking notifications read.
+type MarkReadResponse struct {
+	Updated     int64 `json:"updated"`
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// Handler is the entry point for the /api/notifications serverless function.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+	notifications := store.NewNotificationRepo(db)
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("unread_count") == "true":
+		getUnreadCount(w, r, notifications)
+	case r.Method == http.MethodGet:
+		listNotifications(w, r, notifications)
+	case r.Method == http.MethodPut:
+		markRead(w, r, notifications)
+	default:
+		apierror.NotAllowed(w, r)
+	}
+}
+
+// listNotifications serves GET /api/notifications[?limit=...&cursor=...].
+func listNotifications(w http.ResponseWriter, r *http.Request, notifications store.NotificationRepo) {
+	principal, _ := auth.FromContext(r.Context())
+
+	page, err := store.ParsePage(r.URL.Query().Get("limit"), r.URL.Query().Get("cursor"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidCursor, "Invalid cursor")
+		return
+	}
+
+	list, err := notifications.List(r.Context(), principal.UserID, page)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch notifications")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(store.NewPageResult(list, page, store.NotificationCursor))
+}
+
+func getUnreadCount(w http.ResponseWriter, r *http.Request, notifications store.NotificationRepo) {
+	principal, _ := auth.FromContext(r.Context())
+
+	count, err := notifications.UnreadCount(r.Context(), principal.UserID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to count notifications")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(UnreadCountResponse{UnreadCount: count})
+}
+
+// markRead serves PUT /api/notifications?id=... for one notification and
+// PUT /api/notifications for all of them.
+func markRead(w http.ResponseWriter, r *http.Request, notifications store.NotificationRepo) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var resp MarkReadResponse
+	if idStr := r.URL.Query().Get("id"); idStr != "" {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			apierror.Write(w, r, apierror.InvalidParameter, "Invalid Notification ID format")
+			return
+		}
+		changed, err := notifications.MarkRead(r.Context(), principal.UserID, id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				apierror.Write(w, r, apierror.NotificationNotFound, "Notification not found")
+				return
+			}
+			apierror.InternalError(w, r, err, "Failed to mark notification as read")
+			return
+		}
+		if changed {
+			resp.Updated = 1
+		}
+	} else {
+		n, err := notifications.MarkAllRead(r.Context(), principal.UserID)
+		if err != nil {
+			apierror.InternalError(w, r, err, "Failed to mark notifications as read")
+			return
+		}
+		resp.Updated = n
+	}
+
+	count, err := notifications.UnreadCount(r.Context(), principal.UserID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to count notifications")
+		return
+	}
+	resp.UnreadCount = count
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,159 @@
+package notifications
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/notify"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+type notificationPage struct {
+	Items      []store.Notification `json:"items"`
+	NextCursor *string              `json:"next_cursor"`
+}
+
+func list(t *testing.T, user apitest.User) []store.Notification {
+	t.Helper()
+	rec := apitest.Do(t, Handler, http.MethodGet, "/api/notifications", user.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: status = %d: %s", rec.Code, rec.Body)
+	}
+	var page notificationPage
+	apitest.Decode(t, rec, &page)
+	return page.Items
+}
+
+func like(t *testing.T, n *notify.Notifier, user apitest.User, postID uuid.UUID) {
+	t.Helper()
+	n.Liked(context.Background(), &store.Like{PostID: &postID, UserID: user.ID})
+}
+
+// Unread notifications of the same kind about the same thing collapse into
+// one entry that names the latest actors and counts them all.
+func TestNotificationGrouping(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	alice := apitest.CreateUser(t, db, "alice")
+	bob := apitest.CreateUser(t, db, "bob")
+	carol := apitest.CreateUser(t, db, "carol")
+	post := apitest.CreatePost(t, db, author.ID, "popular")
+	notifier := notify.New(db)
+
+	like(t, notifier, alice, post.ID)
+	like(t, notifier, bob, post.ID)
+	like(t, notifier, alice, post.ID) // again: moves alice to the front, not counted twice
+	for _, u := range []apitest.User{carol, bob} {
+		notifier.Followed(context.Background(), u.ID, author.ID)
+	}
+
+	items := list(t, author)
+	if len(items) != 2 {
+		t.Fatalf("%d notifications, want the follows and the likes grouped: %+v", len(items), items)
+	}
+	follows, likes := items[0], items[1]
+	if follows.Type != store.NotificationFollow || likes.Type != store.NotificationLike {
+		t.Fatalf("types = %s, %s; want follow then like (most recently updated first)", follows.Type, likes.Type)
+	}
+
+	tests := []struct {
+		name        string
+		n           store.Notification
+		wantActors  []uuid.UUID
+		wantSummary string
+	}{
+		{"likes", likes, []uuid.UUID{alice.ID, bob.ID}, alice.Username + " and " + bob.Username + " liked your post"},
+		{"follows", follows, []uuid.UUID{bob.ID, carol.ID}, bob.Username + " and " + carol.Username + " started following you"},
+	}
+	for _, tt := range tests {
+		if tt.n.ActorCount != int64(len(tt.wantActors)) || len(tt.n.Actors) != len(tt.wantActors) {
+			t.Errorf("%s: %d actors (%d shown), want %d", tt.name, tt.n.ActorCount, len(tt.n.Actors), len(tt.wantActors))
+			continue
+		}
+		for i, id := range tt.wantActors {
+			if tt.n.Actors[i].ID != id {
+				t.Errorf("%s: actor %d = %s, want %s", tt.name, i, tt.n.Actors[i].ID, id)
+			}
+		}
+		if tt.n.Summary != tt.wantSummary {
+			t.Errorf("%s: summary = %q, want %q", tt.name, tt.n.Summary, tt.wantSummary)
+		}
+	}
+}
+
+// Marking read clears the badge; a read notification is not reopened by a
+// later action, which starts a new group instead.
+func TestNotificationMarkRead(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	fan := apitest.CreateUser(t, db, "fan")
+	other := apitest.CreateUser(t, db, "other")
+	first := apitest.CreatePost(t, db, author.ID, "first")
+	second := apitest.CreatePost(t, db, author.ID, "second")
+	notifier := notify.New(db)
+	like(t, notifier, fan, first.ID)
+	like(t, notifier, fan, second.ID)
+
+	unread := func() int64 {
+		t.Helper()
+		rec := apitest.Do(t, Handler, http.MethodGet, "/api/notifications?unread_count=true", author.Token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unread count: status = %d: %s", rec.Code, rec.Body)
+		}
+		var resp UnreadCountResponse
+		apitest.Decode(t, rec, &resp)
+		return resp.UnreadCount
+	}
+	markRead := func(target string, want int, wantResp MarkReadResponse) {
+		t.Helper()
+		rec := apitest.Do(t, Handler, http.MethodPut, target, author.Token, nil)
+		if rec.Code != want {
+			t.Fatalf("PUT %s: status = %d, want %d: %s", target, rec.Code, want, rec.Body)
+		}
+		if want != http.StatusOK {
+			return
+		}
+		var resp MarkReadResponse
+		apitest.Decode(t, rec, &resp)
+		if resp != wantResp {
+			t.Errorf("PUT %s = %+v, want %+v", target, resp, wantResp)
+		}
+	}
+
+	if n := unread(); n != 2 {
+		t.Fatalf("unread = %d, want 2", n)
+	}
+	items := list(t, author)
+	one := "/api/notifications?id=" + items[0].ID.String()
+	markRead(one, http.StatusOK, MarkReadResponse{Updated: 1, UnreadCount: 1})
+	markRead(one, http.StatusOK, MarkReadResponse{Updated: 0, UnreadCount: 1})
+
+	// Another user's notification looks like one that does not exist.
+	rec := apitest.Do(t, Handler, http.MethodPut, one, other.Token, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("marking someone else's: status = %d, want 404", rec.Code)
+	}
+	markRead("/api/notifications?id="+uuid.NewString(), http.StatusNotFound, MarkReadResponse{})
+	markRead("/api/notifications?id=nope", http.StatusBadRequest, MarkReadResponse{})
+
+	markRead("/api/notifications", http.StatusOK, MarkReadResponse{Updated: 1, UnreadCount: 0})
+	if n := unread(); n != 0 {
+		t.Errorf("unread after marking all = %d, want 0", n)
+	}
+
+	like(t, notifier, other, first.ID)
+	items = list(t, author)
+	if len(items) != 3 || items[0].ReadAt != nil || items[0].ActorCount != 1 {
+		t.Fatalf("after a new like: %+v, want a new unread group on top", items)
+	}
+	if n := unread(); n != 1 {
+		t.Errorf("unread after a new like = %d, want 1", n)
+	}
+}
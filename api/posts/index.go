@@ -1,99 +1,49 @@
 package posts
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"sync"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-)
 
-var (
-	db          *gorm.DB
-	once        sync.Once
-	jwtSecret   []byte
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/linkpreview"
+	"go-common/notify"
+	"go-common/storage"
+	"go-common/store"
 )
 
-type Post struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	Content   string    `gorm:"not null" json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	User      Profile   `gorm:"foreignKey:UserID" json:"user"`
-}
-
-type Profile struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	Username  string    `json:"username"`
-	FullName  string    `json:"full_name"`
-	AvatarURL string    `json:"avatar_url"`
-}
-
-func (Profile) TableName() string {
-    return "profiles"
+type UpdatePostRequest struct {
+	Content string `json:"content"`
 }
 
+// fetcher unfurls the links in new and edited posts. Tests replace it to
+// reach their local servers, which it refuses.
+var fetcher = linkpreview.New()
+
+const (
+	// previewTTL is how long a cached preview is used before its page is
+	// fetched again.
+	previewTTL = 24 * time.Hour
+	// failedPreviewTTL is how long a failed fetch is remembered.
+	failedPreviewTTL = time.Hour
+	// unfurlTimeout bounds the fetch of a preview, which the response waits
+	// for, well below linkpreview.DefaultTimeout.
+	unfurlTimeout = 1500 * time.Millisecond
+)
 
-func Connect() (*gorm.DB, error) {
-	var err error
-	once.Do(func() {
-		if os.Getenv("VERCEL_ENV") == "" {
-			err = godotenv.Load("../../.env") // Assuming .env is at project root
-			if err != nil {
-				log.Println("Warning: .env file not found, relying on environment variables")
-			}
-		}
-		dsn := os.Getenv("DIRECT_URL") // Use DIRECT_URL for direct connection
-		if dsn == "" {
-			dsn = os.Getenv("DATABASE_URL") // Fallback to DATABASE_URL if DIRECT_URL is not set
-		}
-		if dsn == "" {
-			log.Fatal("FATAL: Neither DIRECT_URL nor DATABASE_URL environment variable is set")
-		}
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			PrepareStmt: false, // Disable prepared statement caching for serverless environment
-		})
-		if err != nil {
-			log.Fatalf("FATAL: Failed to connect to database: %v", err)
-		}
-
-		sqlDB, err := db.DB()
-		if err != nil {
-			log.Fatalf("FATAL: Failed to get underlying sql.DB: %v", err)
-		}
-		sqlDB.SetMaxIdleConns(1) // Keep very few idle connections
-		sqlDB.SetMaxOpenConns(1) // Limit total open connections
-		sqlDB.SetConnMaxLifetime(time.Minute) // Short lifetime
-
-		jwtSecret = []byte(os.Getenv("SUPABASE_JWT_SECRET"))
-		if len(jwtSecret) == 0 {
-			log.Fatal("FATAL: SUPABASE_JWT_SECRET environment variable not set")
-		}
-
-		log.Println("Database connection successful and pool established.")
-	})
-	if err != nil {
-		return nil, err
-	}
-	return db, nil
-}
-
-func GetDB() (*gorm.DB, error) {
-	if db == nil {
-		return Connect()
-	}
-	return db, nil
-}
-
-type UpdatePostRequest struct {
-	Content string `json:"content"`
+// CreatePostRequest is the body of POST /api/posts. AttachmentIDs are
+// uploads from /api/attachments, at most store.MaxAttachments, in the order
+// they are shown. A post needs content, attachments or both.
+type CreatePostRequest struct {
+	Content       string      `json:"content"`
+	AttachmentIDs []uuid.UUID `json:"attachment_ids"`
 }
 
 func Handler(w http.ResponseWriter, r *http.Request) {
@@ -107,270 +57,267 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
-	
-	db, err := GetDB()
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	db, err := store.GetDB()
 	if err != nil {
-		http.Error(w, "Failed to connect to database", http.StatusInternalServerError)
+		apierror.DatabaseError(w, r, err)
 		return
 	}
+	posts := store.NewPostRepo(db)
+	previews := store.NewLinkPreviewRepo(db)
 
 	switch r.Method {
 	case http.MethodPost:
-		createPost(w, r, db)
+		createPost(w, r, posts, store.NewMediaRepo(db), previews, notify.New(db))
 	case http.MethodDelete:
-		deletePost(w, r, db)
+		deletePost(w, r, posts, store.NewMediaRepo(db))
 	case http.MethodPut:
-		updatePost(w, r, db)
+		updatePost(w, r, posts, store.NewLikeRepo(db), store.NewMediaRepo(db), previews)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.NotAllowed(w, r)
 	}
 }
 
-func updatePost(w http.ResponseWriter, r *http.Request, db *gorm.DB) {
+func updatePost(w http.ResponseWriter, r *http.Request, posts store.PostRepo, likes store.LikeRepo, attachments store.MediaRepo, previews store.LinkPreviewRepo) {
 	postIDStr := r.URL.Query().Get("id")
 	if postIDStr == "" {
-		http.Error(w, "Post ID is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidParameter, "Post ID is required")
 		return
 	}
 
 	postID, err := uuid.Parse(postIDStr)
 	if err != nil {
-		http.Error(w, "Invalid Post ID format", http.StatusBadRequest)
-		return
-	}
-
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header required", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.InvalidParameter, "Invalid Post ID format")
 		return
 	}
-	tokenString := authHeader[len("Bearer "):]
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
 
-	if err != nil || !token.Valid {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return
-	}
+	principal, _ := auth.FromContext(r.Context())
+	userID := principal.UserID
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+	var updateReq UpdatePostRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
 		return
 	}
 
-	userIDStr, ok := claims["sub"].(string)
-	if !ok {
-		http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+	if updateReq.Content == "" {
+		apierror.Write(w, r, apierror.ValidationFailed, "Post content cannot be empty")
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
+	post, err := posts.ByID(r.Context(), postID)
 	if err != nil {
-		http.Error(w, "Invalid user ID format", http.StatusBadRequest)
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.PostNotFound, "Post not found")
+			return
+		}
+		apierror.InternalError(w, r, err, "Database error")
 		return
 	}
 
-	var updateReq UpdatePostRequest
-	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if post.UserID != userID {
+		apierror.Write(w, r, apierror.Forbidden, "You are not authorized to edit this post")
 		return
 	}
 
-	if updateReq.Content == "" {
-		http.Error(w, "Post content cannot be empty", http.StatusBadRequest)
+	linkURL := firstLink(updateReq.Content)
+	if err := posts.UpdateContent(r.Context(), post.ID, updateReq.Content, linkURL); err != nil {
+		apierror.InternalError(w, r, err, "Failed to update post")
 		return
 	}
-
-	var post Post
-	if err := db.First(&post, "id = ?", postID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			http.Error(w, "Post not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
+	if linkURL != nil {
+		unfurl(r.Context(), previews, *linkURL)
 	}
 
-	if post.UserID != userID {
-		http.Error(w, "You are not authorized to edit this post", http.StatusForbidden)
+	post, err = posts.ByID(r.Context(), post.ID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to retrieve updated post")
 		return
 	}
 
-	post.Content = updateReq.Content
-	if err := db.Save(&post).Error; err != nil {
-		http.Error(w, "Failed to update post", http.StatusInternalServerError)
-		return
+	updated := []store.Post{*post}
+	err = likes.FillPosts(r.Context(), userID, updated)
+	if err == nil {
+		err = attachments.FillPosts(r.Context(), updated)
 	}
-	
-	if err := db.Preload("User").First(&post, "id = ?", post.ID).Error; err != nil {
-		http.Error(w, "Failed to retrieve updated post", http.StatusInternalServerError)
+	if err == nil {
+		err = previews.FillPosts(r.Context(), updated)
+	}
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to retrieve updated post")
 		return
 	}
+	post = &updated[0]
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(post)
 }
 
-func deletePost(w http.ResponseWriter, r *http.Request, db *gorm.DB) {
+func deletePost(w http.ResponseWriter, r *http.Request, posts store.PostRepo, attachments store.MediaRepo) {
 	postIDStr := r.URL.Query().Get("id") // Assuming post ID is passed as 'id' query param
 	if postIDStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Post ID is required"})
+		apierror.Write(w, r, apierror.InvalidParameter, "Post ID is required")
 		return
 	}
 
 	postID, err := uuid.Parse(postIDStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid Post ID format"})
-		return
-	}
-
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Authorization header required"})
+		apierror.Write(w, r, apierror.InvalidParameter, "Invalid Post ID format")
 		return
 	}
-	tokenString := authHeader[len("Bearer "):]
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	principal, _ := auth.FromContext(r.Context())
+	userID := principal.UserID
 
-	if err != nil || !token.Valid {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid token"})
+	post, err := posts.ByID(r.Context(), postID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.PostNotFound, "Post not found")
+			return
+		}
+		apierror.InternalError(w, r, err, "Database query error")
 		return
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid token claims"})
+	if post.UserID != userID {
+		apierror.Write(w, r, apierror.Forbidden, "You are not authorized to delete this post")
 		return
 	}
 
-	userIDStr, ok := claims["sub"].(string)
-	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid user ID in token"})
+	// The attachment rows go with the post; load them first so that their
+	// stored files can be deleted as well.
+	deleted := []store.Post{*post}
+	if err := attachments.FillPosts(r.Context(), deleted); err != nil {
+		apierror.InternalError(w, r, err, "Database query error")
 		return
 	}
-
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid user ID format"})
-		return
+	var keys []string
+	for _, a := range deleted[0].Attachments {
+		keys = append(keys, a.StorageKeys()...)
 	}
-
-	var post Post
-	result := db.First(&post, "id = ?", postID)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{"message": "Post not found"})
+	var fileStore storage.Storage
+	if len(keys) > 0 {
+		if fileStore, err = storage.FromEnv(); err != nil {
+			apierror.InternalError(w, r, err, "Media storage is not configured")
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Database query error", "error": result.Error.Error()})
-		return
 	}
 
-	if post.UserID != userID {
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(map[string]string{"message": "You are not authorized to delete this post"})
+	if err := posts.Delete(r.Context(), post.ID); err != nil {
+		apierror.InternalError(w, r, err, "Failed to delete post")
 		return
 	}
-
-	if err := db.Delete(&post).Error; err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to delete post", "error": err.Error()})
-		return
+	if len(keys) > 0 {
+		// Nothing refers to the files any more, so a failure is only logged.
+		if err := storage.DeleteAll(r.Context(), fileStore, keys); err != nil {
+			log.Printf("[ERROR] Failed to delete attachment files of post %s: %v", post.ID, err)
+		}
 	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Post deleted successfully"})
 }
 
+func createPost(w http.ResponseWriter, r *http.Request, posts store.PostRepo, attachments store.MediaRepo, previews store.LinkPreviewRepo, notifier *notify.Notifier) {
+	principal, _ := auth.FromContext(r.Context())
+	userID := principal.UserID
 
-
-func createPost(w http.ResponseWriter, r *http.Request, db *gorm.DB) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Authorization header required"})
+	var req CreatePostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
 		return
 	}
-	tokenString := authHeader[len("Bearer "):]
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
 
-	if err != nil || !token.Valid {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid token"})
+	if req.Content == "" && len(req.AttachmentIDs) == 0 {
+		apierror.Write(w, r, apierror.ValidationFailed, "Post content cannot be empty")
 		return
 	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid token claims"})
+	if len(req.AttachmentIDs) > store.MaxAttachments {
+		apierror.Write(w, r, apierror.ValidationFailed, fmt.Sprintf("A post can have at most %d attachments", store.MaxAttachments))
 		return
 	}
 
-	userIDStr, ok := claims["sub"].(string)
-	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid user ID in token"})
+	post := &store.Post{ID: uuid.New(), UserID: userID, Content: req.Content, LinkURL: firstLink(req.Content)}
+	if err := posts.Create(r.Context(), post, req.AttachmentIDs...); err != nil {
+		if errors.Is(err, store.ErrAttachmentUnavailable) {
+			apierror.Write(w, r, apierror.ValidationFailed, "Attachments must be your own uploads that are not part of another post")
+			return
+		}
+		apierror.InternalError(w, r, err, "Failed to create post")
 		return
 	}
+	notifier.Posted(r.Context(), post)
+	if post.LinkURL != nil {
+		unfurl(r.Context(), previews, *post.LinkURL)
+	}
 
-	userID, err := uuid.Parse(userIDStr)
+	// To return the created post with user info
+	post, err := posts.ByID(r.Context(), post.ID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid user ID format"})
+		apierror.InternalError(w, r, err, "Failed to retrieve created post")
 		return
 	}
-
-	var post Post
-	if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid request body"})
-		return
+	created := []store.Post{*post}
+	err = attachments.FillPosts(r.Context(), created)
+	if err == nil {
+		err = previews.FillPosts(r.Context(), created)
 	}
-
-	if post.Content == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Post content cannot be empty"})
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to retrieve created post")
 		return
 	}
+	post = &created[0]
 
-	post.UserID = userID
-	post.ID = uuid.New()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(post)
+}
 
-	if err := db.Create(&post).Error; err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to create post", "error": err.Error()})
-		return
+// firstLink returns the normalised first URL in content, or nil if there is
+// none.
+func firstLink(content string) *string {
+	link, err := linkpreview.Normalize(linkpreview.FirstURL(content))
+	if err != nil {
+		return nil
 	}
+	return &link
+}
 
-	// To return the created post with user info
-	if err := db.Preload("User").First(&post, "id = ?", post.ID).Error; err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to retrieve created post", "error": err.Error()})
+// unfurl makes sure a fresh preview of link is cached. It runs once the post
+// is saved and never fails the request: a page that cannot be fetched within
+// unfurlTimeout just gets no preview.
+func unfurl(ctx context.Context, previews store.LinkPreviewRepo, link string) {
+	cached, err := previews.ByURL(ctx, link)
+	switch {
+	case err == nil:
+		ttl := previewTTL
+		if cached.Failed {
+			ttl = failedPreviewTTL
+		}
+		if time.Since(cached.FetchedAt) < ttl {
+			return
+		}
+	case !errors.Is(err, store.ErrNotFound):
+		log.Printf("[ERROR] Loading link preview of %s: %v", link, err)
 		return
 	}
 
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(post)
-}
\ No newline at end of file
+	fetchCtx, cancel := context.WithTimeout(ctx, unfurlTimeout)
+	defer cancel()
+	entry := &store.LinkPreview{URL: link, FetchedAt: time.Now()}
+	if preview, err := fetcher.Fetch(fetchCtx, link); err != nil {
+		log.Printf("[INFO] No link preview for %s: %v", link, err)
+		entry.Failed = true
+	} else {
+		entry.Title, entry.Description = preview.Title, preview.Description
+		entry.ImageURL, entry.SiteName = preview.ImageURL, preview.SiteName
+	}
+	if err := previews.Save(ctx, entry); err != nil {
+		log.Printf("[ERROR] Saving link preview of %s: %v", link, err)
+	}
+}
@@ -0,0 +1,358 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-common/apierror"
+	"go-common/apitest"
+	"go-common/linkpreview"
+	"go-common/storage"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+func TestCreatePost(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+
+	tests := []struct {
+		name  string
+		token string
+		body  any
+		want  int
+	}{
+		{"authenticated", author.Token, CreatePostRequest{Content: "hello"}, http.StatusCreated},
+		{"anonymous", "", CreatePostRequest{Content: "hello"}, http.StatusUnauthorized},
+		{"empty content", author.Token, CreatePostRequest{}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, http.MethodPost, "/api/posts", tt.token, tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code != http.StatusCreated {
+				return
+			}
+			var post store.Post
+			apitest.Decode(t, rec, &post)
+			if post.UserID != author.ID || post.User.Username != author.Username {
+				t.Errorf("post belongs to %s (%q), want %s", post.UserID, post.User.Username, author.ID)
+			}
+		})
+	}
+}
+
+// Only a post's author may edit or delete it.
+func TestPostOwnership(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	other := apitest.CreateUser(t, db, "other")
+	posts := store.NewPostRepo(db)
+
+	tests := []struct {
+		name        string
+		method      string
+		token       string
+		missing     bool // target a post that does not exist
+		want        int
+		wantCode    apierror.Code
+		wantContent string // "" means the post must be gone afterwards
+	}{
+		{"author edits", http.MethodPut, author.Token, false, http.StatusOK, "", "edited"},
+		{"other user edits", http.MethodPut, other.Token, false, http.StatusForbidden, apierror.Forbidden, "original"},
+		{"anonymous edit", http.MethodPut, "", false, http.StatusUnauthorized, apierror.Unauthorized, "original"},
+		{"edit missing post", http.MethodPut, author.Token, true, http.StatusNotFound, apierror.PostNotFound, "original"},
+		{"author deletes", http.MethodDelete, author.Token, false, http.StatusOK, "", ""},
+		{"other user deletes", http.MethodDelete, other.Token, false, http.StatusForbidden, apierror.Forbidden, "original"},
+		{"anonymous delete", http.MethodDelete, "", false, http.StatusUnauthorized, apierror.Unauthorized, "original"},
+		{"delete missing post", http.MethodDelete, author.Token, true, http.StatusNotFound, apierror.PostNotFound, "original"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := apitest.CreatePost(t, db, author.ID, "original")
+			target := post.ID
+			if tt.missing {
+				target = apitest.CreatePost(t, db, author.ID, "doomed").ID
+				if err := posts.Delete(context.Background(), target); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var body any
+			if tt.method == http.MethodPut {
+				body = UpdatePostRequest{Content: "edited"}
+			}
+			rec := apitest.Do(t, Handler, tt.method, "/api/posts?id="+target.String(), tt.token, body)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.wantCode != "" {
+				var env apierror.Envelope
+				apitest.Decode(t, rec, &env)
+				if env.Error.Code != tt.wantCode {
+					t.Errorf("error code = %q, want %q", env.Error.Code, tt.wantCode)
+				}
+			}
+
+			got, err := posts.ByID(context.Background(), post.ID)
+			switch {
+			case tt.wantContent == "" && !errors.Is(err, store.ErrNotFound):
+				t.Errorf("post still exists (err = %v)", err)
+			case tt.wantContent != "" && err != nil:
+				t.Errorf("loading post: %v", err)
+			case tt.wantContent != "" && got.Content != tt.wantContent:
+				t.Errorf("content = %q, want %q", got.Content, tt.wantContent)
+			}
+		})
+	}
+}
+
+// upload stores an unattached image attachment for userID.
+func upload(t *testing.T, attachments store.MediaRepo, userID uuid.UUID) uuid.UUID {
+	t.Helper()
+	a := &store.Attachment{
+		UserID: userID, Type: store.AttachmentImage, ContentType: "image/jpeg",
+		StorageKey: "media/test.jpg", URL: "/api/uploads/media/test.jpg", Width: 10, Height: 10,
+	}
+	if err := attachments.Create(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	return a.ID
+}
+
+func TestCreatePostWithAttachments(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	other := apitest.CreateUser(t, db, "other")
+	attachments := store.NewMediaRepo(db)
+
+	first, second := upload(t, attachments, author.ID), upload(t, attachments, author.ID)
+	body := CreatePostRequest{AttachmentIDs: []uuid.UUID{second, first}}
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/posts", author.Token, body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var post store.Post
+	apitest.Decode(t, rec, &post)
+	if len(post.Attachments) != 2 || post.Attachments[0].ID != second || post.Attachments[1].ID != first {
+		t.Errorf("attachments = %+v, want %s then %s", post.Attachments, second, first)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ids   []uuid.UUID
+	}{
+		{"already attached", author.Token, []uuid.UUID{first}},
+		{"someone else's", other.Token, []uuid.UUID{upload(t, attachments, author.ID)}},
+		{"unknown", author.Token, []uuid.UUID{uuid.New()}},
+		{"too many", author.Token, make([]uuid.UUID, store.MaxAttachments+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := CreatePostRequest{Content: "with media", AttachmentIDs: tt.ids}
+			rec := apitest.Do(t, Handler, http.MethodPost, "/api/posts", tt.token, body)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+// Deleting a post deletes its attachments' stored files along with the rows.
+func TestDeletePostDeletesAttachmentFiles(t *testing.T) {
+	db := apitest.DB(t)
+	dir := t.TempDir()
+	t.Setenv(storage.EnvDriver, "local")
+	t.Setenv(storage.EnvLocalDir, dir)
+	author := apitest.CreateUser(t, db, "author")
+
+	prefix := "media/" + author.ID.String() + "/" + uuid.NewString()
+	keys := []string{prefix + ".jpg", prefix + "-thumb.jpg"}
+	for _, key := range keys {
+		if _, err := storage.NewLocal(dir, "").Put(context.Background(), key, []byte("jpeg"), "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := &store.Attachment{
+		UserID: author.ID, Type: store.AttachmentImage, ContentType: "image/jpeg",
+		StorageKey: keys[0], URL: "/api/uploads/" + keys[0], ThumbnailKey: &keys[1], Width: 10, Height: 10,
+	}
+	if err := store.NewMediaRepo(db).Create(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/posts", author.Token, CreatePostRequest{AttachmentIDs: []uuid.UUID{a.ID}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", rec.Code, rec.Body)
+	}
+	var post store.Post
+	apitest.Decode(t, rec, &post)
+
+	if rec := apitest.Do(t, Handler, http.MethodDelete, "/api/posts?id="+post.ID.String(), author.Token, nil); rec.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d: %s", rec.Code, rec.Body)
+	}
+	for _, key := range keys {
+		if _, err := os.Stat(filepath.Join(dir, key)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s left behind after delete: %v", key, err)
+		}
+	}
+}
+
+func TestCreatePostLinkPreview(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(linkpreview.DefaultTimeout):
+			}
+			return
+		}
+		if r.URL.Path != "/article" {
+			http.NotFound(w, r)
+			return
+		}
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<head><meta property="og:title" content="An article"><meta property="og:image" content="/cover.png"></head>`))
+	}))
+	defer srv.Close()
+	// The default fetcher refuses the loopback test server.
+	saved := fetcher
+	fetcher = &linkpreview.Fetcher{Client: srv.Client(), MaxBytes: linkpreview.DefaultMaxBytes}
+	t.Cleanup(func() { fetcher = saved })
+
+	create := func(content string) store.Post {
+		t.Helper()
+		rec := apitest.Do(t, Handler, http.MethodPost, "/api/posts", author.Token, CreatePostRequest{Content: content})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var post store.Post
+		apitest.Decode(t, rec, &post)
+		return post
+	}
+
+	post := create("Read this: " + srv.URL + "/article?utm_source=feed.")
+	if post.LinkPreview == nil || post.LinkPreview.Title != "An article" || post.LinkPreview.ImageURL != srv.URL+"/cover.png" {
+		t.Fatalf("link_preview = %+v", post.LinkPreview)
+	}
+	if post.LinkPreview.URL != srv.URL+"/article" {
+		t.Errorf("preview url = %q, want the normalised URL", post.LinkPreview.URL)
+	}
+
+	// The preview is cached under the normalised URL.
+	if again := create("Again " + srv.URL + "/article#comments"); again.LinkPreview == nil || hits.Load() != 1 {
+		t.Errorf("second post: preview %+v after %d fetches, want 1", again.LinkPreview, hits.Load())
+	}
+
+	// A page without a preview does not stop the post.
+	if broken := create("Broken " + srv.URL + "/missing"); broken.LinkPreview != nil {
+		t.Errorf("broken link preview = %+v", broken.LinkPreview)
+	}
+
+	// A slow page is given up on well before the fetcher's own timeout.
+	start := time.Now()
+	if slow := create("Slow " + srv.URL + "/slow"); slow.LinkPreview != nil {
+		t.Errorf("slow link preview = %+v", slow.LinkPreview)
+	}
+	if elapsed := time.Since(start); elapsed >= linkpreview.DefaultTimeout {
+		t.Errorf("slow link held the post for %v", elapsed)
+	}
+
+	// Editing the link out drops the preview.
+	rec := apitest.Do(t, Handler, http.MethodPut, "/api/posts?id="+post.ID.String(), author.Token, UpdatePostRequest{Content: "No link now"})
+	var updated store.Post
+	apitest.Decode(t, rec, &updated)
+	if rec.Code != http.StatusOK || updated.LinkPreview != nil {
+		t.Errorf("after edit: status %d, preview %+v", rec.Code, updated.LinkPreview)
+	}
+}
+
+// Mentions notify the people mentioned, except those on either side of a
+// block with the author.
+func TestMentionsSkipBlockedUsers(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	friend := apitest.CreateUser(t, db, "friend")
+	blocker := apitest.CreateUser(t, db, "blocker")
+	block := &store.Block{BlockerID: blocker.ID, BlockedID: author.ID}
+	if _, err := store.NewBlockRepo(db).Create(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+
+	content := "hi @" + friend.Username + " and @" + blocker.Username
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/posts", author.Token, CreatePostRequest{Content: content})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var post store.Post
+	apitest.Decode(t, rec, &post)
+
+	for _, tt := range []struct {
+		user apitest.User
+		want int64
+	}{{friend, 1}, {blocker, 0}} {
+		var n int64
+		err := db.Model(&store.Notification{}).
+			Where("recipient_id = ? AND group_key = ?", tt.user.ID, "mention:post:"+post.ID.String()).
+			Count(&n).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.want {
+			t.Errorf("%s has %d mention notifications, want %d", tt.user.Username, n, tt.want)
+		}
+	}
+}
+
+// A private account's post only notifies mentioned users it has approved.
+func TestMentionsOnPrivatePostsSkipNonFollowers(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	follower := apitest.CreateUser(t, db, "follower")
+	stranger := apitest.CreateUser(t, db, "stranger")
+	if err := db.Model(&store.Profile{}).Where("id = ?", author.ID).Update("is_private", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	apitest.Follow(t, db, follower.ID, author.ID)
+
+	content := "hi @" + follower.Username + " and @" + stranger.Username
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/posts", author.Token, CreatePostRequest{Content: content})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var post store.Post
+	apitest.Decode(t, rec, &post)
+
+	for _, tt := range []struct {
+		user apitest.User
+		want int64
+	}{{follower, 1}, {stranger, 0}} {
+		var n int64
+		err := db.Model(&store.Notification{}).
+			Where("recipient_id = ? AND group_key = ?", tt.user.ID, "mention:post:"+post.ID.String()).
+			Count(&n).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.want {
+			t.Errorf("%s has %d mention notifications, want %d", tt.user.Username, n, tt.want)
+		}
+	}
+}
@@ -2,107 +2,94 @@ package profile
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
+	"unicode/utf8"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-)
 
-var (
-	db   *gorm.DB
-	once sync.Once
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/store"
+	"go-common/username"
 )
 
-// Connect initializes the database connection.
-// It's designed to be called once to prevent connection leaks.
-func Connect() (*gorm.DB, error) {
-	var err error
-	once.Do(func() {
-		// Load .env file (for local development)
-		if os.Getenv("VERCEL_ENV") == "" { // Simple check if not in Vercel
-			err = godotenv.Load()
-			if err != nil {
-				log.Println("Warning: .env file not found, relying on environment variables")
-			}
-		}
-
-		dsn := os.Getenv("DIRECT_URL") // Try DIRECT_URL first
-		if dsn == "" {
-			dsn = os.Getenv("DATABASE_URL") // Fallback to DATABASE_URL
-		}
-		if dsn == "" {
-			log.Fatal("FATAL: Neither DIRECT_URL nor DATABASE_URL environment variable is set")
-		}
-
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			PrepareStmt: false, // Disable prepared statement caching for serverless environment
-		})
-		if err != nil {
-			log.Fatalf("FATAL: Failed to connect to database: %v", err)
-		}
-
-		sqlDB, err := db.DB()
-		if err != nil {
-			log.Fatalf("FATAL: Failed to get underlying sql.DB: %v", err)
-		}
-		sqlDB.SetMaxIdleConns(1) // Keep very few idle connections
-		sqlDB.SetMaxOpenConns(1) // Limit total open connections
-		sqlDB.SetConnMaxLifetime(time.Minute) // Short lifetime
-
-		log.Println("Database connection successful and pool established.")
-	})
-
-	if err != nil {
-		return nil, err
-	}
-	return db, nil
-}
-
-// GetDB returns the existing database connection pool.
-// If the connection hasn't been established, it will be initialized.
-func GetDB() (*gorm.DB, error) {
-	if db == nil {
-		return Connect()
-	}
-	return db, nil
-}
-
-type Post struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	Content   string    `gorm:"not null" json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	User      Profile   `gorm:"foreignKey:UserID" json:"user"` // Add this line
-}
-
-func (Profile) TableName() string {
-    return "profiles"
+// ProfileResponse is a profile together with its posts, newest first. The
+// posts of a private account are withheld (PostsHidden) unless the caller is
+// the owner or an approved follower, and so are the posts of anyone the
+// caller has blocked or been blocked by. The embedded profile carries the
+// follower, following and post counts; the relationship fields relate the
+// profile to the caller and are all false on the caller's own profile.
+type ProfileResponse struct {
+	store.Profile
+	store.Relationship
+	Posts       []store.Post `json:"posts"`
+	PostsHidden bool         `json:"posts_hidden"`
 }
 
-type Profile struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	Username  string    `gorm:"unique;not null" json:"username"`
-	FullName  string    `json:"full_name"`
-	UpdatedAt *time.Time `json:"updated_at"`
-	AvatarURL *string    `json:"avatar_url"`
-	Website   *string    `json:"website"`
-	Posts     []Post     `gorm:"foreignKey:UserID;references:ID;order:created_at DESC" json:"posts"` // Add this line
+// MovedResponse is the body of the redirect sent for a username its owner
+// has since changed. Username is the current one.
+type MovedResponse struct {
+	Username string `json:"username"`
 }
 
 // UpdateProfileRequest defines the structure for incoming profile update data.
 // This ensures that users can only update specific, allowed fields.
+// Username must pass username.Validate and can be changed once every
+// username.ChangeCooldown.
+// Website and Bio may be set to "" to clear them.
 type UpdateProfileRequest struct {
 	FullName  *string `json:"full_name"`
 	Username  *string `json:"username"`
+	Website   *string `json:"website"`
+	Bio       *string `json:"bio"`
+	IsPrivate *bool   `json:"is_private"`
+}
+
+const (
+	// MaxBioLength is the longest bio, in characters. The database enforces
+	// it too (profiles_bio_length).
+	MaxBioLength = 160
+	// MaxWebsiteLength is the longest website URL.
+	MaxWebsiteLength = 200
+)
+
+// normalizeWebsite validates a website URL: it must be an absolute http or
+// https URL with a host. A blank website clears the field (nil).
+func normalizeWebsite(s string) (*string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if len(s) > MaxWebsiteLength {
+		return nil, fmt.Errorf("Website must be at most %d characters", MaxWebsiteLength)
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return nil, errors.New("Website must be an http or https URL")
+	}
+	normalized := u.String()
+	return &normalized, nil
+}
+
+// normalizeBio trims a bio and checks its length. A blank bio clears the
+// field (nil).
+func normalizeBio(s string) (*string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if utf8.RuneCountInString(s) > MaxBioLength {
+		return nil, fmt.Errorf("Bio must be at most %d characters", MaxBioLength)
+	}
+	return &s, nil
 }
 
 // ProfileHandler is the entry point for the /api/profile serverless function.
@@ -120,19 +107,17 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := validateToken(r)
-	if err != nil {
-		log.Printf("[DEBUG] Token validation failed: %v", err)
-		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
-		return
-	}
+	auth.Middleware(route)(w, r)
+}
 
+func route(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+	userID := principal.UserID
 	log.Printf("[DEBUG] Token validated successfully for user ID: %s", userID)
 
-	    db, err := GetDB()
+	db, err := store.GetDB()
 	if err != nil {
-		log.Printf("[ERROR] Failed to connect to database: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		apierror.DatabaseError(w, r, err)
 		return
 	}
 
@@ -142,45 +127,92 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		updateProfile(w, r, userID, db)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.NotAllowed(w, r)
 	}
 }
 
-func getProfile(w http.ResponseWriter, r *http.Request, userID string, db *gorm.DB) {
+func getProfile(w http.ResponseWriter, r *http.Request, userID uuid.UUID, db *gorm.DB) {
 	username := r.URL.Query().Get("username")
-	var profile Profile
+	profiles := store.NewProfileRepo(db)
+	var profile *store.Profile
 	var err error
 
 	if username != "" {
 		// If username is provided, fetch by username
-		err = db.Preload("Posts.User").Where("username = ?", username).First(&profile).Error
 		log.Printf("[DEBUG] Attempting to fetch profile by username: %s", username)
+		profile, err = profiles.ByUsername(r.Context(), username)
+		if errors.Is(err, store.ErrNotFound) {
+			var moved *store.Profile
+			if moved, err = profiles.ByPreviousUsername(r.Context(), username); err == nil {
+				redirectMoved(w, moved.Username)
+				return
+			}
+		}
 	} else {
 		// Otherwise, fetch by userID from token
-		err = db.Preload("Posts.User").Where("id = ?", userID).First(&profile).Error
 		log.Printf("[DEBUG] Attempting to fetch profile by userID: %s", userID)
+		profile, err = profiles.ByID(r.Context(), userID)
 	}
 
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if errors.Is(err, store.ErrNotFound) {
 			log.Printf("[DEBUG] Profile not found for username/userID: %s/%s", username, userID)
-			http.Error(w, "Profile not found", http.StatusNotFound)
+			apierror.Write(w, r, apierror.ProfileNotFound, "Profile not found")
 			return
 		}
-		log.Printf("[DEBUG] Database error in getProfile: %v", err)
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		apierror.InternalError(w, r, err, "Database error")
+		return
+	}
+
+	resp := ProfileResponse{Profile: *profile, Posts: []store.Post{}}
+	follows := store.NewFollowRepo(db)
+	visible, err := follows.CanView(r.Context(), userID, profile)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Database error")
+		return
+	}
+
+	if visible {
+		resp.Posts, err = store.NewPostRepo(db).ByUser(r.Context(), profile.ID)
+		if err == nil {
+			err = store.NewLikeRepo(db).FillPosts(r.Context(), userID, resp.Posts)
+		}
+		if err == nil {
+			err = store.NewMediaRepo(db).FillPosts(r.Context(), resp.Posts)
+		}
+		if err == nil {
+			err = store.NewLinkPreviewRepo(db).FillPosts(r.Context(), resp.Posts)
+		}
+	} else {
+		resp.PostsHidden = true
+	}
+	if err == nil && profile.ID != userID {
+		resp.Relationship, err = follows.Relationship(r.Context(), userID, profile.ID)
+	}
+	if err != nil {
+		apierror.InternalError(w, r, err, "Database error")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(profile)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// redirectMoved sends the caller to the profile under its current username.
+// The redirect is temporary: the old username is free to be claimed again,
+// and a cached permanent redirect would keep pointing at the previous owner.
+func redirectMoved(w http.ResponseWriter, current string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/profile?username="+url.QueryEscape(current))
+	w.WriteHeader(http.StatusTemporaryRedirect)
+	json.NewEncoder(w).Encode(MovedResponse{Username: current})
 }
 
-func updateProfile(w http.ResponseWriter, r *http.Request, userID string, db *gorm.DB) {
+func updateProfile(w http.ResponseWriter, r *http.Request, userID uuid.UUID, db *gorm.DB) {
 	var req UpdateProfileRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("[DEBUG] Failed to decode request body in updateProfile: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
 		return
 	}
 
@@ -190,19 +222,79 @@ func updateProfile(w http.ResponseWriter, r *http.Request, userID string, db *go
 	if req.FullName != nil {
 		updates["full_name"] = *req.FullName
 	}
+	profiles := store.NewProfileRepo(db)
+	var newUsername *string
 	if req.Username != nil {
-		updates["username"] = *req.Username
+		// Clients send the whole form back, so an unchanged username is not
+		// validated: it may predate the current rules.
+		current, err := profiles.ByID(r.Context(), userID)
+		if err != nil {
+			apierror.InternalError(w, r, err, "Database error")
+			return
+		}
+		if current.Username != *req.Username {
+			if err := username.Validate(*req.Username); err != nil {
+				apierror.Write(w, r, apierror.ValidationFailed, err.Error())
+				return
+			}
+			newUsername = req.Username
+		}
+	}
+	if req.Website != nil {
+		website, err := normalizeWebsite(*req.Website)
+		if err != nil {
+			apierror.Write(w, r, apierror.ValidationFailed, err.Error())
+			return
+		}
+		updates["website"] = website
+	}
+	if req.Bio != nil {
+		bio, err := normalizeBio(*req.Bio)
+		if err != nil {
+			apierror.Write(w, r, apierror.ValidationFailed, err.Error())
+			return
+		}
+		updates["bio"] = bio
+	}
+	if req.IsPrivate != nil {
+		updates["is_private"] = *req.IsPrivate
 	}
 
-	if len(updates) == 0 {
+	if len(updates) == 0 && req.Username == nil {
 		log.Println("[DEBUG] No fields to update.")
-		http.Error(w, "No fields to update", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.ValidationFailed, "No fields to update")
 		return
 	}
 
-	if err := db.Model(&Profile{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
-		log.Printf("[DEBUG] Database error in updateProfile: %v", err)
-		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+	// Rename first: it is the change most likely to be refused. Both writes
+	// share a transaction so a failed update does not leave the rename, and
+	// its cooldown, behind.
+	err := db.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		profiles := store.NewProfileRepo(tx)
+		if newUsername != nil {
+			if err := profiles.Rename(r.Context(), userID, *newUsername, username.ChangeCooldown); err != nil {
+				return err
+			}
+		}
+		if len(updates) > 0 {
+			return profiles.Update(r.Context(), userID, updates)
+		}
+		return nil
+	})
+	var cooldown *store.RenameCooldownError
+	switch {
+	case err == nil:
+	case errors.Is(err, store.ErrUsernameTaken):
+		apierror.Write(w, r, apierror.UsernameTaken, "Username is already taken")
+		return
+	case errors.As(err, &cooldown):
+		wait := time.Until(cooldown.Until)
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		apierror.Write(w, r, apierror.UsernameCooldown,
+			"You can change your username again on "+cooldown.Until.UTC().Format("January 2, 2006"))
+		return
+	default:
+		apierror.InternalError(w, r, err, "Failed to update profile")
 		return
 	}
 
@@ -211,47 +303,3 @@ func updateProfile(w http.ResponseWriter, r *http.Request, userID string, db *go
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Profile updated successfully"})
 }
-
-func validateToken(r *http.Request) (string, error) {
-	jwtSecret := os.Getenv("SUPABASE_JWT_SECRET")
-	if jwtSecret == "" {
-		log.Println("[DEBUG] VALIDATION ERROR: SUPABASE_JWT_SECRET environment variable not set.")
-		return "", fmt.Errorf("server configuration error: missing JWT secret")
-	}
-
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		log.Println("[DEBUG] VALIDATION ERROR: Missing Authorization header.")
-		return "", fmt.Errorf("missing Authorization header")
-	}
-
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	if tokenString == authHeader {
-		log.Printf("[DEBUG] VALIDATION ERROR: Invalid Authorization header format. Header was: %s", authHeader)
-		return "", fmt.Errorf("invalid Authorization header format, must be 'Bearer <token>'")
-	}
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(jwtSecret), nil
-	})
-
-	if err != nil {
-		log.Printf("[DEBUG] VALIDATION ERROR: Failed to parse JWT token: %v. Token string: %s", err, tokenString)
-		return "", fmt.Errorf("invalid token: %w", err)
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID, ok := claims["sub"].(string)
-		if !ok {
-			log.Printf("[DEBUG] VALIDATION ERROR: 'sub' claim is missing or not a string. Claims: %v", claims)
-			return "", fmt.Errorf("invalid token claims: 'sub' (user ID) is missing or not a string")
-		}
-		return userID, nil
-	}
-
-	log.Println("[DEBUG] VALIDATION ERROR: Token is invalid for an unknown reason.")
-	return "", fmt.Errorf("invalid token")
-}
\ No newline at end of file
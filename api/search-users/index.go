@@ -2,127 +2,151 @@ package searchusers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"sync"
-	"time"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-)
 
-var (
-	db   *gorm.DB
-	once sync.Once
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/store"
 )
 
-// Profile struct matches the public.profiles table
-type Profile struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	Username  string    `gorm:"unique;not null" json:"username"`
-	FullName  string    `json:"full_name"`
-	AvatarURL string    `json:"avatar_url"`
-	Website   string    `json:"website"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
-
-func (Profile) TableName() string {
-    return "public.profiles"
-}
-
-// Connect initializes the database connection
-func Connect() (*gorm.DB, error) {
-	var err error
-	once.Do(func() {
-		if os.Getenv("VERCEL_ENV") == "" {
-			err = godotenv.Load()
-			if err != nil {
-				log.Println("Warning: .env file not found, relying on environment variables")
-			}
-		}
-		dsn := os.Getenv("DIRECT_URL") // Use DIRECT_URL for direct connection
-		if dsn == "" {
-			dsn = os.Getenv("DATABASE_URL") // Fallback to DATABASE_URL if DIRECT_URL is not set
-		}
-		if dsn == "" {
-			log.Fatal("FATAL: Neither DIRECT_URL nor DATABASE_URL environment variable is set")
-		}
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			PrepareStmt: false, // Disable prepared statement caching for serverless environment
-		})
-		if err != nil {
-			log.Fatalf("FATAL: Failed to connect to database: %v", err)
-		}
-
-		sqlDB, err := db.DB()
-		if err != nil {
-			log.Fatalf("FATAL: Failed to get underlying sql.DB: %v", err)
-		}
-		sqlDB.SetMaxIdleConns(1) // Keep very few idle connections
-		sqlDB.SetMaxOpenConns(1) // Limit total open connections
-		sqlDB.SetConnMaxLifetime(time.Minute) // Short lifetime
-
-		log.Println("Database connection successful and pool established.")
-	})
-	if err != nil {
-		return nil, err
-	}
-	return db, nil
-}
+// MinQueryLength is the shortest query accepted. Shorter ones match too much
+// to be useful and cannot use the trigram indexes.
+const MinQueryLength = 2
 
-// GetDB returns the existing database connection pool
-func GetDB() (*gorm.DB, error) {
-	if db == nil {
-		return Connect()
-	}
-	return db, nil
+// SearchResponse is returned for type=all: the first page of matching users
+// and of matching posts. Further pages are fetched with type=users or
+// type=posts.
+type SearchResponse struct {
+	Users store.PageResult[store.UserSearchResult] `json:"users"`
+	Posts store.PageResult[store.PostSearchResult] `json:"posts"`
 }
 
-// Handler is the entry point for the Vercel serverless function
+// Handler is the entry point for the Vercel serverless function. The "type"
+// query parameter selects users (the default), posts or all; users and posts
+// are each returned as a page of ranked matches. Search does not require a
+// token, but a signed-in caller gets is_following and liked_by_me filled in.
 func Handler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	log.Printf("[%s] Request received for /api/search-users", r.Method)
 
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.NotAllowed(w, r)
 		log.Printf("[ERROR] Method %s not allowed for /api/search-users", r.Method)
 		return
 	}
 
-	query := r.URL.Query().Get("q")
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
 	if query == "" {
-		http.Error(w, "Search query 'q' is required", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.InvalidParameter, "Search query 'q' is required")
 		log.Println("[ERROR] Search query 'q' is missing")
 		return
 	}
+	if utf8.RuneCountInString(query) < MinQueryLength {
+		apierror.Write(w, r, apierror.InvalidParameter, fmt.Sprintf("Search query 'q' must be at least %d characters", MinQueryLength))
+		log.Printf("[ERROR] Search query too short: %s", query)
+		return
+	}
+
+	searchType := r.URL.Query().Get("type")
+	if searchType == "" {
+		searchType = "users"
+	}
+	if searchType != "users" && searchType != "posts" && searchType != "all" {
+		apierror.Write(w, r, apierror.InvalidParameter, "Search type must be 'users', 'posts' or 'all'")
+		log.Printf("[ERROR] Invalid search type: %s", searchType)
+		return
+	}
+
+	page, err := store.ParsePage(r.URL.Query().Get("limit"), r.URL.Query().Get("cursor"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidCursor, "Invalid cursor")
+		log.Printf("[ERROR] Invalid cursor: %v", err)
+		return
+	}
+	if searchType == "all" && page.After != nil {
+		apierror.Write(w, r, apierror.InvalidParameter, "cursor requires type=users or type=posts")
+		log.Println("[ERROR] Cursor given with type=all")
+		return
+	}
 
-	log.Printf("[INFO] Searching for users with query: %s", query)
-	db, err := GetDB()
+	log.Printf("[INFO] Searching %s with query: %s", searchType, query)
+	db, err := store.GetDB()
 	if err != nil {
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
+		apierror.DatabaseError(w, r, err)
 		log.Printf("[ERROR] Database connection error: %v", err)
 		return
 	}
 
-	var users []Profile
-	searchQuery := "%" + query + "%"
+	var viewerID uuid.UUID
+	if principal, err := auth.Authenticate(r); err == nil {
+		viewerID = principal.UserID
+	}
 
-	log.Printf("[INFO] Executing database query: username ILIKE %s", searchQuery)
-	result := db.Where("username ILIKE ?", searchQuery).Find(&users)
-	if result.Error != nil {
-		http.Error(w, "Database query error", http.StatusInternalServerError)
-		log.Printf("[ERROR] Database query error: %v", result.Error)
-		return
+	var response SearchResponse
+	if searchType != "posts" {
+		results, err := store.NewProfileRepo(db).Search(r.Context(), viewerID, query, page)
+		if err != nil {
+			apierror.InternalError(w, r, err, "Database query error")
+			return
+		}
+		response.Users = store.NewPageResult(results, page, store.UserSearchCursor)
+		log.Printf("[INFO] Found %d users for query: %s", len(response.Users.Items), query)
+	}
+
+	if searchType != "users" {
+		response.Posts, err = searchPosts(r, db, viewerID, query, page)
+		if err != nil {
+			apierror.InternalError(w, r, err, "Database query error")
+			return
+		}
+		log.Printf("[INFO] Found %d posts for query: %s", len(response.Posts.Items), query)
+	}
+
+	var body interface{} = response
+	switch searchType {
+	case "users":
+		body = response.Users
+	case "posts":
+		body = response.Posts
 	}
 
-	log.Printf("[INFO] Found %d users for query: %s", len(users), query)
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(users); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		log.Printf("[ERROR] Failed to encode response: %v", err)
 	}
 	log.Println("[INFO] Response sent successfully.")
-}
\ No newline at end of file
+}
+
+// searchPosts runs the full-text post search and adds like stats.
+func searchPosts(r *http.Request, db *gorm.DB, viewerID uuid.UUID, query string, page store.Page) (store.PageResult[store.PostSearchResult], error) {
+	results, err := store.NewPostRepo(db).Search(r.Context(), viewerID, query, page)
+	if err != nil {
+		return store.PageResult[store.PostSearchResult]{}, err
+	}
+
+	posts := make([]store.Post, len(results))
+	for i := range results {
+		posts[i] = results[i].Post
+	}
+	if err := store.NewLikeRepo(db).FillPosts(r.Context(), viewerID, posts); err != nil {
+		return store.PageResult[store.PostSearchResult]{}, err
+	}
+	if err := store.NewMediaRepo(db).FillPosts(r.Context(), posts); err != nil {
+		return store.PageResult[store.PostSearchResult]{}, err
+	}
+	if err := store.NewLinkPreviewRepo(db).FillPosts(r.Context(), posts); err != nil {
+		return store.PageResult[store.PostSearchResult]{}, err
+	}
+	for i := range results {
+		results[i].Post = posts[i]
+	}
+
+	return store.NewPageResult(results, page, store.PostSearchCursor), nil
+}
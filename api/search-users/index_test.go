@@ -0,0 +1,191 @@
+package searchusers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+type userPage struct {
+	Items      []store.UserSearchResult `json:"items"`
+	NextCursor *string                  `json:"next_cursor"`
+}
+
+func searchUsers(t *testing.T, token, target string) userPage {
+	t.Helper()
+	rec := apitest.Do(t, Handler, http.MethodGet, target, token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: status = %d: %s", target, rec.Code, rec.Body)
+	}
+	var page userPage
+	apitest.Decode(t, rec, &page)
+	return page
+}
+
+// userSearch seeds users whose full names match a term unique to the test
+// at each ranking tier, and returns the term, the viewer and the expected
+// order of the matches.
+func userSearch(t *testing.T) (string, apitest.User, []apitest.User) {
+	t.Helper()
+	db := apitest.DB(t)
+	term := "srch" + strings.ReplaceAll(uuid.NewString(), "-", "")[:6]
+	viewer := apitest.CreateUser(t, db, "viewer")
+	exact := apitest.CreateUser(t, db, term)
+	prefix := apitest.CreateUser(t, db, term+"x")
+	followed := apitest.CreateUser(t, db, "b"+term)
+	substring := apitest.CreateUser(t, db, "a"+term)
+	blocked := apitest.CreateUser(t, db, "c"+term)
+	apitest.Follow(t, db, viewer.ID, followed.ID)
+	block := &store.Block{BlockerID: blocked.ID, BlockedID: viewer.ID}
+	if _, err := store.NewBlockRepo(db).Create(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+	return term, viewer, []apitest.User{exact, prefix, followed, substring}
+}
+
+// Exact matches come first, then prefix matches, then substring matches
+// with the ones the viewer follows ahead of the rest. Users on either side
+// of a block with the viewer are left out.
+func TestSearchUsersRanking(t *testing.T) {
+	term, viewer, want := userSearch(t)
+
+	page := searchUsers(t, viewer.Token, "/api/search-users?q="+url.QueryEscape(term))
+	if len(page.Items) != len(want) {
+		t.Fatalf("%d results, want %d: %+v", len(page.Items), len(want), page.Items)
+	}
+	for i, u := range want {
+		got := page.Items[i]
+		if got.ID != u.ID {
+			t.Errorf("result %d = %s, want %s", i, got.Username, u.Username)
+		}
+		if wantFollowing := i == 2; got.IsFollowing != wantFollowing {
+			t.Errorf("result %d is_following = %v, want %v", i, got.IsFollowing, wantFollowing)
+		}
+	}
+
+	// Anonymous callers get the same matches without the follow boost.
+	anon := searchUsers(t, "", "/api/search-users?q="+url.QueryEscape(term))
+	for _, r := range anon.Items {
+		if r.IsFollowing {
+			t.Errorf("anonymous result %s has is_following set", r.Username)
+		}
+	}
+}
+
+func TestSearchUsersPagination(t *testing.T) {
+	term, viewer, want := userSearch(t)
+
+	var got []uuid.UUID
+	target := "/api/search-users?limit=2&q=" + url.QueryEscape(term)
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("pagination did not terminate")
+		}
+		page := searchUsers(t, viewer.Token, target)
+		if len(page.Items) > 2 {
+			t.Fatalf("page has %d results, want at most 2", len(page.Items))
+		}
+		for _, r := range page.Items {
+			got = append(got, r.ID)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		target = "/api/search-users?limit=2&q=" + url.QueryEscape(term) + "&cursor=" + url.QueryEscape(*page.NextCursor)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("paged through %d results, want %d", len(got), len(want))
+	}
+	for i, u := range want {
+		if got[i] != u.ID {
+			t.Errorf("result %d = %s, want %s", i, got[i], u.ID)
+		}
+	}
+}
+
+func TestSearchUsersValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+	}{
+		{"missing query", http.MethodGet, "/api/search-users", http.StatusBadRequest},
+		{"short query", http.MethodGet, "/api/search-users?q=a", http.StatusBadRequest},
+		{"unknown type", http.MethodGet, "/api/search-users?q=abc&type=groups", http.StatusBadRequest},
+		{"invalid cursor", http.MethodGet, "/api/search-users?q=abc&cursor=nope", http.StatusBadRequest},
+		{"cursor with type=all", http.MethodGet, "/api/search-users?q=abc&type=all&cursor=" + store.Cursor{ID: uuid.New()}.Encode(), http.StatusBadRequest},
+		{"wrong method", http.MethodPost, "/api/search-users?q=abc", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, tt.method, tt.target, "", nil)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+type postPage struct {
+	Items      []store.PostSearchResult `json:"items"`
+	NextCursor *string                  `json:"next_cursor"`
+}
+
+// Post search ranks posts that use the term more often first, marks the
+// matches in the snippet and leaves out posts of private accounts the
+// caller does not follow.
+func TestSearchPosts(t *testing.T) {
+	db := apitest.DB(t)
+	term := "zebra" + strings.ReplaceAll(uuid.NewString(), "-", "")[:6]
+	viewer := apitest.CreateUser(t, db, "viewer")
+	author := apitest.CreateUser(t, db, "author")
+	private := apitest.CreateUser(t, db, "private")
+	if err := db.Model(&store.Profile{}).Where("id = ?", private.ID).Update("is_private", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	once := apitest.CreatePost(t, db, author.ID, "I saw a "+term+" today")
+	twice := apitest.CreatePost(t, db, author.ID, term+" after "+term+", all day long")
+	apitest.CreatePost(t, db, private.ID, "my own "+term+" <script>")
+	apitest.CreatePost(t, db, author.ID, "nothing to see here")
+
+	rec := apitest.Do(t, Handler, http.MethodGet, "/api/search-users?type=posts&q="+url.QueryEscape(term), viewer.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var page postPage
+	apitest.Decode(t, rec, &page)
+	if len(page.Items) != 2 || page.Items[0].ID != twice.ID || page.Items[1].ID != once.ID {
+		t.Fatalf("results = %+v, want the two public posts, most matches first", page.Items)
+	}
+	if !strings.Contains(page.Items[1].Snippet, "<mark>"+term+"</mark>") {
+		t.Errorf("snippet = %q, want the term marked", page.Items[1].Snippet)
+	}
+
+	// The private account's post matches for a follower, escaped.
+	apitest.Follow(t, db, viewer.ID, private.ID)
+	rec = apitest.Do(t, Handler, http.MethodGet, "/api/search-users?type=all&q="+url.QueryEscape(term), viewer.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("type=all: status = %d: %s", rec.Code, rec.Body)
+	}
+	var all SearchResponse
+	apitest.Decode(t, rec, &all)
+	if len(all.Posts.Items) != 3 {
+		t.Fatalf("%d posts for a follower, want 3", len(all.Posts.Items))
+	}
+	for _, p := range all.Posts.Items {
+		if strings.Contains(p.Snippet, "<script>") {
+			t.Errorf("snippet %q is not escaped", p.Snippet)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package stories
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/store"
+)
+
+const (
+	// DefaultTTL is how long a story stays up when the request does not say.
+	DefaultTTL = 24 * time.Hour
+	// MaxTTL caps the lifetime a client can ask for.
+	MaxTTL = 48 * time.Hour
+	// MinTTL keeps stories from expiring before anyone could see them.
+	MinTTL = time.Minute
+)
+
+// CreateStoryRequest is the body of POST /api/stories.
+type CreateStoryRequest struct {
+	MediaURL   string  `json:"media_url"`
+	MediaType  string  `json:"media_type"`
+	Caption    *string `json:"caption"`
+	TTLSeconds int     `json:"ttl_seconds"`
+}
+
+// ViewResponse is returned after recording a story view.
+type ViewResponse struct {
+	StoryID uuid.UUID `json:"story_id"`
+	Changed bool      `json:"changed"`
+}
+
+// Handler is the entry point for the /api/stories serverless function.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+	stories := store.NewStoryRepo(db)
+	follows := store.NewFollowRepo(db)
+	onStory := r.URL.Query().Get("story_id") != ""
+
+	switch {
+	case r.Method == http.MethodGet && onStory:
+		listViewers(w, r, stories, follows)
+	case r.Method == http.MethodGet:
+		getFeed(w, r, stories)
+	case r.Method == http.MethodPost && onStory:
+		recordView(w, r, stories, follows)
+	case r.Method == http.MethodPost:
+		createStory(w, r, stories)
+	default:
+		apierror.NotAllowed(w, r)
+	}
+}
+
+// getFeed serves GET /api/stories: the caller's and their followees' active
+// stories, grouped by author.
+func getFeed(w http.ResponseWriter, r *http.Request, stories store.StoryRepo) {
+	principal, _ := auth.FromContext(r.Context())
+
+	groups, err := stories.Feed(r.Context(), principal.UserID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch stories")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(groups)
+}
+
+func createStory(w http.ResponseWriter, r *http.Request, stories store.StoryRepo) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var req CreateStoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, r, apierror.InvalidRequestBody, "Invalid request body")
+		return
+	}
+
+	if u, err := url.Parse(req.MediaURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		apierror.Write(w, r, apierror.ValidationFailed, "media_url must be an http(s) URL")
+		return
+	}
+
+	if req.MediaType == "" {
+		req.MediaType = "image"
+	}
+	if req.MediaType != "image" && req.MediaType != "video" {
+		apierror.Write(w, r, apierror.ValidationFailed, "media_type must be 'image' or 'video'")
+		return
+	}
+
+	ttl := DefaultTTL
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl < MinTTL || ttl > MaxTTL {
+		apierror.Write(w, r, apierror.ValidationFailed, "ttl_seconds must be between 60 and 172800")
+		return
+	}
+
+	now := time.Now()
+	story := &store.Story{
+		ID:        uuid.New(),
+		UserID:    principal.UserID,
+		MediaURL:  req.MediaURL,
+		MediaType: req.MediaType,
+		Caption:   req.Caption,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := stories.Create(r.Context(), story); err != nil {
+		apierror.InternalError(w, r, err, "Failed to create story")
+		return
+	}
+
+	// Expired stories are already invisible; deleting them here keeps the table
+	// small without a scheduled job. A failure only delays the cleanup.
+	if n, err := stories.PurgeExpired(r.Context(), now); err != nil {
+		log.Printf("[ERROR] Failed to purge expired stories: %v", err)
+	} else if n > 0 {
+		log.Printf("[INFO] Purged %d expired stories", n)
+	}
+
+	// To return the created story with user info
+	created, err := stories.ByID(r.Context(), story.ID)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to retrieve created story")
+		return
+	}
+	created.ViewedByMe = true
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// recordView serves POST /api/stories?story_id=... Viewing a story more than
+// once, or viewing your own, changes nothing.
+func recordView(w http.ResponseWriter, r *http.Request, stories store.StoryRepo, follows store.FollowRepo) {
+	story, ok := activeStory(w, r, stories, follows)
+	if !ok {
+		return
+	}
+	principal, _ := auth.FromContext(r.Context())
+
+	resp := ViewResponse{StoryID: story.ID}
+	if story.UserID != principal.UserID {
+		changed, err := stories.RecordView(r.Context(), story.ID, principal.UserID)
+		if err != nil {
+			apierror.InternalError(w, r, err, "Failed to record story view")
+			return
+		}
+		resp.Changed = changed
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// listViewers serves GET /api/stories?story_id=...[&limit=...&cursor=...] to
+// the story's author.
+func listViewers(w http.ResponseWriter, r *http.Request, stories store.StoryRepo, follows store.FollowRepo) {
+	story, ok := activeStory(w, r, stories, follows)
+	if !ok {
+		return
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	if story.UserID != principal.UserID {
+		apierror.Write(w, r, apierror.Forbidden, "Only the author can see who viewed this story")
+		return
+	}
+
+	page, err := store.ParsePage(r.URL.Query().Get("limit"), r.URL.Query().Get("cursor"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidCursor, "Invalid cursor")
+		return
+	}
+
+	views, err := stories.Viewers(r.Context(), story.ID, page)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch story viewers")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(store.NewPageResult(views, page, store.StoryViewCursor))
+}
+
+// activeStory loads the unexpired story named by the "story_id" query
+// parameter. Stories the caller may not see, because the author is private
+// and not followed or one has blocked the other, get the same 404 as ones
+// that do not exist. It writes the error response and returns false
+// otherwise.
+func activeStory(w http.ResponseWriter, r *http.Request, stories store.StoryRepo, follows store.FollowRepo) (*store.Story, bool) {
+	storyID, err := uuid.Parse(r.URL.Query().Get("story_id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidParameter, "Invalid story_id")
+		return nil, false
+	}
+
+	story, err := stories.ByID(r.Context(), storyID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Write(w, r, apierror.StoryNotFound, "Story not found")
+			return nil, false
+		}
+		apierror.InternalError(w, r, err, "Database query error")
+		return nil, false
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	visible, err := follows.CanView(r.Context(), principal.UserID, &story.User)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Database query error")
+		return nil, false
+	}
+	if !visible {
+		apierror.Write(w, r, apierror.StoryNotFound, "Story not found")
+		return nil, false
+	}
+
+	return story, true
+}
@@ -0,0 +1,240 @@
+package stories
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+type viewersPage struct {
+	Items      []store.StoryView `json:"items"`
+	NextCursor *string           `json:"next_cursor"`
+}
+
+func postStory(t *testing.T, user apitest.User) store.Story {
+	t.Helper()
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/stories", user.Token,
+		CreateStoryRequest{MediaURL: "https://example.com/" + user.Username + ".jpg"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", rec.Code, rec.Body)
+	}
+	var story store.Story
+	apitest.Decode(t, rec, &story)
+	return story
+}
+
+func TestCreateStoryValidation(t *testing.T) {
+	db := apitest.DB(t)
+	user := apitest.CreateUser(t, db, "storyteller")
+
+	tests := []struct {
+		name string
+		body CreateStoryRequest
+		want int
+	}{
+		{"defaults", CreateStoryRequest{MediaURL: "https://example.com/a.jpg"}, http.StatusCreated},
+		{"video with ttl", CreateStoryRequest{MediaURL: "https://example.com/a.mp4", MediaType: "video", TTLSeconds: 3600}, http.StatusCreated},
+		{"not a URL", CreateStoryRequest{MediaURL: "a.jpg"}, http.StatusBadRequest},
+		{"unknown media type", CreateStoryRequest{MediaURL: "https://example.com/a.gif", MediaType: "gif"}, http.StatusBadRequest},
+		{"ttl too short", CreateStoryRequest{MediaURL: "https://example.com/a.jpg", TTLSeconds: 30}, http.StatusBadRequest},
+		{"ttl too long", CreateStoryRequest{MediaURL: "https://example.com/a.jpg", TTLSeconds: 3 * 86400}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, http.MethodPost, "/api/stories", user.Token, tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code != http.StatusCreated {
+				return
+			}
+			var story store.Story
+			apitest.Decode(t, rec, &story)
+			if story.UserID != user.ID || !story.ViewedByMe || !story.ExpiresAt.After(story.CreatedAt) {
+				t.Errorf("created %+v, want an unexpired story by the caller", story)
+			}
+		})
+	}
+}
+
+// The feed holds the caller's and their followees' stories, and a story
+// stops counting as unseen once it has been viewed.
+func TestFeedAndViews(t *testing.T) {
+	db := apitest.DB(t)
+	viewer := apitest.CreateUser(t, db, "viewer")
+	friend := apitest.CreateUser(t, db, "friend")
+	stranger := apitest.CreateUser(t, db, "stranger")
+	apitest.Follow(t, db, viewer.ID, friend.ID)
+
+	own := postStory(t, viewer)
+	friendStory := postStory(t, friend)
+	postStory(t, stranger)
+
+	feed := func() []store.StoryGroup {
+		t.Helper()
+		rec := apitest.Do(t, Handler, http.MethodGet, "/api/stories", viewer.Token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("feed: status = %d: %s", rec.Code, rec.Body)
+		}
+		var groups []store.StoryGroup
+		apitest.Decode(t, rec, &groups)
+		return groups
+	}
+
+	groups := feed()
+	if len(groups) != 2 {
+		t.Fatalf("feed has %d groups, want the caller's and the friend's", len(groups))
+	}
+	if groups[0].User.ID != friend.ID || !groups[0].HasUnseen {
+		t.Errorf("first group = %s (unseen %v), want the friend's unseen stories", groups[0].User.ID, groups[0].HasUnseen)
+	}
+	if groups[1].User.ID != viewer.ID || groups[1].HasUnseen || groups[1].Stories[0].ID != own.ID {
+		t.Errorf("second group = %s (unseen %v), want the caller's own, seen", groups[1].User.ID, groups[1].HasUnseen)
+	}
+
+	target := "/api/stories?story_id=" + friendStory.ID.String()
+	for i, wantChanged := range []bool{true, false} {
+		rec := apitest.Do(t, Handler, http.MethodPost, target, viewer.Token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("view %d: status = %d: %s", i+1, rec.Code, rec.Body)
+		}
+		var resp ViewResponse
+		apitest.Decode(t, rec, &resp)
+		if resp.StoryID != friendStory.ID || resp.Changed != wantChanged {
+			t.Errorf("view %d: %+v, want changed = %v", i+1, resp, wantChanged)
+		}
+	}
+
+	// Viewing your own story records nothing.
+	rec := apitest.Do(t, Handler, http.MethodPost, "/api/stories?story_id="+own.ID.String(), viewer.Token, nil)
+	var resp ViewResponse
+	apitest.Decode(t, rec, &resp)
+	if rec.Code != http.StatusOK || resp.Changed {
+		t.Errorf("own view: status = %d, changed = %v, want 200 and unchanged", rec.Code, resp.Changed)
+	}
+
+	for _, g := range feed() {
+		if g.HasUnseen {
+			t.Errorf("group of %s still unseen after viewing", g.User.ID)
+		}
+	}
+}
+
+// Only the author sees who viewed a story.
+func TestViewers(t *testing.T) {
+	db := apitest.DB(t)
+	author := apitest.CreateUser(t, db, "author")
+	first := apitest.CreateUser(t, db, "first")
+	second := apitest.CreateUser(t, db, "second")
+	story := postStory(t, author)
+	target := "/api/stories?story_id=" + story.ID.String()
+
+	for _, u := range []apitest.User{first, second} {
+		if rec := apitest.Do(t, Handler, http.MethodPost, target, u.Token, nil); rec.Code != http.StatusOK {
+			t.Fatalf("view by %s: status = %d: %s", u.Username, rec.Code, rec.Body)
+		}
+	}
+
+	rec := apitest.Do(t, Handler, http.MethodGet, target, author.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("viewers: status = %d: %s", rec.Code, rec.Body)
+	}
+	var page viewersPage
+	apitest.Decode(t, rec, &page)
+	got := map[uuid.UUID]bool{}
+	for _, v := range page.Items {
+		got[v.ViewerID] = true
+	}
+	if len(page.Items) != 2 || !got[first.ID] || !got[second.ID] {
+		t.Errorf("viewers = %+v, want first and second", page.Items)
+	}
+
+	if rec := apitest.Do(t, Handler, http.MethodGet, target, first.Token, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("viewers as a viewer: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+// Stories of private accounts the caller does not follow, and of accounts
+// on either side of a block, cannot be viewed and look like they do not
+// exist.
+func TestViewHiddenStory(t *testing.T) {
+	db := apitest.DB(t)
+	private := apitest.CreateUser(t, db, "private")
+	follower := apitest.CreateUser(t, db, "follower")
+	stranger := apitest.CreateUser(t, db, "stranger")
+	blocker := apitest.CreateUser(t, db, "blocker")
+	if err := db.Model(&store.Profile{}).Where("id = ?", private.ID).Update("is_private", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	apitest.Follow(t, db, follower.ID, private.ID)
+	block := &store.Block{BlockerID: blocker.ID, BlockedID: stranger.ID}
+	if _, err := store.NewBlockRepo(db).Create(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+	privateStory := postStory(t, private)
+	blockerStory := postStory(t, blocker)
+
+	tests := []struct {
+		name  string
+		user  apitest.User
+		story store.Story
+		want  int
+	}{
+		{"follower of private account", follower, privateStory, http.StatusOK},
+		{"stranger to private account", stranger, privateStory, http.StatusNotFound},
+		{"blocked by author", stranger, blockerStory, http.StatusNotFound},
+		{"public author", follower, blockerStory, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, http.MethodPost, "/api/stories?story_id="+tt.story.ID.String(), tt.user.Token, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			var views int64
+			err := db.Model(&store.StoryView{}).
+				Where("story_id = ? AND viewer_id = ?", tt.story.ID, tt.user.ID).
+				Count(&views).Error
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.want == http.StatusOK; (views == 1) != want {
+				t.Errorf("%d views recorded, want recorded = %v", views, want)
+			}
+		})
+	}
+}
+
+func TestStoryParameters(t *testing.T) {
+	db := apitest.DB(t)
+	user := apitest.CreateUser(t, db, "params")
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		want   int
+	}{
+		{"invalid story_id", http.MethodPost, "/api/stories?story_id=nope", user.Token, http.StatusBadRequest},
+		{"unknown story", http.MethodPost, "/api/stories?story_id=" + uuid.NewString(), user.Token, http.StatusNotFound},
+		{"anonymous", http.MethodGet, "/api/stories", "", http.StatusUnauthorized},
+		{"wrong method", http.MethodDelete, "/api/stories", user.Token, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apitest.Do(t, Handler, tt.method, tt.target, tt.token, nil)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
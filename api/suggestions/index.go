@@ -0,0 +1,73 @@
+package suggestions
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/store"
+)
+
+// SuggestionsResponse lists accounts for the caller to follow, best first.
+type SuggestionsResponse struct {
+	Items []store.Suggestion `json:"items"`
+}
+
+// Handler is the entry point for the /api/suggestions serverless function. It
+// serves GET /api/suggestions[?limit=...], the caller's "who to follow" list.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	auth.Middleware(route)(w, r)
+}
+
+func route(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		apierror.NotAllowed(w, r)
+		return
+	}
+
+	db, err := store.GetDB()
+	if err != nil {
+		apierror.DatabaseError(w, r, err)
+		return
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	list, err := store.NewSuggestionRepo(db).For(r.Context(), principal.UserID, parseLimit(r.URL.Query().Get("limit")))
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch suggestions")
+		return
+	}
+	if list == nil {
+		list = []store.Suggestion{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SuggestionsResponse{Items: list})
+}
+
+// parseLimit reads the "limit" query parameter. Like store.ParsePage, it
+// clamps a missing or out-of-range value rather than rejecting it.
+func parseLimit(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return store.DefaultSuggestionLimit
+	}
+	if n > store.MaxSuggestionLimit {
+		return store.MaxSuggestionLimit
+	}
+	return n
+}
@@ -0,0 +1,68 @@
+package suggestions
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+func TestSuggestions(t *testing.T) {
+	db := apitest.DB(t)
+	me := apitest.CreateUser(t, db, "me")
+	bob := apitest.CreateUser(t, db, "bob")
+	amy := apitest.CreateUser(t, db, "amy")
+	popular := apitest.CreateUser(t, db, "popular") // followed by bob and amy
+	niche := apitest.CreateUser(t, db, "niche")     // followed by bob
+	fan := apitest.CreateUser(t, db, "fan")         // follows me
+	blocked := apitest.CreateUser(t, db, "blocked") // followed by bob, but blocked
+	apitest.Follow(t, db, me.ID, bob.ID)
+	apitest.Follow(t, db, me.ID, amy.ID)
+	apitest.Follow(t, db, bob.ID, popular.ID)
+	apitest.Follow(t, db, amy.ID, popular.ID)
+	apitest.Follow(t, db, bob.ID, niche.ID)
+	apitest.Follow(t, db, fan.ID, me.ID)
+	apitest.Follow(t, db, bob.ID, blocked.ID)
+	apitest.Follow(t, db, bob.ID, me.ID) // me already follows bob
+	block := &store.Block{BlockerID: me.ID, BlockedID: blocked.ID}
+	if _, err := store.NewBlockRepo(db).Create(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+	// niche has posted, fan has not, so niche wins their one-point tie.
+	apitest.CreatePost(t, db, niche.ID, "hello")
+
+	rec := apitest.Do(t, Handler, http.MethodGet, "/api/suggestions", me.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp SuggestionsResponse
+	apitest.Decode(t, rec, &resp)
+
+	want := []struct {
+		id     uuid.UUID
+		reason string
+	}{
+		{popular.ID, "Followed by " + amy.Username + " and 1 other"},
+		{niche.ID, "Followed by " + bob.Username},
+		{fan.ID, "Follows you"},
+	}
+	if len(resp.Items) != len(want) {
+		t.Fatalf("got %d suggestions, want %d: %+v", len(resp.Items), len(want), resp.Items)
+	}
+	for i, w := range want {
+		got := resp.Items[i]
+		if got.ID != w.id {
+			t.Errorf("suggestion %d = %s, want %s", i, got.Username, w.id)
+		}
+		if got.Reason != w.reason {
+			t.Errorf("suggestion %d reason = %q, want %q", i, got.Reason, w.reason)
+		}
+	}
+}
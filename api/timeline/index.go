@@ -2,101 +2,23 @@ package timeline
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
-	"sync"
-	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-)
 
-var (
-	db        *gorm.DB
-	once      sync.Once
-	jwtSecret []byte
+	"go-common/apierror"
+	"go-common/auth"
+	"go-common/store"
 )
 
-type Post struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	Content   string    `gorm:"not null" json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	User      Profile   `gorm:"foreignKey:UserID" json:"user"`
-}
-
-type Profile struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey;" json:"id"`
-	Username  string    `json:"username"`
-	FullName  string    `json:"full_name"`
-	AvatarURL string    `json:"avatar_url"`
-}
-
-func (Profile) TableName() string {
-	return "profiles"
-}
-
-func Connect() (*gorm.DB, error) {
-	var err error
-	once.Do(func() {
-		if os.Getenv("VERCEL_ENV") == "" {
-			err = godotenv.Load("../../.env") // Assuming .env is at project root
-			if err != nil {
-				log.Println("Warning: .env file not found, relying on environment variables")
-			}
-		}
-		dsn := os.Getenv("DIRECT_URL") // Use DIRECT_URL for direct connection
-		if dsn == "" {
-			dsn = os.Getenv("DATABASE_URL") // Fallback to DATABASE_URL if DIRECT_URL is not set
-		}
-		if dsn == "" {
-			log.Fatal("FATAL: Neither DIRECT_URL nor DATABASE_URL environment variable is set")
-		}
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-			PrepareStmt: false, // Disable prepared statement caching for serverless environment
-			Logger:      logger.Default.LogMode(logger.Silent),
-		})
-		if err != nil {
-			log.Fatalf("FATAL: Failed to connect to database: %v", err)
-		}
-
-		sqlDB, err := db.DB()
-		if err != nil {
-			log.Fatalf("FATAL: Failed to get underlying sql.DB: %v", err)
-		}
-		sqlDB.SetMaxIdleConns(1) // Keep very few idle connections
-		sqlDB.SetMaxOpenConns(1) // Limit total open connections
-		sqlDB.SetConnMaxLifetime(time.Minute) // Short lifetime
-
-		jwtSecret = []byte(os.Getenv("SUPABASE_JWT_SECRET"))
-		if len(jwtSecret) == 0 {
-			log.Fatal("FATAL: SUPABASE_JWT_SECRET environment variable not set")
-		}
-
-		log.Println("Database connection successful and pool established.")
-	})
-	if err != nil {
-		return nil, err
-	}
-	return db, err
-}
-
-func GetDB() (*gorm.DB, error) {
-	if db == nil {
-		return Connect()
-	}
-	return db, nil
+func Handler(w http.ResponseWriter, r *http.Request) {
+	auth.Middleware(route)(w, r)
 }
 
-func Handler(w http.ResponseWriter, r *http.Request) {
-	db, err := GetDB()
+func route(w http.ResponseWriter, r *http.Request) {
+	db, err := store.GetDB()
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		apierror.DatabaseError(w, r, err)
 		return
 	}
 
@@ -104,61 +26,39 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		getTimeline(w, r, db)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.NotAllowed(w, r)
 	}
 }
 
 func getTimeline(w http.ResponseWriter, r *http.Request, db *gorm.DB) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	principal, _ := auth.FromContext(r.Context())
+	userID := principal.UserID
+
+	page, err := store.ParsePage(r.URL.Query().Get("limit"), r.URL.Query().Get("cursor"))
+	if err != nil {
+		apierror.Write(w, r, apierror.InvalidCursor, "Invalid cursor")
 		return
 	}
 
-	tokenString := authHeader[len("Bearer "):]
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return jwtSecret, nil
-	})
-	if err != nil || !token.Valid {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid Token"})
+	// Fetch posts from users that the current user is following AND the user's own posts
+	posts, err := store.NewPostRepo(db).Timeline(r.Context(), userID, page)
+	if err != nil {
+		apierror.InternalError(w, r, err, "Failed to fetch timeline")
 		return
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid Token Claims"})
-		return
+
+	err = store.NewLikeRepo(db).FillPosts(r.Context(), userID, posts)
+	if err == nil {
+		err = store.NewMediaRepo(db).FillPosts(r.Context(), posts)
 	}
-	userIDStr, ok := claims["sub"].(string)
-	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid User ID in Token"})
-		return
+	if err == nil {
+		err = store.NewLinkPreviewRepo(db).FillPosts(r.Context(), posts)
 	}
-	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid User ID Format"})
-		return
-	}
-	var posts []Post
-	// Fetch posts from users that the current user is following AND the user's own posts
-	if err := db.Distinct("posts.*, posts.created_at").
-		Preload("User").
-		Joins("LEFT JOIN follows ON posts.user_id = follows.following_id"). // Use LEFT JOIN to include user's own posts even if they don't follow anyone
-		Where("follows.follower_id = ? OR posts.user_id = ?", userID, userID). // Include posts from followed users OR user's own posts
-		Order("posts.created_at DESC").
-		Find(&posts).Error; err != nil {
-		log.Printf("Error fetching timeline: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to fetch timeline", "error": err.Error()})
+		apierror.InternalError(w, r, err, "Failed to fetch timeline")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(posts)
+	json.NewEncoder(w).Encode(store.NewPageResult(posts, page, store.PostCursor))
 }
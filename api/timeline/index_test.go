@@ -0,0 +1,163 @@
+package timeline
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+type timelinePage struct {
+	Items      []store.Post `json:"items"`
+	NextCursor *string      `json:"next_cursor"`
+}
+
+// The timeline holds the caller's own posts and those of the users they
+// follow, newest first, and nobody else's.
+func TestTimelineComposition(t *testing.T) {
+	db := apitest.DB(t)
+	viewer := apitest.CreateUser(t, db, "viewer")
+	friend := apitest.CreateUser(t, db, "friend")
+	stranger := apitest.CreateUser(t, db, "stranger")
+	follower := apitest.CreateUser(t, db, "follower")
+	apitest.Follow(t, db, viewer.ID, friend.ID)
+	apitest.Follow(t, db, follower.ID, viewer.ID)
+
+	tests := []struct {
+		name   string
+		author uuid.UUID
+		want   bool
+	}{
+		{"own post", viewer.ID, true},
+		{"followed user's post", friend.ID, true},
+		{"stranger's post", stranger.ID, false},
+		{"follower's post", follower.ID, false},
+		{"followed user's second post", friend.ID, true},
+	}
+	var want []uuid.UUID
+	excluded := map[uuid.UUID]string{}
+	for _, tt := range tests {
+		post := apitest.CreatePost(t, db, tt.author, tt.name)
+		if tt.want {
+			want = append([]uuid.UUID{post.ID}, want...)
+		} else {
+			excluded[post.ID] = tt.name
+		}
+	}
+
+	rec := apitest.Do(t, Handler, http.MethodGet, "/api/timeline", viewer.Token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var page timelinePage
+	apitest.Decode(t, rec, &page)
+
+	var got []uuid.UUID
+	for _, p := range page.Items {
+		if name, ok := excluded[p.ID]; ok {
+			t.Errorf("timeline contains %s", name)
+		}
+		got = append(got, p.ID)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("timeline has %d posts, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("post %d = %s, want %s (newest first)", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTimelinePagination(t *testing.T) {
+	db := apitest.DB(t)
+	viewer := apitest.CreateUser(t, db, "pager")
+	for _, content := range []string{"one", "two", "three"} {
+		apitest.CreatePost(t, db, viewer.ID, content)
+	}
+
+	var contents []string
+	target := "/api/timeline?limit=2"
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatal("pagination did not end")
+		}
+		rec := apitest.Do(t, Handler, http.MethodGet, target, viewer.Token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var page timelinePage
+		apitest.Decode(t, rec, &page)
+		for _, p := range page.Items {
+			contents = append(contents, p.Content)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		target = "/api/timeline?limit=2&cursor=" + url.QueryEscape(*page.NextCursor)
+	}
+
+	want := []string{"three", "two", "one"}
+	if len(contents) != len(want) {
+		t.Fatalf("got %v, want %v", contents, want)
+	}
+	for i := range want {
+		if contents[i] != want[i] {
+			t.Fatalf("got %v, want %v", contents, want)
+		}
+	}
+}
+
+func TestTimelineRequiresAuth(t *testing.T) {
+	rec := apitest.Do(t, Handler, http.MethodGet, "/api/timeline", "", nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+// Muting a followed user hides their posts from the timeline; unmuting brings
+// them back.
+func TestTimelineHidesMuted(t *testing.T) {
+	db := apitest.DB(t)
+	viewer := apitest.CreateUser(t, db, "muter")
+	muted := apitest.CreateUser(t, db, "muted")
+	apitest.Follow(t, db, viewer.ID, muted.ID)
+	post := apitest.CreatePost(t, db, muted.ID, "muted post")
+
+	contains := func() bool {
+		rec := apitest.Do(t, Handler, http.MethodGet, "/api/timeline", viewer.Token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var page timelinePage
+		apitest.Decode(t, rec, &page)
+		for _, p := range page.Items {
+			if p.ID == post.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	mutes := store.NewMuteRepo(db)
+	if _, err := mutes.Create(context.Background(), &store.Mute{MuterID: viewer.ID, MutedID: muted.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if contains() {
+		t.Error("timeline shows a muted user's post")
+	}
+	if _, err := mutes.Delete(context.Background(), viewer.ID, muted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !contains() {
+		t.Error("timeline hides an unmuted user's post")
+	}
+}
@@ -49,11 +49,11 @@ export default function HomePage() {
 
     if (!response.ok) {
       const errorData = await response.json();
-      throw new Error(errorData.message || 'Failed to fetch posts.');
+      throw new Error(errorData.error?.message || 'Failed to fetch posts.');
     }
 
-    const data: Post[] = await response.json();
-    setPosts(data || []);
+    const data: { items: Post[]; next_cursor: string | null } = await response.json();
+    setPosts(data?.items || []);
   }, []);
 
   useEffect(() => {
@@ -112,7 +112,7 @@ export default function HomePage() {
 
       if (!response.ok) {
         const errorData = await response.json();
-        throw new Error(errorData.message || 'Failed to delete post.');
+        throw new Error(errorData.error?.message || 'Failed to delete post.');
       }
 
       setPosts(prev => prev.filter(p => p.id !== postToDelete.id));
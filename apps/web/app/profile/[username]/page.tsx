@@ -2,7 +2,7 @@
 
 import { createClientComponentClient } from '@supabase/auth-helpers-nextjs';
 import { useRouter } from 'next/navigation';
-import { useEffect, useState, useCallback } from 'react';
+import { useEffect, useState } from 'react';
 import { motion, AnimatePresence } from 'framer-motion';
 import { UserCircle, LogOut, Edit, ArrowLeft, Rss, Users, Heart, MessageCircle, Share2 } from 'lucide-react';
 import LoadingState from '../../../components/LoadingState';
@@ -18,6 +18,9 @@ interface User {
   full_name: string;
   username: string;
   avatar_url: string;
+  followers_count?: number;
+  following_count?: number;
+  posts_count?: number;
 }
 
 interface Post {
@@ -110,26 +113,6 @@ export default function ProfileViewPage({ params }: { params: { username: string
       posts: { data: [], isLoading: false, error: null },     // Initialize with false
   });
 
-  const fetchFollowData = useCallback(async (type: 'followers' | 'following', userId: string) => {
-    setTabData(prev => ({ ...prev, [type]: { ...prev[type], isLoading: true, error: null } }));
-
-    try {
-      const response = await fetch(`/api/${type}?user_id=${userId}`);
-      if (!response.ok) {
-        throw new Error(`Failed to fetch ${type}`);
-      }
-      const data = await response.json();
-      const processedData = data === null ? [] : data;
-
-      if (!Array.isArray(processedData)) {
-        throw new Error(`API returned unexpected data for ${type}. Received: ${JSON.stringify(data)}`);
-      }
-      setTabData(prev => ({ ...prev, [type]: { data: processedData, isLoading: false, error: null } }));
-    } catch (err: any) {
-      setTabData(prev => ({ ...prev, [type]: { ...prev[type], isLoading: false, error: err.message } }));
-    }
-  }, []);
-
   const handlePostUpdated = (updatedPost: Post) => {
     setTabData(prev => ({
       ...prev,
@@ -163,7 +146,7 @@ export default function ProfileViewPage({ params }: { params: { username: string
 
       if (!response.ok) {
         const errorData = await response.json();
-        throw new Error(errorData.message || 'Failed to delete post.');
+        throw new Error(errorData.error?.message || 'Failed to delete post.');
       }
 
       setTabData(prev => ({
@@ -222,10 +205,16 @@ export default function ProfileViewPage({ params }: { params: { username: string
 
         if (!response.ok) {
           const errorData = await response.json();
-          throw new Error(errorData.message || 'Failed to fetch profile.');
+          throw new Error(errorData.error?.message || 'Failed to fetch profile.');
         }
 
         const data = await response.json();
+
+        // fetch follows the API's redirect for a changed username; show the
+        // current one in the address bar.
+        if (data.username && data.username.toLowerCase() !== String(params.username).toLowerCase()) {
+          router.replace(`/profile/${data.username}`);
+        }
         
         // Re-introducing isOwnProfile logic
         if (currentUser && data.id === currentUser.id) {
@@ -237,12 +226,12 @@ export default function ProfileViewPage({ params }: { params: { username: string
         }
         
         const [followersResponse, followingResponse] = await Promise.all([
-          fetch(`/api/followers?user_id=${data.id}`),
-          fetch(`/api/following?user_id=${data.id}`),
+          fetch(`/api/followers?user_id=${data.id}`, { headers: { 'Authorization': `Bearer ${token}` } }),
+          fetch(`/api/followers?direction=following&user_id=${data.id}`, { headers: { 'Authorization': `Bearer ${token}` } }),
         ]);
 
-        const followersData = followersResponse.ok ? await followersResponse.json() : [];
-        const followingData = followingResponse.ok ? await followingResponse.json() : [];
+        const followersData = followersResponse.ok ? (await followersResponse.json()).items : [];
+        const followingData = followingResponse.ok ? (await followingResponse.json()).items : [];
 
         setTabData({
           followers: { data: followersData === null ? [] : followersData, isLoading: false, error: null },
@@ -314,11 +303,11 @@ export default function ProfileViewPage({ params }: { params: { username: string
                     <span className="text-text-muted ml-1">Posts</span>
                   </div>
                   <div>
-                    <span className="font-bold">{tabData.followers.isLoading ? '...' : tabData.followers.data.length}</span>
+                    <span className="font-bold">{tabData.followers.isLoading ? '...' : profile?.followers_count ?? tabData.followers.data.length}</span>
                     <span className="text-text-muted ml-1">Followers</span>
                   </div>
                   <div>
-                    <span className="font-bold">{tabData.following.isLoading ? '...' : tabData.following.data.length}</span>
+                    <span className="font-bold">{tabData.following.isLoading ? '...' : profile?.following_count ?? tabData.following.data.length}</span>
                     <span className="text-text-muted ml-1">Following</span>
                   </div>
                 </div>
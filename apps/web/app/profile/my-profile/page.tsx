@@ -2,7 +2,7 @@
 
 import { createClientComponentClient } from '@supabase/auth-helpers-nextjs';
 import { useRouter } from 'next/navigation';
-import { useEffect, useState, useCallback } from 'react';
+import { useEffect, useState } from 'react';
 import { motion, AnimatePresence } from 'framer-motion';
 import { UserCircle, LogOut, Edit, ArrowLeft, Rss, Users, Heart, MessageCircle, Share2 } from 'lucide-react';
 import LoadingState from '../../../components/LoadingState';
@@ -18,6 +18,9 @@ interface User {
   full_name: string;
   username: string;
   avatar_url: string;
+  followers_count?: number;
+  following_count?: number;
+  posts_count?: number;
 }
 
 interface Post {
@@ -109,26 +112,6 @@ export default function MyProfilePage() {
       posts: { data: [], isLoading: false, error: null },     // Initialize with false
   });
 
-  const fetchFollowData = useCallback(async (type: 'followers' | 'following', userId: string) => {
-    setTabData(prev => ({ ...prev, [type]: { ...prev[type], isLoading: true, error: null } }));
-
-    try {
-      const response = await fetch(`/api/${type}?user_id=${userId}`);
-      if (!response.ok) {
-        throw new Error(`Failed to fetch ${type}`);
-      }
-      const data = await response.json();
-      const processedData = data === null ? [] : data;
-
-      if (!Array.isArray(processedData)) {
-        throw new Error(`API returned unexpected data for ${type}. Received: ${JSON.stringify(data)}`);
-      }
-      setTabData(prev => ({ ...prev, [type]: { data: processedData, isLoading: false, error: null } }));
-    } catch (err: any) {
-      setTabData(prev => ({ ...prev, [type]: { ...prev[type], isLoading: false, error: err.message } }));
-    }
-  }, []);
-
   const handlePostUpdated = (updatedPost: Post) => {
     setTabData(prev => ({
       ...prev,
@@ -162,7 +145,7 @@ export default function MyProfilePage() {
 
       if (!response.ok) {
         const errorData = await response.json();
-        throw new Error(errorData.message || 'Failed to delete post.');
+        throw new Error(errorData.error?.message || 'Failed to delete post.');
       }
 
       setTabData(prev => ({
@@ -216,7 +199,7 @@ export default function MyProfilePage() {
 
         if (!profileResponse.ok) {
           const errorData = await profileResponse.json();
-          throw new Error(errorData.message || 'Failed to fetch profile.');
+          throw new Error(errorData.error?.message || 'Failed to fetch profile.');
         }
 
         const profileData = await profileResponse.json();
@@ -224,12 +207,12 @@ export default function MyProfilePage() {
         
         // Fetch followers, following, and posts concurrently for the current user
         const [followersResponse, followingResponse] = await Promise.all([
-          fetch(`/api/followers?user_id=${profileData.id}`),
-          fetch(`/api/following?user_id=${profileData.id}`),
+          fetch(`/api/followers?user_id=${profileData.id}`, { headers: { 'Authorization': `Bearer ${token}` } }),
+          fetch(`/api/followers?direction=following&user_id=${profileData.id}`, { headers: { 'Authorization': `Bearer ${token}` } }),
         ]);
 
-        const followersData = followersResponse.ok ? await followersResponse.json() : [];
-        const followingData = followingResponse.ok ? await followingResponse.json() : [];
+        const followersData = followersResponse.ok ? (await followersResponse.json()).items : [];
+        const followingData = followingResponse.ok ? (await followingResponse.json()).items : [];
 
         setTabData({
           followers: { data: followersData === null ? [] : followersData, isLoading: false, error: null },
@@ -305,11 +288,11 @@ export default function MyProfilePage() {
                     <span className="text-text-muted ml-1">Posts</span>
                   </div>
                   <div>
-                    <span className="font-bold">{tabData.followers.isLoading ? '...' : tabData.followers.data.length}</span>
+                    <span className="font-bold">{tabData.followers.isLoading ? '...' : profile?.followers_count ?? tabData.followers.data.length}</span>
                     <span className="text-text-muted ml-1">Followers</span>
                   </div>
                   <div>
-                    <span className="font-bold">{tabData.following.isLoading ? '...' : tabData.following.data.length}</span>
+                    <span className="font-bold">{tabData.following.isLoading ? '...' : profile?.following_count ?? tabData.following.data.length}</span>
                     <span className="text-text-muted ml-1">Following</span>
                   </div>
                 </div>
@@ -51,29 +51,15 @@ const SearchPage = () => {
     setError(null);
 
     try {
-      const response = await fetch(`/api/search-users?q=${encodeURIComponent(query)}`);
+      // The token lets the API fill in is_following for each result.
+      const response = await fetch(`/api/search-users?q=${encodeURIComponent(query)}`, {
+        headers: { 'Authorization': `Bearer ${(await supabase.auth.getSession()).data.session?.access_token}` },
+      });
       if (!response.ok) {
         throw new Error('Failed to fetch search results.');
       }
       const data = await response.json();
-
-      // Fetch current user's following list
-      const followingResponse = await fetch(`/api/following?user_id=${currentUserId}`, {
-        headers: { 'Authorization': `Bearer ${(await supabase.auth.getSession()).data.session?.access_token}` },
-      });
-      let followingIds: Set<string> = new Set();
-      if (followingResponse.ok) {
-        const followingData = await followingResponse.json();
-        if (Array.isArray(followingData)) {
-          followingData.forEach((f: any) => followingIds.add(f.id));
-        }
-      }
-
-      const usersWithFollowStatus = data.map((user: any) => ({
-        ...user,
-        is_following: followingIds.has(user.id),
-      }));
-      setResults(usersWithFollowStatus);
+      setResults(data?.items || []);
     } catch (err: any) {
       setError(err.message);
     } finally {
@@ -54,7 +54,7 @@ export default function EditProfilePage() {
 
         if (!response.ok) {
           const errorData = await response.json();
-          throw new Error(errorData.message || 'Failed to fetch profile.');
+          throw new Error(errorData.error?.message || 'Failed to fetch profile.');
         }
 
         const data = await response.json();
@@ -109,18 +109,13 @@ export default function EditProfilePage() {
       setUsernameError('Username cannot be empty.');
       return false;
     }
-    if (username.length === 1) {
-      const alphaRegex = /^[a-zA-Z]$/;
-      if (!alphaRegex.test(username)) {
-        setUsernameError('Single character username must be an alphabet letter.');
-        return false;
-      }
-    } else {
-      const usernameRegex = /^[a-zA-Z0-9_.-]+$/;
-      if (!usernameRegex.test(username)) {
-        setUsernameError('Username can only contain alphanumeric, _, -, or .');
-        return false;
-      }
+    if (username.length < 3) {
+      setUsernameError('Username must be at least 3 characters.');
+      return false;
+    }
+    if (!/^[a-zA-Z0-9][a-zA-Z0-9_.-]*$/.test(username)) {
+      setUsernameError('Username can only contain letters, digits, _, - or ., and must start with a letter or digit.');
+      return false;
     }
     if (username.length > 20) {
       setUsernameError('Username cannot exceed 20 characters.');
@@ -137,14 +132,15 @@ export default function EditProfilePage() {
     setUsernameError(null);
     setUsernameAvailable(null);
     try {
-      const response = await fetch(`/api/check-username?username=${profile.username}`);
+      const response = await fetch(`/api/check-username?username=${encodeURIComponent(profile.username)}&full_name=${encodeURIComponent(profile.full_name || '')}`);
       const data = await response.json();
       if (!response.ok) {
-        throw new Error(data.message || 'Error checking username');
+        throw new Error(data.error?.message || 'Error checking username');
       }
       setUsernameAvailable(data.available);
       if (!data.available) {
-        setUsernameError('Username is already taken.');
+        const suggestions = data.suggestions?.length ? ` Try ${data.suggestions.join(', ')}.` : '';
+        setUsernameError(`${data.message || 'Username is not available.'}${suggestions}`);
       }
     } catch (err: any) {
       setUsernameError(err.message || 'Failed to check username availability. Please try again.');
@@ -193,15 +189,15 @@ export default function EditProfilePage() {
         const errorData = await response.json();
         let userFriendlyMessage = 'Failed to update profile. Please try again.';
 
-        if (errorData.message) {
-          if (errorData.message.includes('username already taken')) {
+        if (errorData.error?.code === 'unauthorized') {
+          userFriendlyMessage = 'You are not authorized to perform this action. Please sign in again.';
+        } else if (errorData.error?.message) {
+          if (errorData.error.code === 'username_taken') {
             userFriendlyMessage = 'The username you entered is already taken. Please choose a different one.';
-          } else if (errorData.message.includes('invalid full name')) {
+          } else if (errorData.error.message.includes('invalid full name')) {
             userFriendlyMessage = 'Full name is invalid. It must be at least 3 characters long.';
-          } else if (errorData.message.includes('Unauthorized')) {
-            userFriendlyMessage = 'You are not authorized to perform this action. Please sign in again.';
           } else {
-            userFriendlyMessage = errorData.message; // Fallback to backend message if not specific
+            userFriendlyMessage = errorData.error.message; // Fallback to backend message if not specific
           }
         }
         throw new Error(userFriendlyMessage);
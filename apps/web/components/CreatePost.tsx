@@ -62,7 +62,7 @@ export default function CreatePost({ onPostCreated, onClose, isMobile }: CreateP
 
       if (!response.ok) {
         const errorData = await response.json();
-        throw new Error(errorData.message || 'Failed to create post.');
+        throw new Error(errorData.error?.message || 'Failed to create post.');
       }
 
       const newPost = await response.json();
@@ -75,7 +75,7 @@ export default function EditPostModal({ isOpen, post, onPostUpdated, onClose }:
 
       if (!response.ok) {
         const errorData = await response.json();
-        throw new Error(errorData.message || 'Failed to update post.');
+        throw new Error(errorData.error?.message || 'Failed to update post.');
       }
 
       const updatedPost = await response.json();
@@ -12,11 +12,31 @@ import PostOptionsMenu from './PostOptionsMenu';
 import ConfirmationModal from './ConfirmationModal';
 import EditPostModal from './EditPostModal';
 
+interface Attachment {
+  id: string;
+  type: 'image' | 'video';
+  url: string;
+  thumbnail_url: string | null;
+  width: number;
+  height: number;
+  alt_text: string;
+}
+
+interface LinkPreview {
+  url: string;
+  title: string;
+  description: string;
+  image_url: string;
+  site_name: string;
+}
+
 interface Post {
   id: string;
   user_id: string;
   content: string;
   created_at: string;
+  attachments?: Attachment[];
+  link_preview?: LinkPreview | null;
   user: {
     id: string;
     username: string;
@@ -98,6 +118,49 @@ const Timeline = ({ posts, currentUserId, onUpdatePost, onDeletePost, onReportPo
                   />
                 </div>
                 <p className="text-text-light text-sm md:text-base leading-relaxed mb-4 whitespace-pre-wrap">{post.content}</p>
+                {post.link_preview && (!post.attachments || post.attachments.length === 0) && (
+                  <a
+                    href={post.link_preview.url}
+                    target="_blank"
+                    rel="noopener noreferrer nofollow"
+                    className="block mb-4 overflow-hidden rounded-lg border border-border-subtle hover:border-accent-main transition-colors duration-200"
+                  >
+                    {post.link_preview.image_url && (
+                      // eslint-disable-next-line @next/next/no-img-element
+                      <img src={post.link_preview.image_url} alt="" loading="lazy" className="w-full max-h-64 object-cover" />
+                    )}
+                    <div className="p-3">
+                      {post.link_preview.site_name && (
+                        <p className="text-text-muted text-xs uppercase tracking-wide">{post.link_preview.site_name}</p>
+                      )}
+                      {post.link_preview.title && <p className="text-text-light font-semibold">{post.link_preview.title}</p>}
+                      {post.link_preview.description && (
+                        <p className="text-text-muted text-sm line-clamp-2">{post.link_preview.description}</p>
+                      )}
+                    </div>
+                  </a>
+                )}
+                {post.attachments && post.attachments.length > 0 && (
+                  <div className={`grid gap-2 mb-4 ${post.attachments.length > 1 ? 'grid-cols-2' : 'grid-cols-1'}`}>
+                    {post.attachments.map((attachment) =>
+                      attachment.type === 'video' ? (
+                        <video key={attachment.id} src={attachment.url} controls className="w-full rounded-lg" aria-label={attachment.alt_text || undefined} />
+                      ) : (
+                        <a key={attachment.id} href={attachment.url} target="_blank" rel="noopener noreferrer">
+                          {/* eslint-disable-next-line @next/next/no-img-element */}
+                          <img
+                            src={attachment.thumbnail_url || attachment.url}
+                            alt={attachment.alt_text}
+                            width={attachment.width}
+                            height={attachment.height}
+                            loading="lazy"
+                            className="w-full h-auto rounded-lg object-cover"
+                          />
+                        </a>
+                      )
+                    )}
+                  </div>
+                )}
                 <div className="flex space-x-4 md:space-x-6 text-text-muted text-sm">
                   <button className="flex items-center hover:text-accent-main transition-colors duration-200">
                     <Heart className="w-4 h-4 md:w-5 md:h-5 mr-1" />
@@ -4,6 +4,14 @@ const nextConfig = {
     NEXT_PUBLIC_SUPABASE_URL: process.env.NEXT_PUBLIC_SUPABASE_URL,
     NEXT_PUBLIC_SUPABASE_ANON_KEY: process.env.NEXT_PUBLIC_SUPABASE_ANON_KEY,
   },
+  // Proxy /api to cmd/devserver when it is running instead of Vercel CLI.
+  async rewrites() {
+    const devServer = process.env.API_DEV_SERVER_URL;
+    if (!devServer) {
+      return [];
+    }
+    return [{ source: '/api/:path*', destination: `${devServer}/api/:path*` }];
+  },
 };
 
-module.exports = nextConfig;
\ No newline at end of file
+module.exports = nextConfig;
@@ -0,0 +1,80 @@
+module devserver
+
+go 1.21
+
+require (
+	attachments v0.0.0
+	avatar v0.0.0
+	blocks v0.0.0
+	check-username v0.0.0
+	comments v0.0.0
+	follow v0.0.0
+	follow-requests v0.0.0
+	followers v0.0.0
+	go-common v0.0.0
+	health v0.0.0
+	likes v0.0.0
+	messages v0.0.0
+	notifications v0.0.0
+	posts v0.0.0
+	profile v0.0.0
+	search-users v0.0.0
+	stories v0.0.0
+	suggestions v0.0.0
+	timeline v0.0.0
+)
+
+require (
+	github.com/golang-jwt/jwt/v5 v5.3.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/pgx/v5 v5.6.0 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/joho/godotenv v1.5.1 // indirect
+	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	gorm.io/driver/postgres v1.6.0 // indirect
+	gorm.io/gorm v1.30.1 // indirect
+)
+
+replace attachments => ../../api/attachments
+
+replace avatar => ../../api/avatar
+
+replace blocks => ../../api/blocks
+
+replace check-username => ../../api/check-username
+
+replace comments => ../../api/comments
+
+replace follow => ../../api/follow
+
+replace follow-requests => ../../api/follow-requests
+
+replace followers => ../../api/followers
+
+replace health => ../../api/health
+
+replace likes => ../../api/likes
+
+replace messages => ../../api/messages
+
+replace notifications => ../../api/notifications
+
+replace posts => ../../api/posts
+
+replace profile => ../../api/profile
+
+replace search-users => ../../api/search-users
+
+replace stories => ../../api/stories
+
+replace suggestions => ../../api/suggestions
+
+replace timeline => ../../api/timeline
+
+replace go-common => ../../packages/go-common
@@ -0,0 +1,127 @@
+// Command devserver serves every api/* function from one process, mounted at
+// the paths Vercel gives them, so the backend can be run locally with
+//
+//	go -C cmd/devserver run .
+//
+// Environment is read the way store.Connect reads it (.env in the working
+// directory or two levels up). To use it from `next dev`, set
+// API_DEV_SERVER_URL=http://localhost:8080 in apps/web/.env.local.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"attachments"
+	"avatar"
+	"blocks"
+	checkusername "check-username"
+	"comments"
+	"follow"
+	followrequests "follow-requests"
+	"followers"
+	"health"
+	"likes"
+	"messages"
+	"notifications"
+	"posts"
+	"profile"
+	searchusers "search-users"
+	"stories"
+	"suggestions"
+	"timeline"
+
+	"go-common/storage"
+	"go-common/store"
+)
+
+// routes maps each function's path to its Handler. Keep it in step with the
+// builds in vercel.json; routes_test.go checks that it is.
+var routes = map[string]http.HandlerFunc{
+	"/api/attachments":     attachments.Handler,
+	"/api/avatar":          avatar.Handler,
+	"/api/blocks":          blocks.Handler,
+	"/api/check-username":  checkusername.Handler,
+	"/api/comments":        comments.Handler,
+	"/api/follow":          follow.Handler,
+	"/api/follow-requests": followrequests.Handler,
+	"/api/followers":       followers.Handler,
+	"/api/health":          health.Handler,
+	"/api/likes":           likes.Handler,
+	"/api/messages":        messages.Handler,
+	"/api/notifications":   notifications.Handler,
+	"/api/posts":           posts.Handler,
+	"/api/profile":         profile.Handler,
+	"/api/search-users":    searchusers.Handler,
+	"/api/stories":         stories.Handler,
+	"/api/suggestions":     suggestions.Handler,
+	"/api/timeline":        timeline.Handler,
+}
+
+func main() {
+	store.LoadEnv()
+
+	defaultAddr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		defaultAddr = ":" + port
+	}
+	addr := flag.String("addr", defaultAddr, "address to listen on")
+	flag.Parse()
+
+	log.Printf("[INFO] Serving %d functions on %s", len(routes), *addr)
+	if err := http.ListenAndServe(*addr, newMux()); err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+}
+
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	for path, handler := range routes {
+		mux.Handle(path, logRequests(handler))
+	}
+	// Files written by the local storage driver, e.g. avatars.
+	if files, err := storage.FromEnv(); err == nil {
+		if local, ok := files.(*storage.Local); ok && strings.HasPrefix(local.BaseURL, "/") {
+			prefix := local.BaseURL + "/"
+			mux.Handle(prefix, logRequests(http.StripPrefix(prefix, http.FileServer(http.Dir(local.Dir)))))
+		}
+	}
+	return mux
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// logRequests logs one line per request with its status and duration.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		log.Printf("[INFO] %s %s -> %d (%s)", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Every Go function deployed by vercel.json must be mounted, and nothing else.
+func TestRoutesMatchVercelConfig(t *testing.T) {
+	raw, err := os.ReadFile("../../vercel.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Builds []struct {
+			Src string `json:"src"`
+			Use string `json:"use"`
+		} `json:"builds"`
+	}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{}
+	for _, b := range config.Builds {
+		if b.Use != "@vercel/go" {
+			continue
+		}
+		want["/"+strings.TrimSuffix(b.Src, "/index.go")] = true
+	}
+
+	for path := range want {
+		if routes[path] == nil {
+			t.Errorf("%s is built by vercel.json but not mounted", path)
+		}
+	}
+	for path := range routes {
+		if !want[path] {
+			t.Errorf("%s is mounted but not built by vercel.json", path)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Command migrate applies the SQL migrations in supabase/migrations and tracks
+// them in public.schema_migrations.
+//
+//	go -C cmd/migrate run . up              apply pending migrations
+//	go -C cmd/migrate run . down [-n 1]     roll back the newest migrations
+//	go -C cmd/migrate run . status          list applied and pending migrations
+//	go -C cmd/migrate run . adopt [-to V]   mark existing migrations applied without running them
+//	go -C cmd/migrate run . new NAME        create a migration and its rollback file
+//
+// The database is read from DIRECT_URL or DATABASE_URL, as in store.Connect.
+// Use the direct (non-pooled) connection: the advisory lock that keeps
+// concurrent runs apart is held on a session.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-common/migrate"
+	"go-common/store"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: migrate [-dir DIR] [-rollback-dir DIR] <up|down|status|adopt|new> [args]`)
+}
+
+func run(args []string) error {
+	global := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	global.Usage = usage
+	dir := global.String("dir", "", "migrations directory (default: supabase/migrations in this or a parent directory)")
+	rollbackDir := global.String("rollback-dir", "", "rollback directory (default: supabase/rollbacks next to the migrations)")
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+	if global.NArg() == 0 {
+		usage()
+		return errors.New("no command given")
+	}
+
+	if *dir == "" {
+		found, err := findUp(filepath.Join("supabase", "migrations"))
+		if err != nil {
+			return err
+		}
+		*dir = found
+	}
+	if *rollbackDir == "" {
+		*rollbackDir = filepath.Join(filepath.Dir(*dir), "rollbacks")
+	}
+
+	cmd, cmdArgs := global.Arg(0), global.Args()[1:]
+	if cmd == "new" {
+		return newMigration(*dir, *rollbackDir, cmdArgs)
+	}
+
+	migrations, err := migrate.Load(*dir, *rollbackDir)
+	if err != nil {
+		return err
+	}
+	// The schema check would stop the very run that brings the schema up to date.
+	os.Setenv("CHECK_SCHEMA_VERSION", "0")
+	db, err := store.Connect()
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	runner := migrate.NewRunner(sqlDB, migrations)
+	ctx := context.Background()
+
+	switch cmd {
+	case "up":
+		done, err := runner.Up(ctx)
+		report("applied", done)
+		return err
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ContinueOnError)
+		n := fs.Int("n", 1, "number of migrations to roll back")
+		if err := fs.Parse(cmdArgs); err != nil {
+			return err
+		}
+		done, err := runner.Down(ctx, *n)
+		report("rolled back", done)
+		return err
+	case "adopt":
+		fs := flag.NewFlagSet("adopt", flag.ContinueOnError)
+		upTo := fs.String("to", "", "also adopt every migration up to and including this version")
+		if err := fs.Parse(cmdArgs); err != nil {
+			return err
+		}
+		done, err := runner.Adopt(ctx, *upTo)
+		report("adopted", done)
+		return err
+	case "status":
+		return status(ctx, runner)
+	default:
+		usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func newMigration(dir, rollbackDir string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate new NAME")
+	}
+	path, err := migrate.Create(dir, rollbackDir, time.Now().UTC().Format("20060102150405"), args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println("created", path)
+	fmt.Println("remember to set store.SchemaVersion if the Go code depends on it")
+	return nil
+}
+
+func status(ctx context.Context, runner *migrate.Runner) error {
+	statuses, err := runner.Status(ctx)
+	if err != nil {
+		return err
+	}
+	latest := ""
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Missing:
+			state = "applied (no file)"
+		case s.Applied:
+			state = "applied"
+		}
+		if s.Applied {
+			latest = s.Version
+		}
+		fmt.Printf("%-18s %s_%s\n", state, s.Version, s.Name)
+	}
+	fmt.Printf("\nlatest applied: %s\ncode expects:   %s\n", latest, store.SchemaVersion)
+	return nil
+}
+
+func report(verb string, migrations []migrate.Migration) {
+	if len(migrations) == 0 {
+		fmt.Println("nothing", verb)
+		return
+	}
+	for _, m := range migrations {
+		fmt.Printf("%s %s_%s\n", verb, m.Version, m.Name)
+	}
+}
+
+// findUp looks for rel in the working directory and its parents, so the
+// command works from the repository root and from cmd/migrate.
+func findUp(rel string) (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, rel)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%s not found; pass -dir", rel)
+		}
+		dir = parent
+	}
+}
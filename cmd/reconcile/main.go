@@ -0,0 +1,37 @@
+// Command reconcile recounts the denormalised follower, following and post
+// counters on profiles and fixes any that have drifted:
+//
+//	go -C cmd/reconcile run .
+//
+// The triggers on follows and posts keep the counters right in normal
+// operation, so this is only needed after manual data fixes or to check the
+// triggers. The database is read as in store.Connect.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go-common/store"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "reconcile:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	db, err := store.Connect()
+	if err != nil {
+		return err
+	}
+	fixed, err := store.NewProfileRepo(db).ReconcileCounters(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("fixed the counters on %d profiles\n", fixed)
+	return nil
+}
@@ -0,0 +1,164 @@
+// Package apierror writes the JSON error responses of the api/ functions.
+// Every error has the same shape,
+//
+//	{"error": {"code": "post_not_found", "message": "Post not found", "request_id": "..."}}
+//
+// where code comes from the fixed catalogue below and determines the HTTP
+// status. Clients should branch on code; message is for people. Errors from
+// the database or other internals are logged with the request ID and never
+// sent to the client.
+package apierror
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Code is a machine-readable error code.
+type Code string
+
+// The catalogue of error codes. Add new codes here, with their status in
+// statuses, rather than inventing them in a handler.
+const (
+	InvalidRequestBody Code = "invalid_request_body"
+	InvalidParameter   Code = "invalid_parameter"
+	InvalidCursor      Code = "invalid_cursor"
+	ValidationFailed   Code = "validation_failed"
+	PayloadTooLarge    Code = "payload_too_large"
+	UnsupportedMedia   Code = "unsupported_media_type"
+
+	Unauthorized Code = "unauthorized"
+	Forbidden    Code = "forbidden"
+
+	ProfileNotFound       Code = "profile_not_found"
+	PostNotFound          Code = "post_not_found"
+	CommentNotFound       Code = "comment_not_found"
+	ConversationNotFound  Code = "conversation_not_found"
+	MessageNotFound       Code = "message_not_found"
+	StoryNotFound         Code = "story_not_found"
+	NotificationNotFound  Code = "notification_not_found"
+	FollowRequestNotFound Code = "follow_request_not_found"
+	AttachmentNotFound    Code = "attachment_not_found"
+
+	MethodNotAllowed Code = "method_not_allowed"
+	RateLimited      Code = "rate_limited"
+
+	CannotFollowSelf Code = "cannot_follow_self"
+	PrivateAccount   Code = "private_account"
+	CannotBlockSelf  Code = "cannot_block_self"
+	Blocked          Code = "blocked"
+	UsernameTaken    Code = "username_taken"
+	UsernameCooldown Code = "username_cooldown"
+
+	Internal            Code = "internal_error"
+	DatabaseUnavailable Code = "database_unavailable"
+)
+
+var statuses = map[Code]int{
+	InvalidRequestBody: http.StatusBadRequest,
+	InvalidParameter:   http.StatusBadRequest,
+	InvalidCursor:      http.StatusBadRequest,
+	ValidationFailed:   http.StatusBadRequest,
+	PayloadTooLarge:    http.StatusRequestEntityTooLarge,
+	UnsupportedMedia:   http.StatusUnsupportedMediaType,
+
+	Unauthorized: http.StatusUnauthorized,
+	Forbidden:    http.StatusForbidden,
+
+	ProfileNotFound:       http.StatusNotFound,
+	PostNotFound:          http.StatusNotFound,
+	CommentNotFound:       http.StatusNotFound,
+	ConversationNotFound:  http.StatusNotFound,
+	MessageNotFound:       http.StatusNotFound,
+	StoryNotFound:         http.StatusNotFound,
+	NotificationNotFound:  http.StatusNotFound,
+	FollowRequestNotFound: http.StatusNotFound,
+	AttachmentNotFound:    http.StatusNotFound,
+
+	MethodNotAllowed: http.StatusMethodNotAllowed,
+	RateLimited:      http.StatusTooManyRequests,
+
+	CannotFollowSelf: http.StatusUnprocessableEntity,
+	PrivateAccount:   http.StatusForbidden,
+	CannotBlockSelf:  http.StatusUnprocessableEntity,
+	Blocked:          http.StatusForbidden,
+	UsernameTaken:    http.StatusConflict,
+	UsernameCooldown: http.StatusTooManyRequests,
+
+	Internal:            http.StatusInternalServerError,
+	DatabaseUnavailable: http.StatusServiceUnavailable,
+}
+
+// Status returns the HTTP status for code. Codes missing from the catalogue
+// are treated as internal errors.
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is the body of an error response.
+type Error struct {
+	Code      Code   `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Envelope wraps Error as it is sent.
+type Envelope struct {
+	Error Error `json:"error"`
+}
+
+// RequestIDHeader is set on every error response.
+const RequestIDHeader = "X-Request-Id"
+
+// RequestID identifies r in logs and error responses: Vercel's own ID when
+// deployed, the caller's X-Request-Id if it sent one, or a new random ID.
+func RequestID(r *http.Request) string {
+	if id := r.Header.Get("X-Vercel-Id"); id != "" {
+		return id
+	}
+	if id := r.Header.Get(RequestIDHeader); id != "" {
+		return id
+	}
+	return uuid.NewString()
+}
+
+// Write sends an error response with code's status.
+func Write(w http.ResponseWriter, r *http.Request, code Code, message string) {
+	write(w, code, message, RequestID(r))
+}
+
+func write(w http.ResponseWriter, code Code, message, id string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(RequestIDHeader, id)
+	w.WriteHeader(code.Status())
+	json.NewEncoder(w).Encode(Envelope{Error: Error{Code: code, Message: message, RequestID: id}})
+}
+
+// InternalError logs err and sends an internal_error response with message,
+// which must not contain err.
+func InternalError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	writeLogged(w, r, Internal, err, message)
+}
+
+// DatabaseError logs err and sends a database_unavailable response. Use it
+// when store.GetDB fails.
+func DatabaseError(w http.ResponseWriter, r *http.Request, err error) {
+	writeLogged(w, r, DatabaseUnavailable, err, "Database is unavailable")
+}
+
+// NotAllowed sends a method_not_allowed response.
+func NotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, MethodNotAllowed, "Method not allowed")
+}
+
+func writeLogged(w http.ResponseWriter, r *http.Request, code Code, err error, message string) {
+	id := RequestID(r)
+	log.Printf("[ERROR] %s %s (request %s): %s: %v", r.Method, r.URL.Path, id, message, err)
+	write(w, code, message, id)
+}
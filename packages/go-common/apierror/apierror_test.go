@@ -0,0 +1,61 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+	r.Header.Set("X-Vercel-Id", "fra1::abc")
+	w := httptest.NewRecorder()
+
+	Write(w, r, PostNotFound, "Post not found")
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if got := w.Header().Get(RequestIDHeader); got != "fra1::abc" {
+		t.Errorf("%s = %q, want the Vercel ID", RequestIDHeader, got)
+	}
+	var env Envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatal(err)
+	}
+	want := Error{Code: PostNotFound, Message: "Post not found", RequestID: "fra1::abc"}
+	if env.Error != want {
+		t.Errorf("body = %+v, want %+v", env.Error, want)
+	}
+}
+
+func TestInternalErrorDoesNotLeak(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+	w := httptest.NewRecorder()
+
+	InternalError(w, r, errors.New(`pq: relation "posts" does not exist`), "Failed to fetch posts")
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(w.Body.String(), "relation") {
+		t.Errorf("response leaks the underlying error: %s", w.Body)
+	}
+	if w.Header().Get(RequestIDHeader) == "" {
+		t.Errorf("no %s header", RequestIDHeader)
+	}
+}
+
+func TestEveryCodeHasAStatus(t *testing.T) {
+	for code, status := range statuses {
+		if status < 400 || status > 599 {
+			t.Errorf("%s has status %d", code, status)
+		}
+	}
+	if got := Code("made_up").Status(); got != http.StatusInternalServerError {
+		t.Errorf("unknown code status = %d, want 500", got)
+	}
+}
@@ -0,0 +1,376 @@
+// Package apitest is the integration-test harness for the api/ functions.
+//
+// It creates a throwaway database on the Postgres server named by
+// TEST_DATABASE_URL (the one `supabase start` runs is fine), applies
+// supabase_schema.sql and supabase/migrations to it with stand-ins for the
+// parts of Supabase they rely on, points store.Connect at it and mints access
+// tokens that auth.Middleware accepts. A function's tests use it as
+//
+//	func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+//
+//	func TestSomething(t *testing.T) {
+//		db := apitest.DB(t)
+//		alice := apitest.CreateUser(t, db, "alice")
+//		rec := apitest.Do(t, Handler, http.MethodGet, "/api/...", alice.Token, nil)
+//		...
+//	}
+//
+// Tests that call DB are skipped when TEST_DATABASE_URL is not set.
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"go-common/auth"
+	"go-common/migrate"
+	"go-common/store"
+)
+
+// EnvDatabaseURL names the Postgres server the test databases are created on.
+// It must be a URL whose user may create databases.
+const EnvDatabaseURL = "TEST_DATABASE_URL"
+
+// JWTSecret is the SUPABASE_JWT_SECRET the functions under test verify
+// tokens with.
+const JWTSecret = "apitest-jwt-secret-with-at-least-32-characters"
+
+// bootstrap stands in for what Supabase creates before supabase_schema.sql
+// runs: the anon and authenticated roles PostgREST requests run as, with
+// Supabase's default grants, the auth.users table the profiles trigger hangs
+// off, auth.uid() for the RLS policies, and the realtime publication.
+const bootstrap = `
+-- Roles belong to the server, not the database, so they may exist already,
+-- possibly created by another test binary at the same moment.
+DO $$
+BEGIN
+  BEGIN CREATE ROLE anon NOLOGIN; EXCEPTION WHEN duplicate_object OR unique_violation THEN NULL; END;
+  BEGIN CREATE ROLE authenticated NOLOGIN; EXCEPTION WHEN duplicate_object OR unique_violation THEN NULL; END;
+  BEGIN GRANT anon, authenticated TO CURRENT_USER; EXCEPTION WHEN insufficient_privilege THEN NULL; END;
+END
+$$;
+
+CREATE SCHEMA auth;
+GRANT USAGE ON SCHEMA public, auth TO anon, authenticated;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON TABLES TO anon, authenticated;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT ALL ON SEQUENCES TO anon, authenticated;
+
+CREATE TABLE auth.users (
+  id UUID PRIMARY KEY,
+  email TEXT,
+  raw_user_meta_data JSONB DEFAULT '{}'::jsonb NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+-- Same definition as Supabase's. The functions connect as the table owner,
+-- so RLS is not enforced on them; AsUser runs statements under it.
+CREATE FUNCTION auth.uid() RETURNS UUID LANGUAGE sql STABLE AS $$
+  SELECT coalesce(
+    nullif(current_setting('request.jwt.claim.sub', true), ''),
+    (nullif(current_setting('request.jwt.claims', true), '')::jsonb ->> 'sub')
+  )::uuid
+$$;
+
+CREATE PUBLICATION supabase_realtime;`
+
+// schemaIncludes is the newest migration whose change supabase_schema.sql
+// already contains. Migrations up to it are adopted rather than run, as they
+// are on a database set up with `supabase db reset`.
+const schemaIncludes = "20250802110318"
+
+var (
+	setupOnce sync.Once
+	setupErr  error
+	testDB    *gorm.DB
+	dbName    string
+)
+
+// Main runs the tests and then drops the database DB created, if any. Call it
+// from TestMain.
+func Main(m *testing.M) int {
+	code := m.Run()
+	if err := teardown(); err != nil {
+		log.Printf("[ERROR] Dropping test database %s: %v", dbName, err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// DB returns the connection store.GetDB hands to the functions under test,
+// creating and migrating the database on first use. It skips t if
+// TEST_DATABASE_URL is not set.
+func DB(t testing.TB) *gorm.DB {
+	t.Helper()
+	serverURL := os.Getenv(EnvDatabaseURL)
+	if serverURL == "" {
+		t.Skip(EnvDatabaseURL + " is not set")
+	}
+	setupOnce.Do(func() {
+		testDB, setupErr = setup(serverURL)
+	})
+	if setupErr != nil {
+		t.Fatalf("setting up test database: %v", setupErr)
+	}
+	return testDB
+}
+
+func setup(serverURL string) (*gorm.DB, error) {
+	root, err := repoRoot()
+	if err != nil {
+		return nil, err
+	}
+	schema, err := os.ReadFile(filepath.Join(root, "supabase_schema.sql"))
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := migrate.Load(filepath.Join(root, "supabase", "migrations"), "")
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Scheme == "" {
+		return nil, fmt.Errorf("%s must be a postgres:// URL", EnvDatabaseURL)
+	}
+
+	server, err := store.Open(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	name := "apitest_" + randomHex(6)
+	err = server.Exec("CREATE DATABASE " + name).Error
+	closeDB(server)
+	if err != nil {
+		return nil, err
+	}
+	dbName = name
+	u.Path = "/" + name
+	dsn := u.String()
+
+	conn, err := store.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	err = applySchema(conn, string(schema), migrations)
+	closeDB(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// store.Connect and auth.Default read these on first use, which is after
+	// this point: nothing in the test binary has connected yet.
+	os.Setenv("DIRECT_URL", dsn)
+	os.Setenv("CHECK_SCHEMA_VERSION", "true")
+	os.Setenv("SUPABASE_JWT_SECRET", JWTSecret)
+	os.Setenv("SUPABASE_JWT_AUDIENCE", auth.DefaultAudience)
+	return store.GetDB()
+}
+
+// applySchema creates the Supabase stand-ins, then the schema, then runs the
+// migrations it does not already include. It goes through database/sql
+// directly so that GORM does not read anything in the SQL as a placeholder.
+func applySchema(conn *gorm.DB, schema string, migrations []migrate.Migration) error {
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if _, err := sqlDB.ExecContext(ctx, bootstrap); err != nil {
+		return fmt.Errorf("creating Supabase stand-ins: %w", err)
+	}
+	if _, err := sqlDB.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("applying supabase_schema.sql: %w", err)
+	}
+	runner := migrate.NewRunner(sqlDB, migrations)
+	if _, err := runner.Adopt(ctx, schemaIncludes); err != nil {
+		return fmt.Errorf("adopting migrations in supabase_schema.sql: %w", err)
+	}
+	_, err = runner.Up(ctx)
+	return err
+}
+
+func teardown() error {
+	if dbName == "" {
+		return nil
+	}
+	if testDB != nil {
+		closeDB(testDB)
+	}
+	server, err := store.Open(os.Getenv(EnvDatabaseURL))
+	if err != nil {
+		return err
+	}
+	defer closeDB(server)
+	return server.Exec("DROP DATABASE IF EXISTS " + dbName + " WITH (FORCE)").Error
+}
+
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// repoRoot walks up from the working directory to the directory holding
+// supabase_schema.sql.
+func repoRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "supabase_schema.sql")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("supabase_schema.sql not found above the working directory")
+		}
+		dir = parent
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// User is a seeded account together with a token for it.
+type User struct {
+	store.Profile
+	Token string
+}
+
+// CreateUser signs up a user the way Supabase Auth does, by inserting into
+// auth.users and letting the trigger create the profile. A random suffix is
+// appended to username so tests sharing the database do not collide; the
+// profile's full name is username as given.
+func CreateUser(t testing.TB, db *gorm.DB, username string) User {
+	t.Helper()
+	id := uuid.New()
+	unique := username + "_" + randomHex(3)
+	meta, _ := json.Marshal(map[string]string{"username": unique, "full_name": username})
+
+	err := db.Exec("INSERT INTO auth.users (id, email, raw_user_meta_data) VALUES (?, ?, ?::jsonb)",
+		id, unique+"@example.com", string(meta)).Error
+	if err != nil {
+		t.Fatalf("creating user %s: %v", unique, err)
+	}
+	profile, err := store.NewProfileRepo(db).ByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("loading profile of %s: %v", unique, err)
+	}
+	return User{Profile: *profile, Token: Token(t, id)}
+}
+
+// CreatePost inserts a post by userID.
+func CreatePost(t testing.TB, db *gorm.DB, userID uuid.UUID, content string) store.Post {
+	t.Helper()
+	post := store.Post{ID: uuid.New(), UserID: userID, Content: content}
+	if err := store.NewPostRepo(db).Create(context.Background(), &post); err != nil {
+		t.Fatalf("creating post: %v", err)
+	}
+	return post
+}
+
+// Follow makes followerID follow followingID.
+func Follow(t testing.TB, db *gorm.DB, followerID, followingID uuid.UUID) {
+	t.Helper()
+	follow := store.Follow{FollowerID: followerID, FollowingID: followingID}
+	if _, err := store.NewFollowRepo(db).Create(context.Background(), &follow); err != nil {
+		t.Fatalf("creating follow: %v", err)
+	}
+}
+
+// Token returns an hour-long access token for userID, signed with JWTSecret
+// and carrying the claims Supabase Auth issues.
+func Token(t testing.TB, userID uuid.UUID) string {
+	t.Helper()
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  userID.String(),
+		"aud":  auth.DefaultAudience,
+		"role": "authenticated",
+		"iat":  now.Unix(),
+		"exp":  now.Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(JWTSecret))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return signed
+}
+
+// AsUser runs fn in a transaction as the authenticated role, with userID's
+// claims, the way PostgREST runs a request from userID. RLS policies and
+// column privileges apply to it as they would to the client. The
+// transaction is rolled back and fn's error returned.
+func AsUser(t testing.TB, db *gorm.DB, userID uuid.UUID, fn func(tx *gorm.DB) error) error {
+	t.Helper()
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatalf("beginning transaction: %v", tx.Error)
+	}
+	defer tx.Rollback()
+
+	claims, _ := json.Marshal(map[string]string{"sub": userID.String(), "role": "authenticated"})
+	err := tx.Exec("SELECT set_config('request.jwt.claims', ?, true)", string(claims)).Error
+	if err == nil {
+		err = tx.Exec("SET LOCAL ROLE authenticated").Error
+	}
+	if err != nil {
+		t.Fatalf("switching to %s: %v", userID, err)
+	}
+	return fn(tx)
+}
+
+// Do calls handler with a request for target and returns the response. The
+// request carries token as a bearer token unless it is empty, and body
+// encoded as JSON unless it is nil.
+func Do(t testing.TB, handler http.HandlerFunc, method, target, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("encoding request body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, target, &buf)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// Decode unmarshals the JSON body of rec into v.
+func Decode(t testing.TB, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding response %q: %v", rec.Body.String(), err)
+	}
+}
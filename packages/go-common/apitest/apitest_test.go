@@ -0,0 +1,26 @@
+package apitest
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go-common/auth"
+)
+
+func TestTokenIsAcceptedByVerifier(t *testing.T) {
+	userID := uuid.New()
+	verifier := auth.NewVerifier(auth.Config{
+		Secret:   []byte(JWTSecret),
+		Audience: auth.DefaultAudience,
+		Roles:    []string{"authenticated"},
+	})
+
+	p, err := verifier.Verify(Token(t, userID))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if p.UserID != userID {
+		t.Errorf("UserID = %s, want %s", p.UserID, userID)
+	}
+}
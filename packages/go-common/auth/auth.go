@@ -0,0 +1,214 @@
+// Package auth verifies Supabase-issued JWTs for the Go serverless functions
+// and exposes the authenticated caller as a Principal on the request context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"go-common/apierror"
+)
+
+// Principal is the authenticated caller extracted from a verified token.
+type Principal struct {
+	UserID uuid.UUID
+	Role   string
+	Email  string
+}
+
+var (
+	ErrMissingToken  = errors.New("missing bearer token")
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrNotConfigured = errors.New("token verification is not configured")
+)
+
+// DefaultAudience is the audience Supabase puts on end-user access tokens.
+const DefaultAudience = "authenticated"
+
+// Config controls how tokens are verified.
+type Config struct {
+	// Secret is the HS256 signing secret (SUPABASE_JWT_SECRET).
+	Secret []byte
+	// JWKSURL points at the project's JSON Web Key Set for asymmetric keys.
+	JWKSURL string
+	// Audience is the required "aud" claim.
+	Audience string
+	// Roles lists the accepted values of the "role" claim.
+	Roles []string
+}
+
+// ConfigFromEnv builds a Config from the standard Supabase environment variables.
+func ConfigFromEnv() Config {
+	jwksURL := os.Getenv("SUPABASE_JWKS_URL")
+	if jwksURL == "" {
+		if base := os.Getenv("SUPABASE_URL"); base != "" {
+			jwksURL = strings.TrimRight(base, "/") + "/auth/v1/.well-known/jwks.json"
+		}
+	}
+	audience := os.Getenv("SUPABASE_JWT_AUDIENCE")
+	if audience == "" {
+		audience = DefaultAudience
+	}
+	return Config{
+		Secret:   []byte(os.Getenv("SUPABASE_JWT_SECRET")),
+		JWKSURL:  jwksURL,
+		Audience: audience,
+		Roles:    []string{"authenticated"},
+	}
+}
+
+// Verifier checks token signatures and claims.
+type Verifier struct {
+	cfg  Config
+	jwks *jwksCache
+}
+
+// NewVerifier returns a Verifier for the given configuration.
+func NewVerifier(cfg Config) *Verifier {
+	v := &Verifier{cfg: cfg}
+	if cfg.JWKSURL != "" {
+		v.jwks = newJWKSCache(cfg.JWKSURL, &http.Client{Timeout: 5 * time.Second})
+	}
+	return v
+}
+
+var (
+	defaultVerifier *Verifier
+	defaultOnce     sync.Once
+)
+
+// Default returns a Verifier configured from the environment. The environment
+// is read on first use so that a .env file loaded during Connect() is honoured.
+func Default() *Verifier {
+	defaultOnce.Do(func() {
+		defaultVerifier = NewVerifier(ConfigFromEnv())
+	})
+	return defaultVerifier
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	Role  string `json:"role"`
+	Email string `json:"email"`
+}
+
+// Verify parses tokenString and returns the Principal it identifies.
+func (v *Verifier) Verify(tokenString string) (Principal, error) {
+	if len(v.cfg.Secret) == 0 && v.jwks == nil {
+		return Principal{}, ErrNotConfigured
+	}
+
+	var c claims
+	_, err := jwt.ParseWithClaims(tokenString, &c, v.keyFunc,
+		jwt.WithValidMethods([]string{"HS256", "RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(v.cfg.Audience),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if !v.roleAllowed(c.Role) {
+		return Principal{}, fmt.Errorf("%w: role %q is not allowed", ErrInvalidToken, c.Role)
+	}
+
+	userID, err := uuid.Parse(c.Subject)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: 'sub' is not a valid user ID", ErrInvalidToken)
+	}
+
+	return Principal{UserID: userID, Role: c.Role, Email: c.Email}, nil
+}
+
+func (v *Verifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(v.cfg.Secret) == 0 {
+			return nil, fmt.Errorf("HS256 tokens are not accepted: SUPABASE_JWT_SECRET is not set")
+		}
+		return v.cfg.Secret, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		if v.jwks == nil {
+			return nil, fmt.Errorf("asymmetric tokens are not accepted: no JWKS URL configured")
+		}
+		kid, _ := token.Header["kid"].(string)
+		return v.jwks.key(kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+}
+
+func (v *Verifier) roleAllowed(role string) bool {
+	for _, r := range v.cfg.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func BearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrMissingToken
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrMissingToken
+	}
+	return token, nil
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the Principal stored by Middleware, if any.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+// Authenticate verifies the request's bearer token with the default Verifier.
+func Authenticate(r *http.Request) (Principal, error) {
+	token, err := BearerToken(r)
+	if err != nil {
+		return Principal{}, err
+	}
+	return Default().Verify(token)
+}
+
+// Middleware rejects requests without a valid token and otherwise calls next
+// with the caller's Principal stored in the request context.
+func Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := Authenticate(r)
+		if err != nil {
+			log.Printf("[DEBUG] Token validation failed for %s %s: %v", r.Method, r.URL.Path, err)
+			Unauthorized(w, r)
+			return
+		}
+		next(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	}
+}
+
+// Unauthorized writes the standard 401 response.
+func Unauthorized(w http.ResponseWriter, r *http.Request) {
+	apierror.Write(w, r, apierror.Unauthorized, "Unauthorized")
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testSecret = "auth-test-secret-with-at-least-32-characters"
+
+// validClaims returns the claims Supabase Auth puts on an end-user access
+// token for userID.
+func validClaims(userID string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":   userID,
+		"aud":   DefaultAudience,
+		"role":  "authenticated",
+		"email": "user@example.com",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, c jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return signed
+}
+
+func hsVerifier() *Verifier {
+	return NewVerifier(Config{Secret: []byte(testSecret), Audience: DefaultAudience, Roles: []string{"authenticated"}})
+}
+
+func TestVerifyHS256(t *testing.T) {
+	userID := uuid.New()
+	v := hsVerifier()
+
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		c := validClaims(userID.String())
+		change(c)
+		return c
+	}
+	secret := []byte(testSecret)
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", sign(t, jwt.SigningMethodHS256, secret, "", validClaims(userID.String())), true},
+		{"within leeway", sign(t, jwt.SigningMethodHS256, secret, "", with(func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-10 * time.Second).Unix()
+		})), true},
+		{"expired", sign(t, jwt.SigningMethodHS256, secret, "", with(func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+		})), false},
+		{"no expiry", sign(t, jwt.SigningMethodHS256, secret, "", with(func(c jwt.MapClaims) { delete(c, "exp") })), false},
+		{"wrong audience", sign(t, jwt.SigningMethodHS256, secret, "", with(func(c jwt.MapClaims) { c["aud"] = "service" })), false},
+		{"anon role", sign(t, jwt.SigningMethodHS256, secret, "", with(func(c jwt.MapClaims) { c["role"] = "anon" })), false},
+		{"non-UUID sub", sign(t, jwt.SigningMethodHS256, secret, "", with(func(c jwt.MapClaims) { c["sub"] = "user-1" })), false},
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, []byte("another-secret-with-at-least-32-chars!"), "", validClaims(userID.String())), false},
+		{"HS384", sign(t, jwt.SigningMethodHS384, secret, "", validClaims(userID.String())), false},
+		{"alg none", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims(userID.String())), false},
+		{"malformed", "not.a.token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(tt.token)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if p.UserID != userID || p.Role != "authenticated" || p.Email != "user@example.com" {
+				t.Errorf("principal = %+v, want user %s", p, userID)
+			}
+		})
+	}
+}
+
+func TestVerifyNotConfigured(t *testing.T) {
+	v := NewVerifier(Config{Audience: DefaultAudience, Roles: []string{"authenticated"}})
+	token := sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(uuid.NewString()))
+	if _, err := v.Verify(token); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Verify error = %v, want ErrNotConfigured", err)
+	}
+}
+
+// jwksServer publishes the public halves of the given keys and counts how
+// often the set is fetched.
+func jwksServer(t *testing.T, keys map[string]interface{}) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	b64 := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	for kid, k := range keys {
+		switch k := k.(type) {
+		case *rsa.PrivateKey:
+			set.Keys = append(set.Keys, jsonWebKey{Kty: "RSA", Kid: kid, Use: "sig",
+				N: b64(k.N), E: b64(big.NewInt(int64(k.E)))})
+		case *ecdsa.PrivateKey:
+			set.Keys = append(set.Keys, jsonWebKey{Kty: "EC", Kid: kid, Crv: "P-256",
+				X: b64(k.X), Y: b64(k.Y)})
+		}
+	}
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpublished, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, fetches := jwksServer(t, map[string]interface{}{"rsa-1": rsaKey, "ec-1": ecKey})
+
+	// Only asymmetric keys are configured, so HS256 tokens are refused.
+	v := NewVerifier(Config{JWKSURL: srv.URL, Audience: DefaultAudience, Roles: []string{"authenticated"}})
+	userID := uuid.New()
+	claims := validClaims(userID.String())
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"RS256", sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", claims), true},
+		{"ES256", sign(t, jwt.SigningMethodES256, ecKey, "ec-1", claims), true},
+		{"kid of another key", sign(t, jwt.SigningMethodES256, ecKey, "rsa-1", claims), false},
+		{"unknown kid", sign(t, jwt.SigningMethodES256, unpublished, "ec-2", claims), false},
+		{"unpublished key", sign(t, jwt.SigningMethodES256, unpublished, "ec-1", claims), false},
+		{"HS256 without a secret", sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(tt.token)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if p.UserID != userID {
+				t.Errorf("principal = %+v, want user %s", p, userID)
+			}
+		})
+	}
+
+	// Known keys are served from the cache, and an unknown kid refetches the
+	// set at most once per jwksMinRefresh.
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"Bearer abc", "abc", true},
+		{"bearer  abc ", "abc", true},
+		{"", "", false},
+		{"Bearer", "", false},
+		{"Bearer ", "", false},
+		{"Basic abc", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		got, err := BearerToken(r)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("BearerToken(%q) = %q, %v; want %q, ok = %v", tt.header, got, err, tt.want, tt.ok)
+		}
+		if !tt.ok && !errors.Is(err, ErrMissingToken) {
+			t.Errorf("BearerToken(%q) error = %v, want ErrMissingToken", tt.header, err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	// Default reads the environment on first use, which is here.
+	t.Setenv("SUPABASE_JWT_SECRET", testSecret)
+	t.Setenv("SUPABASE_JWKS_URL", "")
+	t.Setenv("SUPABASE_URL", "")
+	t.Setenv("SUPABASE_JWT_AUDIENCE", "")
+
+	userID := uuid.New()
+	next := func(w http.ResponseWriter, r *http.Request) {
+		p, ok := FromContext(r.Context())
+		if !ok || p.UserID != userID {
+			t.Errorf("principal in context = %+v, %v; want user %s", p, ok, userID)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+	expired := validClaims(userID.String())
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid token", "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(userID.String())), http.StatusNoContent},
+		{"missing header", "", http.StatusUnauthorized},
+		{"not a bearer token", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"expired token", "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", expired), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/test", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			Middleware(next)(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
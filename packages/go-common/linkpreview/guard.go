@@ -0,0 +1,95 @@
+package linkpreview
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned when a preview fetch would connect to an
+// address that is not on the public internet.
+var ErrBlockedAddress = errors.New("linkpreview: address not allowed")
+
+// MaxRedirects is how many redirects a fetch follows.
+const MaxRedirects = 3
+
+// blockedNets are special-purpose ranges the net.IP predicates in isPublic
+// do not cover.
+var blockedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",      // "this network"
+		"100.64.0.0/10",  // carrier-grade NAT
+		"192.0.0.0/24",   // IETF protocol assignments
+		"198.18.0.0/15",  // benchmarking
+		"240.0.0.0/4",    // reserved, including broadcast
+		"64:ff9b::/96",   // NAT64, which can reach private IPv4 space
+		"64:ff9b:1::/48", // local-use NAT64
+		"2001:db8::/32",  // documentation
+		"2002::/16",      // 6to4, which embeds any IPv4 address
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// isPublic reports whether ip is a globally routable unicast address.
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewClient returns an HTTP client for fetching untrusted URLs. It connects
+// only to public addresses, checking the address actually dialled so that a
+// hostname cannot resolve (or be rebound) to an internal one, ignores proxy
+// settings, follows at most MaxRedirects redirects, to http and https URLs
+// only, and gives up on the whole request after timeout.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:                  nil,
+		DialContext:            dialer.DialContext,
+		TLSHandshakeTimeout:    timeout,
+		ResponseHeaderTimeout:  timeout,
+		MaxResponseHeaderBytes: 64 << 10,
+		DisableKeepAlives:      true,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > MaxRedirects {
+				return fmt.Errorf("linkpreview: more than %d redirects", MaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("linkpreview: redirect to %s URL", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
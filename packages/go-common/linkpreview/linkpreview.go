@@ -0,0 +1,151 @@
+// Package linkpreview unfurls links in posts: it finds the first URL in a
+// post, fetches the page through a client that refuses internal addresses
+// and reads its OpenGraph and Twitter card tags.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds a whole fetch, redirects included.
+	DefaultTimeout = 5 * time.Second
+	// DefaultMaxBytes is how much of a page is read. The tags are in the
+	// head, so a truncated page still has them.
+	DefaultMaxBytes = 512 << 10
+	// MaxURLLength is the longest URL that is unfurled.
+	MaxURLLength = 2000
+
+	userAgent = "CirqleBot/1.0 (link preview)"
+)
+
+var (
+	// ErrUnsupportedURL is returned for URLs that are not absolute http or
+	// https URLs, or are too long.
+	ErrUnsupportedURL = errors.New("linkpreview: unsupported URL")
+	// ErrNoPreview is returned for pages that are not HTML or have nothing
+	// to build a preview from.
+	ErrNoPreview = errors.New("linkpreview: no preview")
+)
+
+// Preview is what a page says about itself. Any field but URL may be empty.
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// urlPattern matches URLs written out in text. Trailing punctuation is
+// trimmed separately, since it usually ends the sentence, not the URL.
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"']+`)
+
+// FirstURL returns the first http or https URL in content, or "".
+func FirstURL(content string) string {
+	raw := urlPattern.FindString(content)
+	raw = strings.TrimRight(raw, ".,;:!?")
+	// Drop a closing parenthesis that closes one opened before the URL.
+	if strings.HasSuffix(raw, ")") && strings.Count(raw, "(") < strings.Count(raw, ")") {
+		raw = raw[:len(raw)-1]
+	}
+	return raw
+}
+
+// Normalize returns the form of raw that previews are cached under: scheme
+// and host in lowercase, no default port, no fragment, no utm_* tracking
+// parameters, the rest of the query sorted and "/" for an empty path.
+func Normalize(raw string) (string, error) {
+	if len(raw) > MaxURLLength {
+		return "", ErrUnsupportedURL
+	}
+	u, err := url.Parse(raw)
+	// url.Parse has already lowercased the scheme.
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return "", ErrUnsupportedURL
+	}
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	u.Host = host
+	if port != "" {
+		u.Host += ":" + port
+	}
+	u.Fragment, u.RawFragment = "", ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Fetcher fetches previews. The zero value is not usable; use New.
+type Fetcher struct {
+	Client   *http.Client
+	MaxBytes int64
+}
+
+// New returns a Fetcher using NewClient(DefaultTimeout) that reads at most
+// DefaultMaxBytes of a page.
+func New() *Fetcher {
+	return &Fetcher{Client: NewClient(DefaultTimeout), MaxBytes: DefaultMaxBytes}
+}
+
+// Fetch fetches the page at rawURL and returns its preview. Preview.URL is
+// rawURL; relative image URLs are resolved against the final URL after any
+// redirects.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrUnsupportedURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, ErrUnsupportedURL
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("linkpreview: fetching %s: %s", rawURL, resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNoPreview
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxBytes))
+	if err != nil {
+		return nil, err
+	}
+	preview := parse(string(body), resp.Request.URL)
+	if preview.Title == "" && preview.Description == "" && preview.ImageURL == "" {
+		return nil, ErrNoPreview
+	}
+	preview.URL = rawURL
+	return &preview, nil
+}
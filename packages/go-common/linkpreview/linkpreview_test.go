@@ -0,0 +1,176 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFirstURL(t *testing.T) {
+	tests := []struct{ content, want string }{
+		{"no links here", ""},
+		{"see https://example.com/a?b=c.", "https://example.com/a?b=c"},
+		{"(via http://example.com/wiki/Go_(language))", "http://example.com/wiki/Go_(language)"},
+		{"(https://example.com/x)", "https://example.com/x"},
+		{"first HTTPS://Example.com then https://second.example", "HTTPS://Example.com"},
+		{"ftp://example.com is not unfurled", ""},
+	}
+	for _, tt := range tests {
+		if got := FirstURL(tt.content); got != tt.want {
+			t.Errorf("FirstURL(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct{ raw, want string }{
+		{"HTTPS://Example.COM", "https://example.com/"},
+		{"http://example.com:80/a#frag", "http://example.com/a"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+		{"https://example.com/?utm_source=x&b=2&a=1&UTM_medium=y", "https://example.com/?a=1&b=2"},
+		{"http://[::1]:80/", "http://[::1]/"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.raw)
+		if err != nil || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v, want %q", tt.raw, got, err, tt.want)
+		}
+	}
+	for _, raw := range []string{"javascript:alert(1)", "https://user:pw@example.com/", "/relative", "https://" + strings.Repeat("a", MaxURLLength)} {
+		if _, err := Normalize(raw); !errors.Is(err, ErrUnsupportedURL) {
+			t.Errorf("Normalize(%q) err = %v, want ErrUnsupportedURL", raw, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/articles/1")
+	tests := []struct {
+		name string
+		doc  string
+		want Preview
+	}{
+		{
+			"opengraph",
+			`<html><head><title>Fallback</title>
+			<meta property="og:title" content="Tom &amp; Jerry > Cats">
+			<META PROPERTY='og:description' CONTENT='A "classic"'>
+			<meta property="og:image" content="/img/cover.png" />
+			<meta property="og:site_name" content=Cartoons>
+			</head><body><meta property="og:title" content="ignored"></body>`,
+			Preview{Title: "Tom & Jerry > Cats", Description: `A "classic"`, ImageURL: "https://example.com/img/cover.png", SiteName: "Cartoons"},
+		},
+		{
+			"twitter card and title fallback",
+			`<head><title>
+			  Plain   title </title><meta name="twitter:description" content="Card text">
+			<meta name="twitter:image" content="javascript:alert(1)"></head>`,
+			Preview{Title: "Plain title", Description: "Card text"},
+		},
+		{
+			"scripts and comments are skipped",
+			`<head><!-- <meta property="og:title" content="commented"> -->
+			<script>var s = '<meta property="og:title" content="scripted">';</script>
+			<meta name="description" content="Real"></head>`,
+			Preview{Description: "Real"},
+		},
+		{
+			"first value wins",
+			`<meta property="og:title" content="One"><meta property="og:title" content="Two">`,
+			Preview{Title: "One"},
+		},
+	}
+	for _, tt := range tests {
+		if got := parse(tt.doc, base); got != tt.want {
+			t.Errorf("%s: parse = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	long := parse(`<title>`+strings.Repeat("é", maxTitle+10)+`</title>`, base)
+	if n := len([]rune(long.Title)); n != maxTitle {
+		t.Errorf("long title clipped to %d characters, want %d", n, maxTitle)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("User-Agent = %q", r.Header.Get("User-Agent"))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<head><meta property="og:title" content="Hello"><meta property="og:image" content="cover.jpg"></head>`))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"title":"no"}`))
+	})
+	mux.HandleFunc("/bare", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<p>nothing to see</p>`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := &Fetcher{Client: srv.Client(), MaxBytes: DefaultMaxBytes}
+	p, err := f.Fetch(context.Background(), srv.URL+"/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Preview{URL: srv.URL + "/moved", Title: "Hello", ImageURL: srv.URL + "/cover.jpg"}
+	if *p != want {
+		t.Errorf("preview = %+v, want %+v", *p, want)
+	}
+
+	for _, path := range []string{"/json", "/bare"} {
+		if _, err := f.Fetch(context.Background(), srv.URL+path); !errors.Is(err, ErrNoPreview) {
+			t.Errorf("%s: err = %v, want ErrNoPreview", path, err)
+		}
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("404 page produced a preview")
+	}
+}
+
+// The guarded client refuses the loopback test server, directly and through
+// a hostname.
+func TestNewClientBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("guarded client reached the server")
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second)
+	u, _ := url.Parse(srv.URL)
+	for _, target := range []string{srv.URL, "http://localhost:" + u.Port()} {
+		if _, err := client.Get(target); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("GET %s: err = %v, want ErrBlockedAddress", target, err)
+		}
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for _, addr := range []string{"93.184.216.34", "2606:2800:220:1::1"} {
+		if !isPublic(net.ParseIP(addr)) {
+			t.Errorf("%s is public", addr)
+		}
+	}
+	for _, addr := range []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "255.255.255.255", "::1", "fe80::1", "fc00::1",
+		"::ffff:10.0.0.1", "64:ff9b::a00:1",
+	} {
+		if isPublic(net.ParseIP(addr)) {
+			t.Errorf("%s is not public", addr)
+		}
+	}
+}
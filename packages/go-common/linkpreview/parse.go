@@ -0,0 +1,215 @@
+package linkpreview
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Longest values kept from a page, in characters.
+const (
+	maxTitle       = 300
+	maxDescription = 1000
+	maxSiteName    = 100
+)
+
+// parse reads a preview from the head of an HTML document. It is a scanner
+// for the few tags a preview needs, not an HTML parser: it stops at </head>
+// or <body> and skips comments, scripts and styles, which is enough for the
+// head of real pages. base resolves relative image URLs.
+func parse(doc string, base *url.URL) Preview {
+	meta := map[string]string{}
+	var title string
+	lower := asciiLower(doc)
+
+	for i := 0; i < len(doc); {
+		lt := strings.IndexByte(doc[i:], '<')
+		if lt < 0 {
+			break
+		}
+		i += lt
+		rest := lower[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			i = skipPast(lower, i, "-->")
+		case isTag(rest, "script"):
+			i = skipPast(lower, i, "</script")
+		case isTag(rest, "style"):
+			i = skipPast(lower, i, "</style")
+		case isTag(rest, "/head"), isTag(rest, "body"):
+			i = len(doc)
+		case isTag(rest, "title"):
+			start := skipPast(lower, i, ">")
+			end := strings.Index(lower[start:], "</title")
+			if end < 0 {
+				end = len(doc) - start
+			}
+			if title == "" {
+				title = doc[start : start+end]
+			}
+			i = start + end
+		case isTag(rest, "meta"):
+			end := tagEnd(doc, i)
+			attrs := parseAttrs(doc[i+len("<meta") : end])
+			key := attrs["property"]
+			if key == "" {
+				key = attrs["name"]
+			}
+			key = asciiLower(key)
+			if _, seen := meta[key]; key != "" && !seen {
+				meta[key] = attrs["content"]
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+
+	p := Preview{
+		Title:       clip(first(meta["og:title"], meta["twitter:title"], html.UnescapeString(title)), maxTitle),
+		Description: clip(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescription),
+		SiteName:    clip(meta["og:site_name"], maxSiteName),
+	}
+	if img := first(meta["og:image:secure_url"], meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"]); img != "" {
+		if ref, err := url.Parse(img); err == nil {
+			abs := base.ResolveReference(ref)
+			if (abs.Scheme == "http" || abs.Scheme == "https") && len(abs.String()) <= MaxURLLength {
+				p.ImageURL = abs.String()
+			}
+		}
+	}
+	return p
+}
+
+// parseAttrs parses the attributes of a tag, given the text between its name
+// and the closing '>'. Names are lowercased and values unescaped.
+func parseAttrs(s string) map[string]string {
+	attrs := map[string]string{}
+	for i := 0; i < len(s); {
+		for i < len(s) && (isSpace(s[i]) || s[i] == '/') {
+			i++
+		}
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '/' && s[i] != '>' {
+			i++
+		}
+		name := asciiLower(s[start:i])
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		var value string
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					end = len(s) - i - 1
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+		if name == "" {
+			if i == start {
+				i++
+			}
+			continue
+		}
+		if _, seen := attrs[name]; !seen {
+			attrs[name] = html.UnescapeString(value)
+		}
+	}
+	return attrs
+}
+
+// isTag reports whether s starts with the tag <name, followed by whitespace,
+// '/' or '>'.
+func isTag(s, name string) bool {
+	if !strings.HasPrefix(s, "<"+name) || len(s) == len(name)+1 {
+		return false
+	}
+	c := s[len(name)+1]
+	return isSpace(c) || c == '/' || c == '>'
+}
+
+// tagEnd returns the index just after the '>' closing the tag that starts at
+// i, ignoring any inside quoted attribute values, or len(s).
+func tagEnd(s string, i int) int {
+	var quote, prev byte
+	for ; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && prev == '=':
+			quote = c
+		case c == '>':
+			return i + 1
+		}
+		if !isSpace(c) {
+			prev = c
+		}
+	}
+	return len(s)
+}
+
+// skipPast returns the index just after the first sep in s at or after i, or
+// len(s).
+func skipPast(s string, i int, sep string) int {
+	if j := strings.Index(s[i:], sep); j >= 0 {
+		return i + j + len(sep)
+	}
+	return len(s)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// asciiLower lowercases ASCII letters only, so byte offsets into s stay
+// valid in the result.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// first returns the first of values that is not blank, trimmed.
+func first(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clip collapses runs of whitespace in s and cuts it to max characters.
+func clip(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "")
+	}
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
@@ -0,0 +1,165 @@
+// Package media validates and prepares user-uploaded images and videos.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+
+	// Registered for image.Decode.
+	_ "image/gif"
+	_ "image/png"
+)
+
+const (
+	// MaxAvatarBytes caps the size of an uploaded avatar file. Like
+	// MaxImageBytes it has to fit in a 4.5 MB request body.
+	MaxAvatarBytes = 4 << 20
+	// MaxAvatarPixels caps the decoded size of an avatar, so a small file
+	// cannot claim enormous dimensions and exhaust memory.
+	MaxAvatarPixels = 40_000_000
+	// AvatarContentType is the type of every variant ProcessAvatar produces.
+	AvatarContentType = "image/jpeg"
+)
+
+var (
+	// ErrUnsupportedType is returned for files that are not JPEG, PNG or GIF
+	// images, whatever their name or declared type says.
+	ErrUnsupportedType = errors.New("unsupported image type")
+	// ErrTooLarge is returned for images over MaxAvatarPixels or
+	// MaxImagePixels.
+	ErrTooLarge = errors.New("image dimensions too large")
+)
+
+// AvatarSizes are the square variants produced for every avatar, largest
+// first. Images smaller than a size are not scaled up.
+var AvatarSizes = []struct {
+	Name string
+	Side int
+}{
+	{"large", 400},
+	{"small", 96},
+}
+
+// Variant is one resized copy of an image.
+type Variant struct {
+	Name        string
+	Side        int
+	ContentType string
+	Data        []byte
+}
+
+// ProcessAvatar checks that data is a supported image, applies its EXIF
+// orientation, crops it to a centred square and returns one JPEG variant per
+// entry in AvatarSizes. The variants are re-encoded from pixels only, so no
+// EXIF or other metadata from the upload survives.
+func ProcessAvatar(data []byte) ([]Variant, error) {
+	img, err := decode(data, MaxAvatarPixels)
+	if err != nil {
+		return nil, err
+	}
+	img = cropSquare(img)
+
+	variants := make([]Variant, 0, len(AvatarSizes))
+	for _, size := range AvatarSizes {
+		side := size.Side
+		if b := img.Bounds(); b.Dx() < side {
+			side = b.Dx()
+		}
+		data, err := encodeJPEG(resize(img, side))
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, Variant{
+			Name:        size.Name,
+			Side:        side,
+			ContentType: AvatarContentType,
+			Data:        data,
+		})
+	}
+	return variants, nil
+}
+
+// decode checks that data is a JPEG, PNG or GIF image of at most maxPixels
+// and returns its pixels, flattened and in EXIF orientation. Only the first
+// frame of an animated GIF is kept.
+func decode(data []byte, maxPixels int) (*image.RGBA, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	return orient(flatten(src), exifOrientation(data)), nil
+}
+
+// flatten draws src onto a white background, so transparent areas do not
+// turn black in the JPEG output.
+func flatten(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	return dst
+}
+
+// cropSquare returns the largest centred square of img.
+func cropSquare(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	return img.SubImage(image.Rect(x0, y0, x0+side, y0+side)).(*image.RGBA)
+}
+
+// resize scales the square img to side×side.
+func resize(img *image.RGBA, side int) *image.RGBA {
+	return resizeTo(img, side, side)
+}
+
+// resizeTo scales img to w×h by averaging the source pixels that fall in each
+// destination pixel. It is only used to scale down.
+func resizeTo(img *image.RGBA, w, h int) *image.RGBA {
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy0, sy1 := y*srcH/h, (y+1)*srcH/h
+		if sy1 == sy0 {
+			sy1++
+		}
+		for x := 0; x < w; x++ {
+			sx0, sx1 := x*srcW/w, (x+1)*srcW/w
+			if sx1 == sx0 {
+				sx1++
+			}
+			var r, g, bl, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					c := img.RGBAAt(b.Min.X+sx, b.Min.Y+sy)
+					r, g, bl, a = r+uint32(c.R), g+uint32(c.G), bl+uint32(c.B), a+uint32(c.A)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)})
+		}
+	}
+	return dst
+}
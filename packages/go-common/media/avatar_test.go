@@ -0,0 +1,177 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessAvatar(t *testing.T) {
+	variants, err := ProcessAvatar(encodePNG(t, 800, 600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != len(AvatarSizes) {
+		t.Fatalf("%d variants, want %d", len(variants), len(AvatarSizes))
+	}
+	for i, v := range variants {
+		if v.Name != AvatarSizes[i].Name || v.ContentType != AvatarContentType {
+			t.Errorf("variant %d = %s %s", i, v.Name, v.ContentType)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(v.Data))
+		if err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		if b := img.Bounds(); b.Dx() != AvatarSizes[i].Side || b.Dy() != AvatarSizes[i].Side {
+			t.Errorf("%s is %dx%d, want %d square", v.Name, b.Dx(), b.Dy(), AvatarSizes[i].Side)
+		}
+	}
+}
+
+// Small images are cropped but not scaled up.
+func TestProcessAvatarSmallImage(t *testing.T) {
+	variants, err := ProcessAvatar(encodePNG(t, 50, 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range variants {
+		if v.Side != 50 {
+			t.Errorf("%s side = %d, want 50", v.Name, v.Side)
+		}
+	}
+}
+
+func TestProcessAvatarRejects(t *testing.T) {
+	// A PNG header claiming 100000x100000 pixels.
+	huge := encodePNG(t, 1, 1)
+	binary.BigEndian.PutUint32(huge[16:], 100000)
+	binary.BigEndian.PutUint32(huge[20:], 100000)
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29]))
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text", []byte("definitely not an image"), ErrUnsupportedType},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), ErrUnsupportedType},
+		{"truncated png", encodePNG(t, 10, 10)[:40], ErrUnsupportedType},
+		{"decompression bomb", huge, ErrTooLarge},
+	}
+	for _, tt := range tests {
+		if _, err := ProcessAvatar(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// jpegWithOrientation returns a w×h JPEG with an EXIF APP1 segment carrying
+// orientation o, written in the given byte order.
+func jpegWithOrientation(t *testing.T, w, h, o int, order binary.ByteOrder) []byte {
+	t.Helper()
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(o))
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	var out bytes.Buffer
+	out.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(app1)+2))
+	out.Write(app1)
+	out.Write(img.Bytes()[2:])
+	return out.Bytes()
+}
+
+func TestExifOrientation(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for o := 1; o <= 8; o++ {
+			if got := exifOrientation(jpegWithOrientation(t, 4, 2, o, order)); got != o {
+				t.Errorf("%v orientation %d read as %d", order, o, got)
+			}
+		}
+	}
+	if got := exifOrientation(encodePNG(t, 2, 2)); got != 1 {
+		t.Errorf("png orientation = %d, want 1", got)
+	}
+}
+
+// Each orientation moves the stored top-left pixel to where it is displayed,
+// and 5 to 8 swap width and height.
+func TestOrient(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+
+	tests := []struct {
+		o          int
+		w, h       int
+		redX, redY int
+	}{
+		{1, 3, 2, 0, 0},
+		{2, 3, 2, 2, 0},
+		{3, 3, 2, 2, 1},
+		{4, 3, 2, 0, 1},
+		{5, 2, 3, 0, 0},
+		{6, 2, 3, 1, 0},
+		{7, 2, 3, 1, 2},
+		{8, 2, 3, 0, 2},
+	}
+	for _, tt := range tests {
+		got := orient(img, tt.o)
+		if b := got.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("orientation %d: %dx%d, want %dx%d", tt.o, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+		if c := got.RGBAAt(tt.redX, tt.redY); c.R != 255 {
+			t.Errorf("orientation %d: red pixel not at (%d,%d)", tt.o, tt.redX, tt.redY)
+		}
+	}
+}
+
+// The variants carry none of the upload's metadata.
+func TestProcessAvatarStripsExif(t *testing.T) {
+	variants, err := ProcessAvatar(jpegWithOrientation(t, 20, 10, 6, binary.BigEndian))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range variants {
+		if bytes.Contains(v.Data, []byte("Exif")) {
+			t.Errorf("%s still has an EXIF segment", v.Name)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+)
+
+const (
+	// MaxImageBytes caps the size of an image uploaded for a post. Like
+	// MaxVideoBytes it has to fit in a 4.5 MB request body.
+	MaxImageBytes = 4 << 20
+	// MaxImagePixels caps the decoded size of a post image.
+	MaxImagePixels = 40_000_000
+	// MaxImageSide is the longest side a post image is stored at.
+	MaxImageSide = 2048
+	// ThumbnailSide is the longest side of a post image's thumbnail.
+	ThumbnailSide = 400
+	// ImageContentType is the type of both versions ProcessImage produces.
+	ImageContentType = "image/jpeg"
+)
+
+// Image is an uploaded image prepared for a post: Data is the image to show,
+// no larger than MaxImageSide, and Thumbnail a copy no larger than
+// ThumbnailSide. Width and Height are those of Data.
+type Image struct {
+	Width, Height int
+	Data          []byte
+	Thumbnail     []byte
+}
+
+// ProcessImage checks that data is a supported image, applies its EXIF
+// orientation and returns it and its thumbnail as JPEGs, scaled down to fit
+// but keeping their aspect ratio. Like ProcessAvatar it drops every piece of
+// metadata from the upload.
+func ProcessImage(data []byte) (*Image, error) {
+	img, err := decode(data, MaxImagePixels)
+	if err != nil {
+		return nil, err
+	}
+
+	full := fit(img, MaxImageSide)
+	out, err := encodeJPEG(full)
+	if err != nil {
+		return nil, err
+	}
+	thumb, err := encodeJPEG(fit(full, ThumbnailSide))
+	if err != nil {
+		return nil, err
+	}
+	b := full.Bounds()
+	return &Image{Width: b.Dx(), Height: b.Dy(), Data: out, Thumbnail: thumb}, nil
+}
+
+// fit scales img down, keeping its aspect ratio, so that neither side is
+// longer than max. Images that already fit are returned as they are.
+func fit(img *image.RGBA, max int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return img
+	}
+	if w >= h {
+		w, h = max, h*max/w
+	} else {
+		w, h = w*max/h, max
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return resizeTo(img, w, h)
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/jpeg"
+	"testing"
+)
+
+func TestProcessImage(t *testing.T) {
+	tests := []struct {
+		w, h           int
+		wantW, wantH   int
+		thumbW, thumbH int
+	}{
+		{3000, 1500, MaxImageSide, MaxImageSide / 2, ThumbnailSide, ThumbnailSide / 2},
+		{600, 900, 600, 900, ThumbnailSide * 600 / 900, ThumbnailSide},
+		{100, 50, 100, 50, 100, 50},
+	}
+	for _, tt := range tests {
+		img, err := ProcessImage(encodePNG(t, tt.w, tt.h))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Width != tt.wantW || img.Height != tt.wantH {
+			t.Errorf("%dx%d stored as %dx%d, want %dx%d", tt.w, tt.h, img.Width, img.Height, tt.wantW, tt.wantH)
+		}
+		thumb, err := jpeg.DecodeConfig(bytes.NewReader(img.Thumbnail))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if thumb.Width != tt.thumbW || thumb.Height != tt.thumbH {
+			t.Errorf("%dx%d thumbnail is %dx%d, want %dx%d", tt.w, tt.h, thumb.Width, thumb.Height, tt.thumbW, tt.thumbH)
+		}
+	}
+
+	if _, err := ProcessImage([]byte("GIF89a\x01\x00\x01\x00")); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("broken gif: err = %v", err)
+	}
+}
+
+// box encodes an MP4 box of type typ around body.
+func box(typ string, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	out := make([]byte, 8, 8+len(b))
+	binary.BigEndian.PutUint32(out, uint32(8+len(b)))
+	copy(out[4:], typ)
+	return append(out, b...)
+}
+
+// tkhd encodes a version 0 track header of the given size.
+func tkhd(w, h int) []byte {
+	body := make([]byte, 84)
+	binary.BigEndian.PutUint32(body[76:], uint32(w)<<16)
+	binary.BigEndian.PutUint32(body[80:], uint32(h)<<16)
+	return box("tkhd", body)
+}
+
+func TestProbeVideo(t *testing.T) {
+	ftyp := box("ftyp", []byte("mp42\x00\x00\x00\x00isommp42"))
+	mp4 := func(traks ...[]byte) []byte {
+		return append(append([]byte{}, ftyp...), box("moov", box("mvhd", make([]byte, 100)), bytes.Join(traks, nil))...)
+	}
+	audio := box("trak", tkhd(0, 0))
+	video := box("trak", tkhd(1280, 720))
+
+	v, err := ProbeVideo(mp4(audio, video))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Width != 1280 || v.Height != 720 {
+		t.Errorf("size = %dx%d, want 1280x720", v.Width, v.Height)
+	}
+
+	for name, data := range map[string][]byte{
+		"audio only": mp4(audio),
+		"truncated":  mp4(video)[:len(mp4(video))-10],
+		"png":        encodePNG(t, 2, 2),
+	} {
+		if _, err := ProbeVideo(data); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("%s: err = %v, want ErrUnsupportedType", name, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package media
+
+import (
+	"encoding/binary"
+	"net/http"
+)
+
+const (
+	// MaxVideoBytes caps the size of a video uploaded for a post. Uploads go
+	// through a serverless function, and Vercel rejects request bodies over
+	// 4.5 MB, so anything larger could never arrive.
+	MaxVideoBytes = 4 << 20
+	// VideoContentType is the only video type accepted.
+	VideoContentType = "video/mp4"
+)
+
+// Video describes an uploaded MP4. Videos are stored as uploaded: there is
+// no transcoder to re-encode them or draw a thumbnail from.
+type Video struct {
+	Width, Height int
+}
+
+// ProbeVideo checks that data is an MP4 file and reads the display size of
+// its first video track. It returns ErrUnsupportedType for anything else,
+// including MP4s without a video track.
+func ProbeVideo(data []byte) (*Video, error) {
+	if http.DetectContentType(data) != VideoContentType {
+		return nil, ErrUnsupportedType
+	}
+	moov := findBox(data, "moov")
+	for rest := moov; len(rest) > 0; {
+		trak, next := nextBox(rest, "trak")
+		if trak == nil {
+			break
+		}
+		if w, h := tkhdSize(findBox(trak, "tkhd")); w > 0 && h > 0 {
+			return &Video{Width: w, Height: h}, nil
+		}
+		rest = next
+	}
+	return nil, ErrUnsupportedType
+}
+
+// findBox returns the body of the first box of type typ among the boxes in
+// data, or nil.
+func findBox(data []byte, typ string) []byte {
+	body, _ := nextBox(data, typ)
+	return body
+}
+
+// nextBox returns the body of the first box of type typ among the boxes in
+// data and the data after it, or nil if there is no such box or the boxes
+// are malformed.
+func nextBox(data []byte, typ string) (body, rest []byte) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		header := uint64(8)
+		switch size {
+		case 0: // the box runs to the end of the file
+			size = uint64(len(data))
+		case 1: // a 64-bit size follows the type
+			if len(data) < 16 {
+				return nil, nil
+			}
+			size, header = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return nil, nil
+		}
+		if string(data[4:8]) == typ {
+			return data[header:size], data[size:]
+		}
+		data = data[size:]
+	}
+	return nil, nil
+}
+
+// tkhdSize reads the width and height from the body of a track header box.
+// They are 16.16 fixed point numbers at the end of the box, after fields
+// whose size depends on the box version. Audio tracks have them zero.
+func tkhdSize(tkhd []byte) (int, int) {
+	if len(tkhd) < 1 {
+		return 0, 0
+	}
+	offset := 76
+	if tkhd[0] == 1 {
+		offset = 88
+	}
+	if len(tkhd) < offset+8 {
+		return 0, 0
+	}
+	w := binary.BigEndian.Uint32(tkhd[offset:])
+	h := binary.BigEndian.Uint32(tkhd[offset+4:])
+	return int(w >> 16), int(h >> 16)
+}
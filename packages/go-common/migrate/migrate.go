@@ -0,0 +1,334 @@
+// Package migrate applies the versioned SQL files in supabase/migrations and
+// records them in public.schema_migrations. It reads the same directory the
+// Supabase CLI does, so a database set up with `supabase db push` can be
+// adopted instead of re-run.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// lockKey is the pg_advisory_lock key held while migrating, so two deploys
+// never apply migrations at the same time. It is an arbitrary constant
+// ("socmedmg" as an int64).
+const lockKey int64 = 0x736f636d65646d67
+
+// Table is where applied versions are recorded.
+const Table = "public.schema_migrations"
+
+// fileName matches the Supabase CLI's <version>_<name>.sql convention.
+var fileName = regexp.MustCompile(`^([0-9]+)_(.+)\.sql$`)
+
+// Migration is one versioned SQL file. Down is empty when no rollback file
+// exists.
+type Migration struct {
+	Version string
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load reads the migrations in dir, oldest first. Rollbacks are read from
+// files with the same name in rollbackDir; they are kept out of dir because
+// the Supabase CLI would otherwise apply them as migrations.
+func Load(dir, rollbackDir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := map[string]string{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		if other, ok := seen[m[1]]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %s", other, e.Name(), m[1])
+		}
+		seen[m[1]] = e.Name()
+
+		up, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig := Migration{Version: m[1], Name: m[2], Up: string(up)}
+		if rollbackDir != "" {
+			down, err := os.ReadFile(filepath.Join(rollbackDir, e.Name()))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			mig.Down = string(down)
+		}
+		migrations = append(migrations, mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Runner applies migrations to a database.
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewRunner returns a Runner for migrations, as returned by Load.
+func NewRunner(db *sql.DB, migrations []Migration) *Runner {
+	return &Runner{db: db, migrations: migrations}
+}
+
+// Status describes one migration's state in the database.
+type Status struct {
+	Version string
+	Name    string
+	Applied bool
+	// Missing is set for versions recorded in the database that have no file.
+	Missing bool
+}
+
+// withLock runs fn on a single connection holding the migration lock, after
+// making sure the tracking table exists.
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("taking migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+
+	_, err = conn.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS `+Table+` (
+  version TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  applied_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+-- Not exposed through the Supabase API: RLS on and no policies.
+ALTER TABLE `+Table+` ENABLE ROW LEVEL SECURITY;`)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", Table, err)
+	}
+	return fn(conn)
+}
+
+func applied(ctx context.Context, conn *sql.Conn) (map[string]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name FROM "+Table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := map[string]string{}
+	for rows.Next() {
+		var version, name string
+		if err := rows.Scan(&version, &name); err != nil {
+			return nil, err
+		}
+		versions[version] = name
+	}
+	return versions, rows.Err()
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns the ones it applied. It stops at the first failure.
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range r.migrations {
+			if _, ok := versions[m.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "INSERT INTO "+Table+" (version, name) VALUES ($1, $2)", m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("applying %s_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the n most recently applied migrations, newest first, and
+// returns the ones it rolled back. A migration without a rollback file stops
+// the run.
+func (r *Runner) Down(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(r.migrations) - 1; i >= 0 && len(done) < n; i-- {
+			m := r.migrations[i]
+			if _, ok := versions[m.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(m.Down) == "" {
+				return fmt.Errorf("%s_%s has no rollback file", m.Version, m.Name)
+			}
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "DELETE FROM "+Table+" WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("rolling back %s_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Adopt records migrations as applied without running them, for databases
+// whose schema was already set up by hand or by the Supabase CLI. Versions the
+// Supabase CLI recorded in supabase_migrations.schema_migrations are adopted;
+// if upTo is set, every migration up to and including it is adopted as well.
+func (r *Runner) Adopt(ctx context.Context, upTo string) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		cli := map[string]bool{}
+		var hasCLITable bool
+		err = conn.QueryRowContext(ctx, "SELECT to_regclass('supabase_migrations.schema_migrations') IS NOT NULL").Scan(&hasCLITable)
+		if err != nil {
+			return err
+		}
+		if hasCLITable {
+			rows, err := conn.QueryContext(ctx, "SELECT version FROM supabase_migrations.schema_migrations")
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var v string
+				if err := rows.Scan(&v); err != nil {
+					rows.Close()
+					return err
+				}
+				cli[v] = true
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+		}
+
+		for _, m := range r.migrations {
+			if _, ok := versions[m.Version]; ok {
+				continue
+			}
+			if !cli[m.Version] && (upTo == "" || m.Version > upTo) {
+				continue
+			}
+			_, err := conn.ExecContext(ctx, "INSERT INTO "+Table+" (version, name) VALUES ($1, $2)", m.Version, m.Name)
+			if err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status reports every migration file and whether it has been applied, plus
+// any applied versions whose file no longer exists.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range r.migrations {
+			_, ok := versions[m.Version]
+			statuses = append(statuses, Status{Version: m.Version, Name: m.Name, Applied: ok})
+			delete(versions, m.Version)
+		}
+		for version, name := range versions {
+			statuses = append(statuses, Status{Version: version, Name: name, Applied: true, Missing: true})
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+		return nil
+	})
+	return statuses, err
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Create writes an empty migration named name, versioned with the current UTC
+// time as the Supabase CLI does, and a matching rollback file. It returns the
+// migration's path.
+func Create(dir, rollbackDir, version, name string) (string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", errors.New("migration name is empty")
+	}
+	file := version + "_" + name + ".sql"
+
+	path := filepath.Join(dir, file)
+	if err := writeNew(path, "-- "+name+"\n"); err != nil {
+		return "", err
+	}
+	if rollbackDir != "" {
+		if err := os.MkdirAll(rollbackDir, 0o755); err != nil {
+			return "", err
+		}
+		if err := writeNew(filepath.Join(rollbackDir, file), "-- Rollback for "+file+"\n"); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+func writeNew(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
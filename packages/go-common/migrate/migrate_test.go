@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, rollbacks := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(dir, "20261017120000_second.sql"), "SELECT 2;")
+	writeFile(t, filepath.Join(dir, "20250802110318_first.sql"), "SELECT 1;")
+	writeFile(t, filepath.Join(dir, "README.md"), "not a migration")
+	writeFile(t, filepath.Join(rollbacks, "20261017120000_second.sql"), "SELECT -2;")
+
+	migrations, err := Load(dir, rollbacks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("loaded %d migrations, want 2", len(migrations))
+	}
+	first, second := migrations[0], migrations[1]
+	if first.Version != "20250802110318" || first.Name != "first" || first.Up != "SELECT 1;" || first.Down != "" {
+		t.Errorf("first = %+v", first)
+	}
+	if second.Version != "20261017120000" || second.Down != "SELECT -2;" {
+		t.Errorf("second = %+v", second)
+	}
+}
+
+func TestLoadRejectsDuplicateVersions(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "20261017120000_a.sql"), "")
+	writeFile(t, filepath.Join(dir, "20261017120000_b.sql"), "")
+
+	if _, err := Load(dir, ""); err == nil || !strings.Contains(err.Error(), "share version") {
+		t.Errorf("Load error = %v, want a duplicate version error", err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	rollbacks := filepath.Join(dir, "rollbacks")
+
+	path, err := Create(dir, rollbacks, "20261018090000", "Add Post Media!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "20261018090000_add_post_media.sql"); path != want {
+		t.Errorf("Create path = %s, want %s", path, want)
+	}
+	if _, err := os.Stat(filepath.Join(rollbacks, "20261018090000_add_post_media.sql")); err != nil {
+		t.Errorf("rollback file not created: %v", err)
+	}
+	if _, err := Create(dir, rollbacks, "20261018090000", "add post media"); err == nil {
+		t.Error("Create overwrote an existing migration")
+	}
+}
@@ -0,0 +1,211 @@
+// Package notify writes notifications for the social actions handled by the
+// api/ functions. Notifications are a side effect: failures are logged and
+// never fail the request that triggered them.
+package notify
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"go-common/store"
+)
+
+// maxMentions caps how many people a single post or comment can notify.
+const maxMentions = 10
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.]{3,30})`)
+
+// Notifier turns follows, follow requests, likes, comments and mentions into
+// notifications.
+type Notifier struct {
+	notifications store.NotificationRepo
+	profiles      store.ProfileRepo
+	posts         store.PostRepo
+	comments      store.CommentRepo
+	follows       store.FollowRepo
+	blocks        store.BlockRepo
+}
+
+// New returns a Notifier backed by db.
+func New(db *gorm.DB) *Notifier {
+	return &Notifier{
+		notifications: store.NewNotificationRepo(db),
+		profiles:      store.NewProfileRepo(db),
+		posts:         store.NewPostRepo(db),
+		comments:      store.NewCommentRepo(db),
+		follows:       store.NewFollowRepo(db),
+		blocks:        store.NewBlockRepo(db),
+	}
+}
+
+// Followed notifies followingID that followerID started following them.
+func (n *Notifier) Followed(ctx context.Context, followerID, followingID uuid.UUID) {
+	n.notify(ctx, followerID, &store.Notification{
+		RecipientID: followingID,
+		Type:        store.NotificationFollow,
+		GroupKey:    "follow",
+	})
+}
+
+// FollowRequested notifies targetID, a private account, that requesterID
+// asked to follow them.
+func (n *Notifier) FollowRequested(ctx context.Context, requesterID, targetID uuid.UUID) {
+	n.notify(ctx, requesterID, &store.Notification{
+		RecipientID: targetID,
+		Type:        store.NotificationFollowRequest,
+		GroupKey:    "follow_request",
+	})
+}
+
+// Liked notifies the author of the post or comment that like points at.
+func (n *Notifier) Liked(ctx context.Context, like *store.Like) {
+	notification := &store.Notification{Type: store.NotificationLike, PostID: like.PostID, CommentID: like.CommentID}
+	if like.CommentID != nil {
+		comment, err := n.comments.ByID(ctx, *like.CommentID)
+		if err != nil {
+			log.Printf("[ERROR] notify: loading liked comment %s: %v", *like.CommentID, err)
+			return
+		}
+		notification.RecipientID = comment.UserID
+		notification.PostID = &comment.PostID
+		notification.GroupKey = "like:comment:" + comment.ID.String()
+	} else {
+		post, err := n.posts.ByID(ctx, *like.PostID)
+		if err != nil {
+			log.Printf("[ERROR] notify: loading liked post %s: %v", *like.PostID, err)
+			return
+		}
+		notification.RecipientID = post.UserID
+		notification.GroupKey = "like:post:" + post.ID.String()
+	}
+	n.notify(ctx, like.UserID, notification)
+}
+
+// Commented notifies the post's author of a new comment, or the parent
+// comment's author of a reply, and everyone the comment mentions.
+func (n *Notifier) Commented(ctx context.Context, comment *store.Comment) {
+	if comment.ParentID != nil {
+		parent, err := n.comments.ByID(ctx, *comment.ParentID)
+		if err != nil {
+			log.Printf("[ERROR] notify: loading parent comment %s: %v", *comment.ParentID, err)
+		} else {
+			n.notify(ctx, comment.UserID, &store.Notification{
+				RecipientID: parent.UserID,
+				Type:        store.NotificationReply,
+				PostID:      &comment.PostID,
+				CommentID:   &parent.ID,
+				GroupKey:    "reply:comment:" + parent.ID.String(),
+			})
+		}
+	} else {
+		post, err := n.posts.ByID(ctx, comment.PostID)
+		if err != nil {
+			log.Printf("[ERROR] notify: loading commented post %s: %v", comment.PostID, err)
+		} else {
+			n.notify(ctx, comment.UserID, &store.Notification{
+				RecipientID: post.UserID,
+				Type:        store.NotificationComment,
+				PostID:      &post.ID,
+				GroupKey:    "comment:post:" + post.ID.String(),
+			})
+		}
+	}
+
+	commentID := comment.ID
+	n.mentioned(ctx, comment.UserID, comment.PostID, comment.Content, func(recipient uuid.UUID) *store.Notification {
+		return &store.Notification{
+			RecipientID: recipient,
+			Type:        store.NotificationMention,
+			PostID:      &comment.PostID,
+			CommentID:   &commentID,
+			GroupKey:    "mention:comment:" + commentID.String(),
+		}
+	})
+}
+
+// Posted notifies everyone a new post mentions.
+func (n *Notifier) Posted(ctx context.Context, post *store.Post) {
+	postID := post.ID
+	n.mentioned(ctx, post.UserID, postID, post.Content, func(recipient uuid.UUID) *store.Notification {
+		return &store.Notification{
+			RecipientID: recipient,
+			Type:        store.NotificationMention,
+			PostID:      &postID,
+			GroupKey:    "mention:post:" + postID.String(),
+		}
+	})
+}
+
+// mentioned notifies the existing users @-mentioned in text, which is on or
+// under postID. Users who may not see the post are left out, so a private
+// account's post is not revealed to those it has not approved.
+func (n *Notifier) mentioned(ctx context.Context, actorID, postID uuid.UUID, text string, build func(recipient uuid.UUID) *store.Notification) {
+	usernames := Mentions(text)
+	if len(usernames) == 0 {
+		return
+	}
+	post, err := n.posts.ByID(ctx, postID)
+	if err != nil {
+		log.Printf("[ERROR] notify: loading mentioning post %s: %v", postID, err)
+		return
+	}
+	profiles, err := n.profiles.ByUsernames(ctx, usernames)
+	if err != nil {
+		log.Printf("[ERROR] notify: resolving mentions: %v", err)
+		return
+	}
+	for _, p := range profiles {
+		visible, err := n.follows.CanView(ctx, p.ID, &post.User)
+		if err != nil {
+			log.Printf("[ERROR] notify: checking whether %s may see post %s: %v", p.ID, postID, err)
+			continue
+		}
+		if visible {
+			n.notify(ctx, actorID, build(p.ID))
+		}
+	}
+}
+
+func (n *Notifier) notify(ctx context.Context, actorID uuid.UUID, notification *store.Notification) {
+	// Nobody is notified about their own actions, or about those of someone
+	// they blocked or who blocked them.
+	if notification.RecipientID == actorID {
+		return
+	}
+	blocked, err := n.blocks.Between(ctx, actorID, notification.RecipientID)
+	if err != nil {
+		log.Printf("[ERROR] notify: checking blocks for %s: %v", notification.RecipientID, err)
+		return
+	}
+	if blocked {
+		return
+	}
+	if err := n.notifications.Notify(ctx, notification, actorID); err != nil {
+		log.Printf("[ERROR] notify: writing %s notification for %s: %v", notification.Type, notification.RecipientID, err)
+	}
+}
+
+// Mentions returns the distinct usernames @-mentioned in text, in order of
+// first appearance and at most maxMentions of them. Email addresses are not
+// mentions.
+func Mentions(text string) []string {
+	var usernames []string
+	seen := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.TrimRight(m[1], ".")
+		if len(name) < 3 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		usernames = append(usernames, name)
+		if len(usernames) == maxMentions {
+			break
+		}
+	}
+	return usernames
+}
@@ -0,0 +1,70 @@
+// Package ratelimit limits how often a client may call an endpoint, counting
+// requests in the database through store.RateLimitRepo.
+package ratelimit
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go-common/apierror"
+	"go-common/store"
+)
+
+// Limit allows Requests requests per Window from each client IP to the
+// endpoint called Name.
+type Limit struct {
+	Name     string
+	Requests int
+	Window   time.Duration
+}
+
+// Allow counts r against l and reports whether it may proceed. If not, it
+// has written a rate_limited error with a Retry-After header. When the count
+// cannot be recorded the request is allowed: a broken limiter should not take
+// the endpoint down with it.
+func (l Limit) Allow(w http.ResponseWriter, r *http.Request, db *gorm.DB) bool {
+	limits := store.NewRateLimitRepo(db)
+	count, resetAt, err := limits.Hit(r.Context(), l.Name+":"+ClientIP(r), l.Window)
+	if err != nil {
+		log.Printf("[ERROR] ratelimit: counting %s: %v", l.Name, err)
+		return true
+	}
+	// The first request of a window clears out the counters of l's other
+	// clients whose windows have ended, so the table stays the size of its
+	// active clients without a scheduled job. A failure only delays that.
+	if count == 1 {
+		if _, err := limits.PurgeExpired(r.Context(), l.Name+":", l.Window); err != nil {
+			log.Printf("[ERROR] ratelimit: purging %s: %v", l.Name, err)
+		}
+	}
+	if count <= l.Requests {
+		return true
+	}
+	retry := int(time.Until(resetAt).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	apierror.Write(w, r, apierror.RateLimited, "Too many requests, try again later")
+	return false
+}
+
+// ClientIP returns the IP address of the client that made r. Behind Vercel's
+// proxy that is the first address in X-Forwarded-For; elsewhere it is the
+// remote address of the connection.
+func ClientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+			return ip.String()
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,28 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		forwarded, remote, want string
+	}{
+		{"", "192.0.2.1:1234", "192.0.2.1"},
+		{"203.0.113.7", "10.0.0.1:80", "203.0.113.7"},
+		{"203.0.113.7, 10.0.0.2", "10.0.0.1:80", "203.0.113.7"},
+		{"2001:db8::1", "10.0.0.1:80", "2001:db8::1"},
+		{"not-an-ip", "192.0.2.1:1234", "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := ClientIP(r); got != tt.want {
+			t.Errorf("ClientIP(%q, %q) = %q, want %q", tt.forwarded, tt.remote, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package storage writes public files such as avatars. Storage is the
+// extension point: Local serves development and tests, Supabase production.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage stores publicly readable objects.
+type Storage interface {
+	// Put writes data under key, replacing any object already there, and
+	// returns the URL the object can be fetched from.
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+	// Delete removes the object under key. Deleting an object that does not
+	// exist is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ErrInvalidKey is returned for keys that are empty, absolute or climb out of
+// the storage root.
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// cleanKey validates key and returns it in canonical slash-separated form.
+func cleanKey(key string) (string, error) {
+	clean := path.Clean(key)
+	if key == "" || clean == "." || strings.HasPrefix(clean, "/") || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", ErrInvalidKey
+	}
+	return clean, nil
+}
+
+// Local stores objects as files under Dir and hands out URLs under BaseURL.
+// Something else (cmd/devserver, for one) has to serve Dir at BaseURL.
+type Local struct {
+	Dir     string
+	BaseURL string
+}
+
+// NewLocal returns a Local storing files under dir.
+func NewLocal(dir, baseURL string) *Local {
+	return &Local{Dir: dir, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (s *Local) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	file := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		return "", err
+	}
+	return s.BaseURL + "/" + key, nil
+}
+
+func (s *Local) Delete(ctx context.Context, key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// DeleteAll deletes every key from s, carrying on past failures, and returns
+// the failures joined.
+func DeleteAll(ctx context.Context, s Storage, keys []string) error {
+	var errs []error
+	for _, key := range keys {
+		if err := s.Delete(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Environment variables read by FromEnv.
+const (
+	EnvDriver    = "STORAGE_DRIVER"     // "local" or "supabase"
+	EnvLocalDir  = "STORAGE_LOCAL_DIR"  // Local directory
+	EnvPublicURL = "STORAGE_PUBLIC_URL" // Local URL prefix
+	EnvBucket    = "STORAGE_BUCKET"     // Supabase bucket
+	EnvURL       = "SUPABASE_URL"
+	EnvKey       = "SUPABASE_SERVICE_ROLE_KEY"
+)
+
+// Defaults used by FromEnv.
+const (
+	DefaultPublicURL = "/api/uploads"
+	DefaultBucket    = "avatars"
+)
+
+// DefaultLocalDir is where Local stores files unless STORAGE_LOCAL_DIR says
+// otherwise.
+func DefaultLocalDir() string {
+	return filepath.Join(os.TempDir(), "cirqle-uploads")
+}
+
+// FromEnv returns the storage configured by STORAGE_DRIVER. It defaults to
+// Supabase Storage on Vercel, where the filesystem is not writable, and to
+// local files everywhere else.
+func FromEnv() (Storage, error) {
+	driver := os.Getenv(EnvDriver)
+	if driver == "" {
+		driver = "local"
+		if os.Getenv("VERCEL_ENV") != "" {
+			driver = "supabase"
+		}
+	}
+
+	switch driver {
+	case "local":
+		dir := os.Getenv(EnvLocalDir)
+		if dir == "" {
+			dir = DefaultLocalDir()
+		}
+		baseURL := os.Getenv(EnvPublicURL)
+		if baseURL == "" {
+			baseURL = DefaultPublicURL
+		}
+		return NewLocal(dir, baseURL), nil
+	case "supabase":
+		projectURL, key := os.Getenv(EnvURL), os.Getenv(EnvKey)
+		if projectURL == "" || key == "" {
+			return nil, fmt.Errorf("storage: %s and %s must be set", EnvURL, EnvKey)
+		}
+		bucket := os.Getenv(EnvBucket)
+		if bucket == "" {
+			bucket = DefaultBucket
+		}
+		return NewSupabase(projectURL, key, bucket, nil), nil
+	default:
+		return nil, fmt.Errorf("storage: unknown %s %q", EnvDriver, driver)
+	}
+}
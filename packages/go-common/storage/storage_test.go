@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalPut(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(dir, "/api/uploads/")
+
+	url, err := s.Put(context.Background(), "avatars/u1/a.jpg", []byte("jpeg"), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "/api/uploads/avatars/u1/a.jpg" {
+		t.Errorf("url = %q", url)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "avatars", "u1", "a.jpg"))
+	if err != nil || string(got) != "jpeg" {
+		t.Errorf("file = %q, %v", got, err)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "../escape", "a/../../escape"} {
+		if _, err := s.Put(context.Background(), key, nil, "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) err = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestLocalDelete(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(dir, "/api/uploads")
+	ctx := context.Background()
+
+	if _, err := s.Put(ctx, "media/u1/a.jpg", []byte("jpeg"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "media/u1/a.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "media", "u1", "a.jpg")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file still there: %v", err)
+	}
+	if err := s.Delete(ctx, "media/u1/a.jpg"); err != nil {
+		t.Errorf("deleting a missing file: %v", err)
+	}
+	if err := s.Delete(ctx, "../escape"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Delete(../escape) err = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestSupabasePut(t *testing.T) {
+	var gotPath, gotAuth, gotUpsert, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth, gotUpsert = r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("x-upsert")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Write([]byte(`{"Key":"avatars/u1/a.jpg"}`))
+	}))
+	defer srv.Close()
+
+	s := NewSupabase(srv.URL+"/", "service-key", "avatars", srv.Client())
+	url, err := s.Put(context.Background(), "u1/a.jpg", []byte("jpeg"), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/storage/v1/object/avatars/u1/a.jpg" || gotAuth != "Bearer service-key" || gotUpsert != "true" || gotBody != "jpeg" {
+		t.Errorf("request = %s auth=%q upsert=%q body=%q", gotPath, gotAuth, gotUpsert, gotBody)
+	}
+	if want := srv.URL + "/storage/v1/object/public/avatars/u1/a.jpg"; url != want {
+		t.Errorf("url = %q, want %q", url, want)
+	}
+}
+
+func TestSupabasePutError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"Bucket not found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	s := NewSupabase(srv.URL, "k", "missing", srv.Client())
+	if _, err := s.Put(context.Background(), "a.jpg", nil, "image/jpeg"); err == nil {
+		t.Error("Put succeeded against a failing server")
+	}
+}
+
+func TestSupabaseDelete(t *testing.T) {
+	var gotMethod, gotPath, gotAuth, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotAuth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	s := NewSupabase(srv.URL, "service-key", "avatars", srv.Client())
+	if err := s.Delete(context.Background(), "media/u1/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if gotMethod != http.MethodDelete || gotPath != "/storage/v1/object/avatars" || gotAuth != "Bearer service-key" ||
+		gotBody != `{"prefixes":["media/u1/a.jpg"]}` {
+		t.Errorf("request = %s %s auth=%q body=%q", gotMethod, gotPath, gotAuth, gotBody)
+	}
+}
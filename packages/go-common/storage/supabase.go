@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Supabase stores objects in a public Supabase Storage bucket through the
+// Storage REST API, authenticating with the service role key.
+type Supabase struct {
+	projectURL string
+	key        string
+	bucket     string
+	client     *http.Client
+}
+
+// NewSupabase returns a Supabase storing objects in bucket of the project at
+// projectURL. A nil client uses one with a 30 second timeout.
+func NewSupabase(projectURL, serviceRoleKey, bucket string, client *http.Client) *Supabase {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Supabase{
+		projectURL: strings.TrimRight(projectURL, "/"),
+		key:        serviceRoleKey,
+		bucket:     bucket,
+		client:     client,
+	}
+}
+
+func (s *Supabase) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	object := s.bucket + "/" + key
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		s.projectURL+"/storage/v1/object/"+object, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.key)
+	req.Header.Set("apikey", s.key)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("x-upsert", "true")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("storage: uploading %s: %s: %s", object, resp.Status, bytes.TrimSpace(body))
+	}
+	return s.projectURL + "/storage/v1/object/public/" + object, nil
+}
+
+// Delete goes through the bulk delete endpoint, which succeeds for objects
+// that do not exist.
+func (s *Supabase) Delete(ctx context.Context, key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string][]string{"prefixes": {key}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete,
+		s.projectURL+"/storage/v1/object/"+s.bucket, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.key)
+	req.Header.Set("apikey", s.key)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("storage: deleting %s/%s: %s: %s", s.bucket, key, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
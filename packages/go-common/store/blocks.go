@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// notBlocked returns a condition matching rows whose user column col has no
+// block with the viewer in either direction. It takes the viewer twice.
+func notBlocked(col string) string {
+	return col + " NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?" +
+		" UNION ALL SELECT blocker_id FROM blocks WHERE blocked_id = ?)"
+}
+
+// BlockRepo reads and writes rows in public.blocks.
+type BlockRepo interface {
+	// Create records block, removes any follows and follow requests between
+	// the two users, and reports whether a new block was written. If the
+	// block already exists, block is overwritten with the existing row.
+	Create(ctx context.Context, block *Block) (bool, error)
+	// Delete removes the block, if any, and reports whether there was one.
+	Delete(ctx context.Context, blockerID, blockedID uuid.UUID) (bool, error)
+	// List returns one page of the users blockerID blocked, newest first.
+	List(ctx context.Context, blockerID uuid.UUID, page Page) ([]Block, error)
+	// Exists reports whether blockerID blocked blockedID.
+	Exists(ctx context.Context, blockerID, blockedID uuid.UUID) (bool, error)
+	// Between reports whether either user blocked the other.
+	Between(ctx context.Context, a, b uuid.UUID) (bool, error)
+}
+
+type blockRepo struct {
+	db *gorm.DB
+}
+
+// NewBlockRepo returns a BlockRepo backed by db.
+func NewBlockRepo(db *gorm.DB) BlockRepo {
+	return &blockRepo{db: db}
+}
+
+func (r *blockRepo) Create(ctx context.Context, block *Block) (bool, error) {
+	if block.ID == uuid.Nil {
+		block.ID = uuid.New()
+	}
+
+	var created bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Conflicts are resolved against unique_block (blocker_id, blocked_id).
+		res := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "blocker_id"}, {Name: "blocked_id"}},
+			DoNothing: true,
+		}).Create(block)
+		if res.Error != nil {
+			return res.Error
+		}
+		created = res.RowsAffected > 0
+
+		pair := "(follower_id = @a AND following_id = @b) OR (follower_id = @b AND following_id = @a)"
+		ids := map[string]interface{}{"a": block.BlockerID, "b": block.BlockedID}
+		if err := tx.Where(pair, ids).Delete(&Follow{}).Error; err != nil {
+			return err
+		}
+		pair = "(requester_id = @a AND target_id = @b) OR (requester_id = @b AND target_id = @a)"
+		return tx.Where(pair, ids).Delete(&FollowRequest{}).Error
+	})
+	if err != nil || created {
+		return created, err
+	}
+
+	var existing Block
+	err = r.db.WithContext(ctx).
+		Where("blocker_id = ? AND blocked_id = ?", block.BlockerID, block.BlockedID).
+		First(&existing).Error
+	if err != nil {
+		return false, err
+	}
+	*block = existing
+	return false, nil
+}
+
+func (r *blockRepo) Delete(ctx context.Context, blockerID, blockedID uuid.UUID) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Delete(&Block{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *blockRepo) List(ctx context.Context, blockerID uuid.UUID, page Page) ([]Block, error) {
+	q := r.db.WithContext(ctx).Preload("Blocked").
+		Where("blocks.blocker_id = ?", blockerID)
+	q = afterCursor(q, "blocks.created_at", "blocks.id", page)
+
+	var blocks []Block
+	err := q.Order("blocks.created_at DESC, blocks.id DESC").
+		Limit(page.Limit + 1).
+		Find(&blocks).Error
+	return blocks, err
+}
+
+func (r *blockRepo) Exists(ctx context.Context, blockerID, blockedID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Block{}).
+		Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *blockRepo) Between(ctx context.Context, a, b uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Block{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// BlockCursor returns the pagination cursor positioned at block.
+func BlockCursor(block Block) Cursor {
+	return Cursor{CreatedAt: block.CreatedAt, ID: block.ID}
+}
+
+// MuteRepo reads and writes rows in public.mutes.
+type MuteRepo interface {
+	// Create records mute and reports whether a new row was written. If the
+	// mute already exists, mute is overwritten with the existing row.
+	Create(ctx context.Context, mute *Mute) (bool, error)
+	// Delete removes the mute, if any, and reports whether there was one.
+	Delete(ctx context.Context, muterID, mutedID uuid.UUID) (bool, error)
+	// List returns one page of the users muterID muted, newest first.
+	List(ctx context.Context, muterID uuid.UUID, page Page) ([]Mute, error)
+	// Exists reports whether muterID muted mutedID.
+	Exists(ctx context.Context, muterID, mutedID uuid.UUID) (bool, error)
+}
+
+type muteRepo struct {
+	db *gorm.DB
+}
+
+// NewMuteRepo returns a MuteRepo backed by db.
+func NewMuteRepo(db *gorm.DB) MuteRepo {
+	return &muteRepo{db: db}
+}
+
+func (r *muteRepo) Create(ctx context.Context, mute *Mute) (bool, error) {
+	if mute.ID == uuid.Nil {
+		mute.ID = uuid.New()
+	}
+	// Conflicts are resolved against unique_mute (muter_id, muted_id).
+	res := r.db.WithContext(ctx).Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "muter_id"}, {Name: "muted_id"}},
+		DoNothing: true,
+	}).Create(mute)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+
+	var existing Mute
+	err := r.db.WithContext(ctx).
+		Where("muter_id = ? AND muted_id = ?", mute.MuterID, mute.MutedID).
+		First(&existing).Error
+	if err != nil {
+		return false, err
+	}
+	*mute = existing
+	return false, nil
+}
+
+func (r *muteRepo) Delete(ctx context.Context, muterID, mutedID uuid.UUID) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Where("muter_id = ? AND muted_id = ?", muterID, mutedID).
+		Delete(&Mute{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *muteRepo) List(ctx context.Context, muterID uuid.UUID, page Page) ([]Mute, error) {
+	q := r.db.WithContext(ctx).Preload("Muted").
+		Where("mutes.muter_id = ?", muterID)
+	q = afterCursor(q, "mutes.created_at", "mutes.id", page)
+
+	var mutes []Mute
+	err := q.Order("mutes.created_at DESC, mutes.id DESC").
+		Limit(page.Limit + 1).
+		Find(&mutes).Error
+	return mutes, err
+}
+
+func (r *muteRepo) Exists(ctx context.Context, muterID, mutedID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Mute{}).
+		Where("muter_id = ? AND muted_id = ?", muterID, mutedID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// MuteCursor returns the pagination cursor positioned at mute.
+func MuteCursor(mute Mute) Cursor {
+	return Cursor{CreatedAt: mute.CreatedAt, ID: mute.ID}
+}
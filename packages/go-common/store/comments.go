@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CommentRepo reads and writes rows in public.comments. Comments are always
+// returned with their author preloaded.
+type CommentRepo interface {
+	ByID(ctx context.Context, id uuid.UUID) (*Comment, error)
+	ByPost(ctx context.Context, postID uuid.UUID, parentID *uuid.UUID, page Page) ([]Comment, error)
+	Create(ctx context.Context, comment *Comment) error
+	UpdateContent(ctx context.Context, id uuid.UUID, content string) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type commentRepo struct {
+	db *gorm.DB
+}
+
+// NewCommentRepo returns a CommentRepo backed by db.
+func NewCommentRepo(db *gorm.DB) CommentRepo {
+	return &commentRepo{db: db}
+}
+
+func (r *commentRepo) ByID(ctx context.Context, id uuid.UUID) (*Comment, error) {
+	var comment Comment
+	if err := r.db.WithContext(ctx).Preload("User").First(&comment, "id = ?", id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	if err := r.fillReplyCounts(ctx, []*Comment{&comment}); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// ByPost returns one page of a post's top-level comments, or of the replies to
+// parentID when it is set, oldest first. Like PostRepo.Timeline it fetches
+// page.Limit+1 rows.
+func (r *commentRepo) ByPost(ctx context.Context, postID uuid.UUID, parentID *uuid.UUID, page Page) ([]Comment, error) {
+	q := r.db.WithContext(ctx).Preload("User").Where("comments.post_id = ?", postID)
+	if parentID != nil {
+		q = q.Where("comments.parent_id = ?", *parentID)
+	} else {
+		q = q.Where("comments.parent_id IS NULL")
+	}
+	q = afterCursorAsc(q, "comments.created_at", "comments.id", page)
+
+	var comments []Comment
+	err := q.Order("comments.created_at ASC, comments.id ASC").
+		Limit(page.Limit + 1).
+		Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ptrs := make([]*Comment, len(comments))
+	for i := range comments {
+		ptrs[i] = &comments[i]
+	}
+	return comments, r.fillReplyCounts(ctx, ptrs)
+}
+
+// fillReplyCounts sets ReplyCount on comments with a single grouped query.
+func (r *commentRepo) fillReplyCounts(ctx context.Context, comments []*Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+
+	var rows []struct {
+		ParentID uuid.UUID
+		Count    int64
+	}
+	err := r.db.WithContext(ctx).Model(&Comment{}).
+		Select("parent_id, COUNT(*) AS count").
+		Where("parent_id IN ?", ids).
+		Group("parent_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ParentID] = row.Count
+	}
+	for _, c := range comments {
+		c.ReplyCount = counts[c.ID]
+	}
+	return nil
+}
+
+func (r *commentRepo) Create(ctx context.Context, comment *Comment) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Create(comment).Error
+}
+
+func (r *commentRepo) UpdateContent(ctx context.Context, id uuid.UUID, content string) error {
+	return r.db.WithContext(ctx).Model(&Comment{}).Where("id = ?", id).Update("content", content).Error
+}
+
+func (r *commentRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&Comment{}).Error
+}
+
+// CommentCursor returns the pagination cursor positioned at comment.
+func CommentCursor(comment Comment) Cursor {
+	return Cursor{CreatedAt: comment.CreatedAt, ID: comment.ID}
+}
@@ -0,0 +1,119 @@
+// Package store holds the canonical GORM models for the Supabase schema, the
+// shared database connection and the repositories the api/ functions use.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by repositories when a lookup matches no rows.
+var ErrNotFound = errors.New("record not found")
+
+var (
+	db      *gorm.DB
+	connErr error
+	once    sync.Once
+)
+
+// Connect initializes the database connection.
+// It's designed to be called once per process to prevent connection leaks.
+// With CHECK_SCHEMA_VERSION set it also fails if the database has not been
+// migrated to SchemaVersion.
+func Connect() (*gorm.DB, error) {
+	once.Do(func() {
+		LoadEnv()
+
+		dsn := os.Getenv("DIRECT_URL") // Try DIRECT_URL first
+		if dsn == "" {
+			dsn = os.Getenv("DATABASE_URL") // Fallback to DATABASE_URL
+		}
+		if dsn == "" {
+			connErr = errors.New("neither DIRECT_URL nor DATABASE_URL environment variable is set")
+			return
+		}
+
+		db, connErr = Open(dsn)
+		if connErr != nil {
+			return
+		}
+		if schemaCheckEnabled() {
+			if connErr = CheckSchemaVersion(db); connErr != nil {
+				db = nil
+				return
+			}
+		}
+		log.Println("Database connection successful and pool established.")
+	})
+	if connErr != nil {
+		return nil, connErr
+	}
+	return db, nil
+}
+
+// GetDB returns the existing database connection pool.
+// If the connection hasn't been established, it will be initialized.
+func GetDB() (*gorm.DB, error) {
+	return Connect()
+}
+
+// Open connects to dsn with the pool settings used by the serverless functions.
+func Open(dsn string) (*gorm.DB, error) {
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		PrepareStmt: false, // Disable prepared statement caching for serverless environment
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	}
+	sqlDB.SetMaxIdleConns(1)              // Keep very few idle connections
+	sqlDB.SetMaxOpenConns(1)              // Limit total open connections
+	sqlDB.SetConnMaxLifetime(time.Minute) // Short lifetime
+
+	return conn, nil
+}
+
+var envOnce sync.Once
+
+// LoadEnv loads a .env file for local development. On Vercel the environment
+// is already populated, so nothing is read.
+func LoadEnv() {
+	envOnce.Do(func() {
+		if os.Getenv("VERCEL_ENV") != "" {
+			return
+		}
+		// Functions are run either from their own directory or from the project root.
+		if err := godotenv.Load(".env"); err == nil {
+			return
+		}
+		if err := godotenv.Load("../../.env"); err != nil {
+			log.Println("Warning: .env file not found, relying on environment variables")
+		}
+	})
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
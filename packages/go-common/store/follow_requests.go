@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FollowRequestRepo reads and writes rows in public.follow_requests, the
+// pending follows of private accounts.
+type FollowRequestRepo interface {
+	// Create records req and reports whether a new row was written. If the
+	// request already exists, req is overwritten with the existing row.
+	Create(ctx context.Context, req *FollowRequest) (bool, error)
+	// Pending returns one page of the requests to follow targetID, newest
+	// first, with the requesters preloaded.
+	Pending(ctx context.Context, targetID uuid.UUID, page Page) ([]FollowRequest, error)
+	// ByID returns the request id made to targetID. It returns ErrNotFound if
+	// the request does not exist or was made to someone else.
+	ByID(ctx context.Context, targetID, id uuid.UUID) (*FollowRequest, error)
+	// Exists reports whether requesterID has a pending request to targetID.
+	Exists(ctx context.Context, requesterID, targetID uuid.UUID) (bool, error)
+	// Approve deletes req and creates the follow it asked for, in one
+	// transaction. It returns ErrNotFound if req was already handled.
+	Approve(ctx context.Context, req *FollowRequest) (*Follow, error)
+	// Delete removes the request, if any, and reports whether there was one.
+	// It serves both rejecting and cancelling a request.
+	Delete(ctx context.Context, requesterID, targetID uuid.UUID) (bool, error)
+}
+
+type followRequestRepo struct {
+	db *gorm.DB
+}
+
+// NewFollowRequestRepo returns a FollowRequestRepo backed by db.
+func NewFollowRequestRepo(db *gorm.DB) FollowRequestRepo {
+	return &followRequestRepo{db: db}
+}
+
+func (r *followRequestRepo) Create(ctx context.Context, req *FollowRequest) (bool, error) {
+	if req.ID == uuid.Nil {
+		req.ID = uuid.New()
+	}
+	// Conflicts are resolved against unique_follow_request (requester_id, target_id).
+	res := r.db.WithContext(ctx).Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "requester_id"}, {Name: "target_id"}},
+		DoNothing: true,
+	}).Create(req)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+
+	var existing FollowRequest
+	err := r.db.WithContext(ctx).
+		Where("requester_id = ? AND target_id = ?", req.RequesterID, req.TargetID).
+		First(&existing).Error
+	if err != nil {
+		return false, err
+	}
+	*req = existing
+	return false, nil
+}
+
+func (r *followRequestRepo) Pending(ctx context.Context, targetID uuid.UUID, page Page) ([]FollowRequest, error) {
+	q := r.db.WithContext(ctx).Preload("Requester").
+		Where("follow_requests.target_id = ?", targetID)
+	q = afterCursor(q, "follow_requests.created_at", "follow_requests.id", page)
+
+	var requests []FollowRequest
+	err := q.Order("follow_requests.created_at DESC, follow_requests.id DESC").
+		Limit(page.Limit + 1).
+		Find(&requests).Error
+	return requests, err
+}
+
+func (r *followRequestRepo) ByID(ctx context.Context, targetID, id uuid.UUID) (*FollowRequest, error) {
+	var req FollowRequest
+	err := r.db.WithContext(ctx).Preload("Requester").
+		First(&req, "id = ? AND target_id = ?", id, targetID).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &req, nil
+}
+
+func (r *followRequestRepo) Exists(ctx context.Context, requesterID, targetID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&FollowRequest{}).
+		Where("requester_id = ? AND target_id = ?", requesterID, targetID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *followRequestRepo) Approve(ctx context.Context, req *FollowRequest) (*Follow, error) {
+	follow := &Follow{FollowerID: req.RequesterID, FollowingID: req.TargetID}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ?", req.ID).Delete(&FollowRequest{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		_, err := NewFollowRepo(tx).Create(ctx, follow)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return follow, nil
+}
+
+func (r *followRequestRepo) Delete(ctx context.Context, requesterID, targetID uuid.UUID) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Where("requester_id = ? AND target_id = ?", requesterID, targetID).
+		Delete(&FollowRequest{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// FollowRequestCursor returns the pagination cursor positioned at req.
+func FollowRequestCursor(req FollowRequest) Cursor {
+	return Cursor{CreatedAt: req.CreatedAt, ID: req.ID}
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FollowRepo reads and writes rows in public.follows.
+type FollowRepo interface {
+	// Create makes follow.FollowerID follow follow.FollowingID and reports
+	// whether a new row was written. If the follow already exists, follow is
+	// overwritten with the existing row.
+	Create(ctx context.Context, follow *Follow) (bool, error)
+	// Delete removes the follow, if any, and reports whether there was one.
+	Delete(ctx context.Context, followerID, followingID uuid.UUID) (bool, error)
+	Counts(ctx context.Context, userID uuid.UUID) (FollowCounts, error)
+	// Relationship relates otherID to viewerID in one query.
+	Relationship(ctx context.Context, viewerID, otherID uuid.UUID) (Relationship, error)
+	// IsFollowing reports whether followerID follows followingID.
+	IsFollowing(ctx context.Context, followerID, followingID uuid.UUID) (bool, error)
+	// CanView reports whether viewerID may see owner's posts and follow lists:
+	// owner is viewerID, or neither has blocked the other and owner is public
+	// or has approved viewerID as a follower. viewerID is uuid.Nil for
+	// anonymous callers.
+	CanView(ctx context.Context, viewerID uuid.UUID, owner *Profile) (bool, error)
+	// List returns one page of userID's followers or of the users userID
+	// follows, most recently followed first, annotated for viewerID (uuid.Nil
+	// for anonymous callers).
+	List(ctx context.Context, userID, viewerID uuid.UUID, dir FollowDirection, page Page) ([]FollowListEntry, error)
+}
+
+type followRepo struct {
+	db *gorm.DB
+}
+
+// NewFollowRepo returns a FollowRepo backed by db.
+func NewFollowRepo(db *gorm.DB) FollowRepo {
+	return &followRepo{db: db}
+}
+
+// FollowCounts is how many users follow a user and how many they follow.
+type FollowCounts struct {
+	Followers int64 `json:"followers_count"`
+	Following int64 `json:"following_count"`
+}
+
+func (r *followRepo) Create(ctx context.Context, follow *Follow) (bool, error) {
+	if follow.ID == uuid.Nil {
+		follow.ID = uuid.New()
+	}
+	// Conflicts are resolved against unique_follow (follower_id, following_id).
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "follower_id"}, {Name: "following_id"}},
+		DoNothing: true,
+	}).Create(follow)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+
+	var existing Follow
+	err := r.db.WithContext(ctx).
+		Where("follower_id = ? AND following_id = ?", follow.FollowerID, follow.FollowingID).
+		First(&existing).Error
+	if err != nil {
+		return false, err
+	}
+	*follow = existing
+	return false, nil
+}
+
+func (r *followRepo) Delete(ctx context.Context, followerID, followingID uuid.UUID) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Where("follower_id = ? AND following_id = ?", followerID, followingID).
+		Delete(&Follow{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *followRepo) Counts(ctx context.Context, userID uuid.UUID) (FollowCounts, error) {
+	var counts FollowCounts
+	err := r.db.WithContext(ctx).Model(&Profile{}).
+		Select("followers_count AS followers, following_count AS following").
+		Where("id = ?", userID).
+		Scan(&counts).Error
+	return counts, err
+}
+
+// FollowDirection selects which side of a user's follows List returns.
+type FollowDirection string
+
+const (
+	// Followers lists the users who follow a user.
+	Followers FollowDirection = "followers"
+	// Following lists the users a user follows.
+	Following FollowDirection = "following"
+)
+
+// FollowListEntry is a profile in a followers or following list. FollowedAt
+// is when the follow was made; FollowedByMe and FollowsMe relate the profile
+// to the viewer.
+type FollowListEntry struct {
+	Profile
+	FollowID     uuid.UUID `gorm:"->;-:migration" json:"-"`
+	FollowedAt   time.Time `gorm:"->;-:migration" json:"followed_at"`
+	FollowedByMe bool      `gorm:"->;-:migration" json:"followed_by_me"`
+	FollowsMe    bool      `gorm:"->;-:migration" json:"follows_me"`
+}
+
+const (
+	followedByViewer = "EXISTS (SELECT 1 FROM follows f WHERE f.follower_id = @viewer AND f.following_id = profiles.id)"
+	followsViewer    = "EXISTS (SELECT 1 FROM follows f WHERE f.follower_id = profiles.id AND f.following_id = @viewer)"
+)
+
+func (r *followRepo) List(ctx context.Context, userID, viewerID uuid.UUID, dir FollowDirection, page Page) ([]FollowListEntry, error) {
+	// The listed profile is the other end of each of userID's follows.
+	join, owner := "follows.follower_id", "follows.following_id"
+	if dir == Following {
+		join, owner = "follows.following_id", "follows.follower_id"
+	}
+
+	args := map[string]interface{}{"viewer": viewerID}
+	q := r.db.WithContext(ctx).Model(&Profile{}).
+		Select("profiles.*, follows.id AS follow_id, follows.created_at AS followed_at, "+
+			followedByViewer+" AS followed_by_me, "+followsViewer+" AS follows_me", args).
+		Joins("JOIN follows ON "+join+" = profiles.id").
+		Where(owner+" = ?", userID)
+	q = afterCursor(q, "follows.created_at", "follows.id", page)
+
+	var entries []FollowListEntry
+	err := q.Order("follows.created_at DESC, follows.id DESC").
+		Limit(page.Limit + 1).
+		Find(&entries).Error
+	return entries, err
+}
+
+// FollowListCursor returns the pagination cursor positioned at entry.
+func FollowListCursor(entry FollowListEntry) Cursor {
+	return Cursor{CreatedAt: entry.FollowedAt, ID: entry.FollowID}
+}
+
+// Relationship is how another user relates to the viewer.
+type Relationship struct {
+	IsFollowing     bool `json:"is_following"`
+	FollowsYou      bool `json:"follows_you"`
+	FollowRequested bool `json:"follow_requested"`
+	BlockedByMe     bool `json:"blocked_by_me"`
+	MutedByMe       bool `json:"muted_by_me"`
+}
+
+func (r *followRepo) Relationship(ctx context.Context, viewerID, otherID uuid.UUID) (Relationship, error) {
+	var rel Relationship
+	err := r.db.WithContext(ctx).Raw(`SELECT
+  EXISTS (SELECT 1 FROM follows WHERE follower_id = @viewer AND following_id = @other) AS is_following,
+  EXISTS (SELECT 1 FROM follows WHERE follower_id = @other AND following_id = @viewer) AS follows_you,
+  EXISTS (SELECT 1 FROM follow_requests WHERE requester_id = @viewer AND target_id = @other) AS follow_requested,
+  EXISTS (SELECT 1 FROM blocks WHERE blocker_id = @viewer AND blocked_id = @other) AS blocked_by_me,
+  EXISTS (SELECT 1 FROM mutes WHERE muter_id = @viewer AND muted_id = @other) AS muted_by_me`,
+		map[string]interface{}{"viewer": viewerID, "other": otherID}).Scan(&rel).Error
+	return rel, err
+}
+
+func (r *followRepo) IsFollowing(ctx context.Context, followerID, followingID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Follow{}).
+		Where("follower_id = ? AND following_id = ?", followerID, followingID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *followRepo) CanView(ctx context.Context, viewerID uuid.UUID, owner *Profile) (bool, error) {
+	if viewerID == owner.ID {
+		return true, nil
+	}
+	if viewerID == uuid.Nil {
+		return !owner.IsPrivate, nil
+	}
+	blocked, err := NewBlockRepo(r.db).Between(ctx, viewerID, owner.ID)
+	if err != nil || blocked {
+		return false, err
+	}
+	if !owner.IsPrivate {
+		return true, nil
+	}
+	return r.IsFollowing(ctx, viewerID, owner.ID)
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LikeRepo reads and writes rows in public.likes.
+type LikeRepo interface {
+	// Like records like and reports whether a new row was written; liking
+	// something twice is not an error.
+	Like(ctx context.Context, like *Like) (bool, error)
+	// Unlike removes the caller's like of the target named by like and reports
+	// whether a row was removed.
+	Unlike(ctx context.Context, like *Like) (bool, error)
+	// Count returns the number of likes on the target named by like.
+	Count(ctx context.Context, like *Like) (int64, error)
+	// FillPosts sets LikeCount and LikedByMe on posts for viewerID.
+	FillPosts(ctx context.Context, viewerID uuid.UUID, posts []Post) error
+	// FillComments sets LikeCount and LikedByMe on comments for viewerID.
+	FillComments(ctx context.Context, viewerID uuid.UUID, comments []Comment) error
+}
+
+type likeRepo struct {
+	db *gorm.DB
+}
+
+// NewLikeRepo returns a LikeRepo backed by db.
+func NewLikeRepo(db *gorm.DB) LikeRepo {
+	return &likeRepo{db: db}
+}
+
+// targetColumn returns the column that identifies what like points at.
+func targetColumn(like *Like) (string, uuid.UUID) {
+	if like.CommentID != nil {
+		return "comment_id", *like.CommentID
+	}
+	return "post_id", *like.PostID
+}
+
+func (r *likeRepo) Like(ctx context.Context, like *Like) (bool, error) {
+	column, _ := targetColumn(like)
+	if like.ID == uuid.Nil {
+		like.ID = uuid.New()
+	}
+	// Conflicts are resolved against the partial unique indexes
+	// likes_user_post_key / likes_user_comment_key.
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "user_id"}, {Name: column}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: column + " IS NOT NULL"}}},
+		DoNothing:   true,
+	}).Create(like)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *likeRepo) Unlike(ctx context.Context, like *Like) (bool, error) {
+	column, id := targetColumn(like)
+	res := r.db.WithContext(ctx).
+		Where("user_id = ? AND "+column+" = ?", like.UserID, id).
+		Delete(&Like{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *likeRepo) Count(ctx context.Context, like *Like) (int64, error) {
+	column, id := targetColumn(like)
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Like{}).Where(column+" = ?", id).Count(&count).Error
+	return count, err
+}
+
+type likeStats struct {
+	Count     int64
+	LikedByMe bool
+}
+
+// stats returns the like count and the viewer's like for each id in a single
+// grouped query, so decorating a page of results costs one round trip.
+func (r *likeRepo) stats(ctx context.Context, viewerID uuid.UUID, column string, ids []uuid.UUID) (map[uuid.UUID]likeStats, error) {
+	stats := make(map[uuid.UUID]likeStats, len(ids))
+	if len(ids) == 0 {
+		return stats, nil
+	}
+
+	var rows []struct {
+		TargetID  uuid.UUID
+		Count     int64
+		LikedByMe bool
+	}
+	err := r.db.WithContext(ctx).Model(&Like{}).
+		Select(column+" AS target_id, COUNT(*) AS count, BOOL_OR(user_id = ?) AS liked_by_me", viewerID).
+		Where(column+" IN ?", ids).
+		Group(column).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		stats[row.TargetID] = likeStats{Count: row.Count, LikedByMe: row.LikedByMe}
+	}
+	return stats, nil
+}
+
+func (r *likeRepo) FillPosts(ctx context.Context, viewerID uuid.UUID, posts []Post) error {
+	ids := make([]uuid.UUID, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	stats, err := r.stats(ctx, viewerID, "post_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		s := stats[posts[i].ID]
+		posts[i].LikeCount, posts[i].LikedByMe = s.Count, s.LikedByMe
+	}
+	return nil
+}
+
+func (r *likeRepo) FillComments(ctx context.Context, viewerID uuid.UUID, comments []Comment) error {
+	ids := make([]uuid.UUID, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+	stats, err := r.stats(ctx, viewerID, "comment_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		s := stats[comments[i].ID]
+		comments[i].LikeCount, comments[i].LikedByMe = s.Count, s.LikedByMe
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LinkPreviewRepo reads and writes the preview cache in public.link_previews.
+type LinkPreviewRepo interface {
+	ByURL(ctx context.Context, url string) (*LinkPreview, error)
+	// Save inserts preview, or replaces the cached preview of its URL.
+	Save(ctx context.Context, preview *LinkPreview) error
+	// FillPosts sets LinkPreview on the posts whose link has a successful
+	// preview cached, with one query.
+	FillPosts(ctx context.Context, posts []Post) error
+}
+
+type linkPreviewRepo struct {
+	db *gorm.DB
+}
+
+// NewLinkPreviewRepo returns a LinkPreviewRepo backed by db.
+func NewLinkPreviewRepo(db *gorm.DB) LinkPreviewRepo {
+	return &linkPreviewRepo{db: db}
+}
+
+func (r *linkPreviewRepo) ByURL(ctx context.Context, url string) (*LinkPreview, error) {
+	var preview LinkPreview
+	if err := r.db.WithContext(ctx).First(&preview, "url = ?", url).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &preview, nil
+}
+
+func (r *linkPreviewRepo) Save(ctx context.Context, preview *LinkPreview) error {
+	// Select("*") writes the zero values too, so a failed refetch clears
+	// the fields of an older preview.
+	return r.db.WithContext(ctx).Select("*").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "url"}},
+		UpdateAll: true,
+	}).Create(preview).Error
+}
+
+func (r *linkPreviewRepo) FillPosts(ctx context.Context, posts []Post) error {
+	var urls []string
+	for i := range posts {
+		if posts[i].LinkURL != nil {
+			urls = append(urls, *posts[i].LinkURL)
+		}
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	var previews []LinkPreview
+	if err := r.db.WithContext(ctx).Where("url IN ? AND NOT failed", urls).Find(&previews).Error; err != nil {
+		return err
+	}
+	byURL := make(map[string]*LinkPreview, len(previews))
+	for i := range previews {
+		byURL[previews[i].URL] = &previews[i]
+	}
+	for i := range posts {
+		if posts[i].LinkURL != nil {
+			posts[i].LinkPreview = byURL[*posts[i].LinkURL]
+		}
+	}
+	return nil
+}
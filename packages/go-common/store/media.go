@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxAttachments is the most attachments a post can carry. The database
+// enforces it too (media_attachments_position).
+const MaxAttachments = 4
+
+// ErrAttachmentUnavailable is returned by PostRepo.Create when an attachment
+// does not exist, belongs to someone else or is already part of a post.
+var ErrAttachmentUnavailable = errors.New("attachment unavailable")
+
+// MediaRepo reads and writes rows in public.media_attachments. Attachments
+// are created unattached and attached by PostRepo.Create.
+type MediaRepo interface {
+	ByID(ctx context.Context, id uuid.UUID) (*Attachment, error)
+	Create(ctx context.Context, attachment *Attachment) error
+	UpdateAltText(ctx context.Context, id uuid.UUID, altText string) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	// PurgeUnattached deletes the uploads of userID created before cutoff
+	// that were never attached to a post and returns them, so their stored
+	// files can be deleted too.
+	PurgeUnattached(ctx context.Context, userID uuid.UUID, cutoff time.Time) ([]Attachment, error)
+	// FillPosts sets Attachments on posts, in position order, with one query.
+	FillPosts(ctx context.Context, posts []Post) error
+}
+
+type mediaRepo struct {
+	db *gorm.DB
+}
+
+// NewMediaRepo returns a MediaRepo backed by db.
+func NewMediaRepo(db *gorm.DB) MediaRepo {
+	return &mediaRepo{db: db}
+}
+
+func (r *mediaRepo) ByID(ctx context.Context, id uuid.UUID) (*Attachment, error) {
+	var attachment Attachment
+	if err := r.db.WithContext(ctx).First(&attachment, "id = ?", id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &attachment, nil
+}
+
+func (r *mediaRepo) Create(ctx context.Context, attachment *Attachment) error {
+	return r.db.WithContext(ctx).Create(attachment).Error
+}
+
+func (r *mediaRepo) UpdateAltText(ctx context.Context, id uuid.UUID, altText string) error {
+	return r.db.WithContext(ctx).Model(&Attachment{}).Where("id = ?", id).Update("alt_text", altText).Error
+}
+
+func (r *mediaRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&Attachment{}).Error
+}
+
+func (r *mediaRepo) PurgeUnattached(ctx context.Context, userID uuid.UUID, cutoff time.Time) ([]Attachment, error) {
+	var purged []Attachment
+	err := r.db.WithContext(ctx).Clauses(clause.Returning{}).
+		Where("user_id = ? AND post_id IS NULL AND created_at < ?", userID, cutoff).Delete(&purged).Error
+	return purged, err
+}
+
+func (r *mediaRepo) FillPosts(ctx context.Context, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	var attachments []Attachment
+	err := r.db.WithContext(ctx).Where("post_id IN ?", ids).Order("post_id, position").Find(&attachments).Error
+	if err != nil {
+		return err
+	}
+
+	byPost := make(map[uuid.UUID][]Attachment)
+	for _, a := range attachments {
+		byPost[*a.PostID] = append(byPost[*a.PostID], a)
+	}
+	for i := range posts {
+		posts[i].Attachments = byPost[posts[i].ID]
+		if posts[i].Attachments == nil {
+			posts[i].Attachments = []Attachment{}
+		}
+	}
+	return nil
+}
+
+// attach attaches the uploads ids of userID to postID, in order, within tx.
+func attach(tx *gorm.DB, userID, postID uuid.UUID, ids []uuid.UUID) error {
+	for i, id := range ids {
+		res := tx.Model(&Attachment{}).
+			Where("id = ? AND user_id = ? AND post_id IS NULL", id, userID).
+			Updates(map[string]interface{}{"post_id": postID, "position": i})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return ErrAttachmentUnavailable
+		}
+	}
+	return nil
+}
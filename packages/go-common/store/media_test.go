@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMediaPurgeUnattached(t *testing.T) {
+	db := apitest.DB(t)
+	ctx := context.Background()
+	attachments := store.NewMediaRepo(db)
+	user := apitest.CreateUser(t, db, "uploader")
+	other := apitest.CreateUser(t, db, "other")
+	post := apitest.CreatePost(t, db, user.ID, "with a picture")
+
+	upload := func(userID uuid.UUID, age time.Duration, postID *uuid.UUID) uuid.UUID {
+		t.Helper()
+		id := uuid.New()
+		a := &store.Attachment{ID: id, UserID: userID, PostID: postID, Type: store.AttachmentImage,
+			ContentType: "image/jpeg", StorageKey: "media/" + id.String() + ".jpg", URL: "/" + id.String(),
+			Width: 1, Height: 1, CreatedAt: time.Now().Add(-age)}
+		if err := attachments.Create(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	stale := upload(user.ID, 48*time.Hour, nil)
+	fresh := upload(user.ID, time.Minute, nil)
+	attached := upload(user.ID, 48*time.Hour, &post.ID)
+	othersStale := upload(other.ID, 48*time.Hour, nil)
+
+	purged, err := attachments.PurgeUnattached(ctx, user.ID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeUnattached: %v", err)
+	}
+	if len(purged) != 1 || purged[0].ID != stale || purged[0].StorageKey != "media/"+stale.String()+".jpg" {
+		t.Errorf("PurgeUnattached returned %+v, want only the stale upload", purged)
+	}
+
+	for _, tt := range []struct {
+		name string
+		id   uuid.UUID
+		kept bool
+	}{
+		{"stale", stale, false},
+		{"fresh", fresh, true},
+		{"attached", attached, true},
+		{"someone else's stale", othersStale, true},
+	} {
+		_, err := attachments.ByID(ctx, tt.id)
+		if kept := err == nil; kept != tt.kept {
+			t.Errorf("%s upload kept = %v (%v), want %v", tt.name, kept, err, tt.kept)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MessageRepo reads and writes conversations, their participants and their
+// messages. Every read that takes a viewer only returns conversations the
+// viewer participates in; anything else is reported as ErrNotFound so that
+// callers cannot probe for other people's conversations.
+type MessageRepo interface {
+	// Start creates conv with the given participants (the creator is always
+	// added) and reports whether a new conversation was written. Starting a
+	// 1:1 conversation that already exists returns the existing one. It
+	// returns ErrNotFound if any participant has no profile.
+	Start(ctx context.Context, conv *Conversation, participantIDs []uuid.UUID) (bool, error)
+	// Conversation returns a conversation with its participants.
+	Conversation(ctx context.Context, id, viewerID uuid.UUID) (*Conversation, error)
+	// Conversations returns one page of the viewer's conversations, most
+	// recently active first, with participants, last message and unread count.
+	Conversations(ctx context.Context, viewerID uuid.UUID, page Page) ([]Conversation, error)
+	// Messages returns one page of conv's history, newest first, with ReadBy
+	// filled from conv.Participants.
+	Messages(ctx context.Context, conv *Conversation, page Page) ([]Message, error)
+	// MessageByID returns a message in conversationID.
+	MessageByID(ctx context.Context, conversationID, id uuid.UUID) (*Message, error)
+	// Send stores msg, bumps the conversation's last_message_at and marks it
+	// read for the sender.
+	Send(ctx context.Context, msg *Message) error
+	// MarkRead moves userID's read receipt in conversationID forward to upTo.
+	// Receipts never move backwards.
+	MarkRead(ctx context.Context, conversationID, userID uuid.UUID, upTo time.Time) error
+}
+
+type messageRepo struct {
+	db *gorm.DB
+}
+
+// NewMessageRepo returns a MessageRepo backed by db.
+func NewMessageRepo(db *gorm.DB) MessageRepo {
+	return &messageRepo{db: db}
+}
+
+// DirectKey identifies the 1:1 conversation between a and b regardless of who
+// started it.
+func DirectKey(a, b uuid.UUID) string {
+	x, y := a.String(), b.String()
+	if y < x {
+		x, y = y, x
+	}
+	return x + ":" + y
+}
+
+func (r *messageRepo) Start(ctx context.Context, conv *Conversation, participantIDs []uuid.UUID) (bool, error) {
+	ids := uniqueIDs(append([]uuid.UUID{*conv.CreatedBy}, participantIDs...))
+
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var found int64
+		if err := tx.Model(&Profile{}).Where("id IN ?", ids).Count(&found).Error; err != nil {
+			return err
+		}
+		if found != int64(len(ids)) {
+			return ErrNotFound
+		}
+
+		if conv.ID == uuid.Nil {
+			conv.ID = uuid.New()
+		}
+		if conv.CreatedAt.IsZero() {
+			conv.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		}
+		conv.LastMessageAt = conv.CreatedAt
+
+		res := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "direct_key"}},
+			DoNothing: true,
+		}).Create(conv)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// A 1:1 conversation between these users already exists. Load it
+			// into a fresh value: GORM would add conv's new primary key to the
+			// conditions and find nothing.
+			var existing Conversation
+			if err := tx.First(&existing, "direct_key = ?", *conv.DirectKey).Error; err != nil {
+				return notFound(err)
+			}
+			*conv = existing
+			return nil
+		}
+
+		participants := make([]ConversationParticipant, len(ids))
+		for i, id := range ids {
+			participants[i] = ConversationParticipant{ConversationID: conv.ID, UserID: id, JoinedAt: conv.CreatedAt}
+		}
+		if err := tx.Omit(clause.Associations).Create(&participants).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return created, r.fillParticipants(ctx, []*Conversation{conv})
+}
+
+func (r *messageRepo) Conversation(ctx context.Context, id, viewerID uuid.UUID) (*Conversation, error) {
+	var conv Conversation
+	err := r.db.WithContext(ctx).
+		Joins("JOIN conversation_participants p ON p.conversation_id = conversations.id AND p.user_id = ?", viewerID).
+		First(&conv, "conversations.id = ?", id).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &conv, r.fillParticipants(ctx, []*Conversation{&conv})
+}
+
+func (r *messageRepo) Conversations(ctx context.Context, viewerID uuid.UUID, page Page) ([]Conversation, error) {
+	q := r.db.WithContext(ctx).
+		Joins("JOIN conversation_participants p ON p.conversation_id = conversations.id AND p.user_id = ?", viewerID)
+	q = afterCursor(q, "conversations.last_message_at", "conversations.id", page)
+
+	var convs []Conversation
+	err := q.Order("conversations.last_message_at DESC, conversations.id DESC").
+		Limit(page.Limit + 1).
+		Find(&convs).Error
+	if err != nil || len(convs) == 0 {
+		return convs, err
+	}
+
+	ptrs := make([]*Conversation, len(convs))
+	byID := make(map[uuid.UUID]*Conversation, len(convs))
+	ids := make([]uuid.UUID, len(convs))
+	for i := range convs {
+		ptrs[i] = &convs[i]
+		byID[convs[i].ID] = &convs[i]
+		ids[i] = convs[i].ID
+	}
+	if err := r.fillParticipants(ctx, ptrs); err != nil {
+		return nil, err
+	}
+
+	var last []Message
+	latest := r.db.Model(&Message{}).
+		Select("DISTINCT ON (conversation_id) id").
+		Where("conversation_id IN ?", ids).
+		Order("conversation_id, created_at DESC, id DESC")
+	if err := r.db.WithContext(ctx).Preload("Sender").Where("id IN (?)", latest).Find(&last).Error; err != nil {
+		return nil, err
+	}
+	for i := range last {
+		byID[last[i].ConversationID].LastMessage = &last[i]
+	}
+
+	var unread []struct {
+		ConversationID uuid.UUID
+		Count          int64
+	}
+	err = r.db.WithContext(ctx).Table("messages m").
+		Select("m.conversation_id, COUNT(*) AS count").
+		Joins("JOIN conversation_participants p ON p.conversation_id = m.conversation_id AND p.user_id = ?", viewerID).
+		Where("m.conversation_id IN ? AND m.sender_id <> ?", ids, viewerID).
+		Where("p.last_read_at IS NULL OR m.created_at > p.last_read_at").
+		Group("m.conversation_id").
+		Scan(&unread).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range unread {
+		byID[row.ConversationID].UnreadCount = row.Count
+	}
+	return convs, nil
+}
+
+func (r *messageRepo) Messages(ctx context.Context, conv *Conversation, page Page) ([]Message, error) {
+	q := r.db.WithContext(ctx).Preload("Sender").Where("messages.conversation_id = ?", conv.ID)
+	q = afterCursor(q, "messages.created_at", "messages.id", page)
+
+	var messages []Message
+	err := q.Order("messages.created_at DESC, messages.id DESC").
+		Limit(page.Limit + 1).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		messages[i].ReadBy = readBy(&messages[i], conv.Participants)
+	}
+	return messages, nil
+}
+
+func (r *messageRepo) MessageByID(ctx context.Context, conversationID, id uuid.UUID) (*Message, error) {
+	var msg Message
+	err := r.db.WithContext(ctx).Preload("Sender").
+		First(&msg, "id = ? AND conversation_id = ?", id, conversationID).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &msg, nil
+}
+
+func (r *messageRepo) Send(ctx context.Context, msg *Message) error {
+	if msg.ID == uuid.Nil {
+		msg.ID = uuid.New()
+	}
+	// Truncate to the database's precision so that receipts compare equal to
+	// the timestamps read back from it.
+	msg.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(msg).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&Conversation{}).
+			Where("id = ? AND last_message_at < ?", msg.ConversationID, msg.CreatedAt).
+			Update("last_message_at", msg.CreatedAt).Error
+		if err != nil {
+			return err
+		}
+		return markRead(tx, msg.ConversationID, msg.SenderID, msg.CreatedAt)
+	})
+}
+
+func (r *messageRepo) MarkRead(ctx context.Context, conversationID, userID uuid.UUID, upTo time.Time) error {
+	return markRead(r.db.WithContext(ctx), conversationID, userID, upTo)
+}
+
+func markRead(db *gorm.DB, conversationID, userID uuid.UUID, upTo time.Time) error {
+	// GREATEST ignores NULLs, so an unset receipt simply becomes upTo.
+	return db.Model(&ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Update("last_read_at", gorm.Expr("GREATEST(last_read_at, ?)", upTo)).Error
+}
+
+// fillParticipants sets Participants on convs with a single query.
+func (r *messageRepo) fillParticipants(ctx context.Context, convs []*Conversation) error {
+	if len(convs) == 0 {
+		return nil
+	}
+	byID := make(map[uuid.UUID]*Conversation, len(convs))
+	ids := make([]uuid.UUID, len(convs))
+	for i, c := range convs {
+		c.Participants = []ConversationParticipant{}
+		byID[c.ID] = c
+		ids[i] = c.ID
+	}
+
+	var participants []ConversationParticipant
+	err := r.db.WithContext(ctx).Preload("User").
+		Where("conversation_id IN ?", ids).
+		Order("joined_at ASC, user_id ASC").
+		Find(&participants).Error
+	if err != nil {
+		return err
+	}
+	for _, p := range participants {
+		c := byID[p.ConversationID]
+		c.Participants = append(c.Participants, p)
+	}
+	return nil
+}
+
+// readBy lists the participants other than the sender whose read receipt
+// covers msg.
+func readBy(msg *Message, participants []ConversationParticipant) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, p := range participants {
+		if p.UserID == msg.SenderID || p.LastReadAt == nil {
+			continue
+		}
+		if !p.LastReadAt.Before(msg.CreatedAt) {
+			ids = append(ids, p.UserID)
+		}
+	}
+	return ids
+}
+
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	out := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// ConversationCursor returns the pagination cursor positioned at conv.
+func ConversationCursor(conv Conversation) Cursor {
+	return Cursor{CreatedAt: conv.LastMessageAt, ID: conv.ID}
+}
+
+// MessageCursor returns the pagination cursor positioned at msg.
+func MessageCursor(msg Message) Cursor {
+	return Cursor{CreatedAt: msg.CreatedAt, ID: msg.ID}
+}
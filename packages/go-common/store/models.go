@@ -0,0 +1,328 @@
+package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Profile matches the public.profiles table.
+type Profile struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Username  string     `gorm:"unique" json:"username"`
+	FullName  *string    `json:"full_name"`
+	UpdatedAt *time.Time `json:"updated_at"`
+	AvatarURL *string    `json:"avatar_url"`
+	Website   *string    `json:"website"`
+	Bio       *string    `json:"bio"`
+	IsPrivate bool       `gorm:"not null;default:false" json:"is_private"`
+	// The counters are maintained by triggers on follows and posts, so they
+	// are never written from Go.
+	FollowersCount int64 `gorm:"->;-:migration" json:"followers_count"`
+	FollowingCount int64 `gorm:"->;-:migration" json:"following_count"`
+	PostsCount     int64 `gorm:"->;-:migration" json:"posts_count"`
+}
+
+func (Profile) TableName() string {
+	return "profiles"
+}
+
+// Post matches the public.posts table.
+type Post struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	Content   string    `gorm:"not null" json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	User      Profile   `gorm:"foreignKey:UserID" json:"user"`
+	// LinkURL is the normalised first URL in Content, if any.
+	LinkURL *string `json:"-"`
+
+	LikeCount   int64        `gorm:"-" json:"like_count"`
+	LikedByMe   bool         `gorm:"-" json:"liked_by_me"`
+	Attachments []Attachment `gorm:"-" json:"attachments"`
+	LinkPreview *LinkPreview `gorm:"-" json:"link_preview"`
+}
+
+func (Post) TableName() string {
+	return "posts"
+}
+
+// LinkPreview matches the public.link_previews table: what the page at URL
+// says about itself, as of FetchedAt. Failed marks a fetch that produced no
+// preview.
+type LinkPreview struct {
+	URL         string    `gorm:"column:url;primaryKey" json:"url"`
+	Title       string    `gorm:"not null;default:''" json:"title"`
+	Description string    `gorm:"not null;default:''" json:"description"`
+	ImageURL    string    `gorm:"column:image_url;not null;default:''" json:"image_url"`
+	SiteName    string    `gorm:"not null;default:''" json:"site_name"`
+	Failed      bool      `gorm:"not null;default:false" json:"-"`
+	FetchedAt   time.Time `gorm:"not null" json:"fetched_at"`
+}
+
+func (LinkPreview) TableName() string {
+	return "link_previews"
+}
+
+// Attachment types.
+const (
+	AttachmentImage = "image"
+	AttachmentVideo = "video"
+)
+
+// Attachment matches the public.media_attachments table. PostID is nil until
+// the upload is attached to a post. Images have a thumbnail; videos do not.
+type Attachment struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	PostID       *uuid.UUID `gorm:"type:uuid" json:"post_id"`
+	Position     int        `gorm:"not null;default:0" json:"position"`
+	Type         string     `gorm:"not null" json:"type"`
+	ContentType  string     `gorm:"not null" json:"content_type"`
+	StorageKey   string     `gorm:"not null" json:"storage_key"`
+	URL          string     `gorm:"column:url;not null" json:"url"`
+	ThumbnailKey *string    `json:"thumbnail_key"`
+	ThumbnailURL *string    `gorm:"column:thumbnail_url" json:"thumbnail_url"`
+	Width        int        `gorm:"not null" json:"width"`
+	Height       int        `gorm:"not null" json:"height"`
+	AltText      string     `gorm:"not null;default:''" json:"alt_text"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (Attachment) TableName() string {
+	return "media_attachments"
+}
+
+// StorageKeys returns the keys of the attachment's stored files: the file
+// and, for images, its thumbnail.
+func (a Attachment) StorageKeys() []string {
+	keys := []string{a.StorageKey}
+	if a.ThumbnailKey != nil {
+		keys = append(keys, *a.ThumbnailKey)
+	}
+	return keys
+}
+
+// Comment matches the public.comments table. ParentID is set on replies.
+type Comment struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PostID     uuid.UUID  `gorm:"type:uuid;not null" json:"post_id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	ParentID   *uuid.UUID `gorm:"type:uuid" json:"parent_id"`
+	Content    string     `gorm:"not null" json:"content"`
+	CreatedAt  time.Time  `json:"created_at"`
+	User       Profile    `gorm:"foreignKey:UserID" json:"user"`
+	ReplyCount int64      `gorm:"-" json:"reply_count"`
+	LikeCount  int64      `gorm:"-" json:"like_count"`
+	LikedByMe  bool       `gorm:"-" json:"liked_by_me"`
+}
+
+func (Comment) TableName() string {
+	return "comments"
+}
+
+// Like matches the public.likes table. Exactly one of PostID and CommentID is set.
+type Like struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PostID    *uuid.UUID `gorm:"type:uuid" json:"post_id"`
+	CommentID *uuid.UUID `gorm:"type:uuid" json:"comment_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (Like) TableName() string {
+	return "likes"
+}
+
+// Follow matches the public.follows table.
+type Follow struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	FollowerID  uuid.UUID `gorm:"type:uuid;not null" json:"follower_id"`
+	FollowingID uuid.UUID `gorm:"type:uuid;not null" json:"following_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (Follow) TableName() string {
+	return "follows"
+}
+
+// FollowRequest matches the public.follow_requests table: a pending request
+// to follow a private account.
+type FollowRequest struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RequesterID uuid.UUID `gorm:"type:uuid;not null" json:"requester_id"`
+	TargetID    uuid.UUID `gorm:"type:uuid;not null" json:"target_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Requester   Profile   `gorm:"foreignKey:RequesterID" json:"requester"`
+}
+
+func (FollowRequest) TableName() string {
+	return "follow_requests"
+}
+
+// Block matches the public.blocks table. Blocked is the user BlockerID
+// blocked.
+type Block struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BlockerID uuid.UUID `gorm:"type:uuid;not null" json:"blocker_id"`
+	BlockedID uuid.UUID `gorm:"type:uuid;not null" json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Blocked   Profile   `gorm:"foreignKey:BlockedID" json:"blocked"`
+}
+
+func (Block) TableName() string {
+	return "blocks"
+}
+
+// Mute matches the public.mutes table. Muted is the user MuterID muted.
+type Mute struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	MuterID   uuid.UUID `gorm:"type:uuid;not null" json:"muter_id"`
+	MutedID   uuid.UUID `gorm:"type:uuid;not null" json:"muted_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Muted     Profile   `gorm:"foreignKey:MutedID" json:"muted"`
+}
+
+func (Mute) TableName() string {
+	return "mutes"
+}
+
+// UsernameChange matches the public.username_history table: one row per
+// rename.
+type UsernameChange struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	OldUsername string    `gorm:"not null" json:"old_username"`
+	NewUsername string    `gorm:"not null" json:"new_username"`
+	ChangedAt   time.Time `gorm:"not null;default:now()" json:"changed_at"`
+}
+
+func (UsernameChange) TableName() string {
+	return "username_history"
+}
+
+// Conversation matches the public.conversations table. DirectKey is set only
+// on 1:1 conversations so that a pair of users shares a single thread.
+type Conversation struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Title         *string    `json:"title"`
+	IsGroup       bool       `gorm:"not null" json:"is_group"`
+	DirectKey     *string    `json:"-"`
+	CreatedBy     *uuid.UUID `gorm:"type:uuid" json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastMessageAt time.Time  `json:"last_message_at"`
+
+	Participants []ConversationParticipant `gorm:"-" json:"participants"`
+	LastMessage  *Message                  `gorm:"-" json:"last_message"`
+	UnreadCount  int64                     `gorm:"-" json:"unread_count"`
+}
+
+func (Conversation) TableName() string {
+	return "conversations"
+}
+
+// ConversationParticipant matches the public.conversation_participants table.
+// LastReadAt is the participant's read receipt: every message created at or
+// before it has been read.
+type ConversationParticipant struct {
+	ConversationID uuid.UUID  `gorm:"type:uuid;primaryKey" json:"conversation_id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id"`
+	JoinedAt       time.Time  `json:"joined_at"`
+	LastReadAt     *time.Time `json:"last_read_at"`
+	User           Profile    `gorm:"foreignKey:UserID" json:"user"`
+}
+
+func (ConversationParticipant) TableName() string {
+	return "conversation_participants"
+}
+
+// Message matches the public.messages table.
+type Message struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ConversationID uuid.UUID `gorm:"type:uuid;not null" json:"conversation_id"`
+	SenderID       uuid.UUID `gorm:"type:uuid;not null" json:"sender_id"`
+	Content        string    `gorm:"not null" json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+	Sender         Profile   `gorm:"foreignKey:SenderID" json:"sender"`
+
+	ReadBy []uuid.UUID `gorm:"-" json:"read_by"`
+}
+
+func (Message) TableName() string {
+	return "messages"
+}
+
+// Story matches the public.stories table. A story is only visible until
+// ExpiresAt; reads filter expired rows out and PurgeExpired deletes them.
+type Story struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	MediaURL  string    `gorm:"not null" json:"media_url"`
+	MediaType string    `gorm:"not null" json:"media_type"`
+	Caption   *string   `json:"caption"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	User      Profile   `gorm:"foreignKey:UserID" json:"user"`
+
+	ViewedByMe bool `gorm:"-" json:"viewed_by_me"`
+}
+
+func (Story) TableName() string {
+	return "stories"
+}
+
+// StoryView matches the public.story_views table: one row per viewer per story.
+type StoryView struct {
+	StoryID  uuid.UUID `gorm:"type:uuid;primaryKey" json:"story_id"`
+	ViewerID uuid.UUID `gorm:"type:uuid;primaryKey" json:"viewer_id"`
+	ViewedAt time.Time `gorm:"autoCreateTime" json:"viewed_at"`
+	Viewer   Profile   `gorm:"foreignKey:ViewerID" json:"viewer"`
+}
+
+func (StoryView) TableName() string {
+	return "story_views"
+}
+
+// Notification types.
+const (
+	NotificationFollow        = "follow"
+	NotificationFollowRequest = "follow_request"
+	NotificationLike          = "like"
+	NotificationComment       = "comment"
+	NotificationReply         = "reply"
+	NotificationMention       = "mention"
+)
+
+// Notification matches the public.notifications table. Events that share a
+// GroupKey are folded into one unread notification ("alice and 3 others liked
+// your post"); the people behind it are in public.notification_actors.
+type Notification struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RecipientID uuid.UUID  `gorm:"type:uuid;not null" json:"recipient_id"`
+	Type        string     `gorm:"not null" json:"type"`
+	PostID      *uuid.UUID `gorm:"type:uuid" json:"post_id"`
+	CommentID   *uuid.UUID `gorm:"type:uuid" json:"comment_id"`
+	GroupKey    string     `gorm:"not null" json:"-"`
+	ActorCount  int64      `gorm:"not null;default:1" json:"actor_count"`
+	ReadAt      *time.Time `json:"read_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Actors  []Profile `gorm:"-" json:"actors"`
+	Summary string    `gorm:"-" json:"summary"`
+}
+
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// NotificationActor matches the public.notification_actors table.
+type NotificationActor struct {
+	NotificationID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ActorID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CreatedAt      time.Time
+}
+
+func (NotificationActor) TableName() string {
+	return "notification_actors"
+}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// notificationActorsShown is how many actors List loads per notification.
+const notificationActorsShown = 3
+
+// NotificationRepo reads and writes rows in public.notifications.
+type NotificationRepo interface {
+	// Notify records that actorID caused n. If the recipient already has an
+	// unread notification with the same GroupKey, the actor is added to it and
+	// it moves back to the top instead of a new row being written.
+	Notify(ctx context.Context, n *Notification, actorID uuid.UUID) error
+	// List returns one page of recipientID's notifications, most recently
+	// updated first, with up to three of the latest actors and a summary.
+	List(ctx context.Context, recipientID uuid.UUID, page Page) ([]Notification, error)
+	UnreadCount(ctx context.Context, recipientID uuid.UUID) (int64, error)
+	// MarkRead marks one of recipientID's notifications read and reports
+	// whether it was unread. It returns ErrNotFound if the notification does
+	// not belong to recipientID.
+	MarkRead(ctx context.Context, recipientID, id uuid.UUID) (bool, error)
+	// MarkAllRead marks every unread notification of recipientID read and
+	// returns how many changed.
+	MarkAllRead(ctx context.Context, recipientID uuid.UUID) (int64, error)
+}
+
+type notificationRepo struct {
+	db *gorm.DB
+}
+
+// NewNotificationRepo returns a NotificationRepo backed by db.
+func NewNotificationRepo(db *gorm.DB) NotificationRepo {
+	return &notificationRepo{db: db}
+}
+
+func (r *notificationRepo) Notify(ctx context.Context, n *Notification, actorID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Leaving ID unset makes the insert return the id of whichever row
+		// it ends up touching, new or existing.
+		n.ID = uuid.Nil
+		err := tx.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "recipient_id"}, {Name: "group_key"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "read_at IS NULL"}}},
+			DoUpdates:   clause.AssignmentColumns([]string{"updated_at"}),
+		}).Create(n).Error
+		if err != nil {
+			return err
+		}
+
+		// Re-acting (e.g. like, unlike, like) moves the actor to the front
+		// without counting them twice.
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "notification_id"}, {Name: "actor_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"created_at"}),
+		}).Create(&NotificationActor{NotificationID: n.ID, ActorID: actorID}).Error
+		if err != nil {
+			return err
+		}
+
+		count := tx.Model(&NotificationActor{}).Select("COUNT(*)").Where("notification_id = ?", n.ID)
+		return tx.Model(&Notification{}).Where("id = ?", n.ID).
+			UpdateColumn("actor_count", count).Error
+	})
+}
+
+func (r *notificationRepo) List(ctx context.Context, recipientID uuid.UUID, page Page) ([]Notification, error) {
+	q := r.db.WithContext(ctx).Where("notifications.recipient_id = ?", recipientID)
+	q = afterCursor(q, "notifications.updated_at", "notifications.id", page)
+
+	var notifications []Notification
+	err := q.Order("notifications.updated_at DESC, notifications.id DESC").
+		Limit(page.Limit + 1).
+		Find(&notifications).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := r.fillActors(ctx, notifications); err != nil {
+		return nil, err
+	}
+	for i := range notifications {
+		notifications[i].Summary = summarize(&notifications[i])
+	}
+	return notifications, nil
+}
+
+// fillActors sets Actors on notifications to their latest actors, newest
+// first, with one query for the actor ids and one for their profiles.
+func (r *notificationRepo) fillActors(ctx context.Context, notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	byID := make(map[uuid.UUID]*Notification, len(notifications))
+	ids := make([]uuid.UUID, len(notifications))
+	for i := range notifications {
+		notifications[i].Actors = []Profile{}
+		byID[notifications[i].ID] = &notifications[i]
+		ids[i] = notifications[i].ID
+	}
+
+	var rows []struct {
+		NotificationID uuid.UUID
+		ActorID        uuid.UUID
+	}
+	ranked := r.db.Model(&NotificationActor{}).
+		Select("notification_id, actor_id, created_at, ROW_NUMBER() OVER (PARTITION BY notification_id ORDER BY created_at DESC, actor_id) AS actor_rank").
+		Where("notification_id IN ?", ids)
+	err := r.db.WithContext(ctx).Table("(?) AS ranked", ranked).
+		Select("notification_id, actor_id").
+		Where("actor_rank <= ?", notificationActorsShown).
+		Order("notification_id, actor_rank").
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	actorIDs := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		actorIDs[i] = row.ActorID
+	}
+	var profiles []Profile
+	if err := r.db.WithContext(ctx).Where("id IN ?", actorIDs).Find(&profiles).Error; err != nil {
+		return err
+	}
+	profileByID := make(map[uuid.UUID]Profile, len(profiles))
+	for _, p := range profiles {
+		profileByID[p.ID] = p
+	}
+
+	for _, row := range rows {
+		if p, ok := profileByID[row.ActorID]; ok {
+			n := byID[row.NotificationID]
+			n.Actors = append(n.Actors, p)
+		}
+	}
+	return nil
+}
+
+// summarize renders the one-line text shown for n, e.g. "alice and 3 others
+// liked your post".
+func summarize(n *Notification) string {
+	who := "Someone"
+	if len(n.Actors) > 0 {
+		who = n.Actors[0].Username
+		switch {
+		case n.ActorCount == 2 && len(n.Actors) > 1:
+			who += " and " + n.Actors[1].Username
+		case n.ActorCount == 2:
+			who += " and 1 other"
+		case n.ActorCount > 2:
+			who += fmt.Sprintf(" and %d others", n.ActorCount-1)
+		}
+	}
+
+	var what string
+	switch n.Type {
+	case NotificationFollow:
+		what = "started following you"
+	case NotificationFollowRequest:
+		what = "requested to follow you"
+	case NotificationLike:
+		what = "liked your post"
+		if n.CommentID != nil {
+			what = "liked your comment"
+		}
+	case NotificationComment:
+		what = "commented on your post"
+	case NotificationReply:
+		what = "replied to your comment"
+	case NotificationMention:
+		what = "mentioned you in a post"
+		if n.CommentID != nil {
+			what = "mentioned you in a comment"
+		}
+	default:
+		what = "interacted with you"
+	}
+	return who + " " + what
+}
+
+func (r *notificationRepo) UnreadCount(ctx context.Context, recipientID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Notification{}).
+		Where("recipient_id = ? AND read_at IS NULL", recipientID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *notificationRepo) MarkRead(ctx context.Context, recipientID, id uuid.UUID) (bool, error) {
+	var n Notification
+	err := r.db.WithContext(ctx).First(&n, "id = ? AND recipient_id = ?", id, recipientID).Error
+	if err != nil {
+		return false, notFound(err)
+	}
+	if n.ReadAt != nil {
+		return false, nil
+	}
+	res := r.db.WithContext(ctx).Model(&Notification{}).
+		Where("id = ? AND read_at IS NULL", id).
+		UpdateColumn("read_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *notificationRepo) MarkAllRead(ctx context.Context, recipientID uuid.UUID) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&Notification{}).
+		Where("recipient_id = ? AND read_at IS NULL", recipientID).
+		UpdateColumn("read_at", time.Now())
+	return res.RowsAffected, res.Error
+}
+
+// NotificationCursor returns the pagination cursor positioned at n.
+func NotificationCursor(n Notification) Cursor {
+	return Cursor{CreatedAt: n.UpdatedAt, ID: n.ID}
+}
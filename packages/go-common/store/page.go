@@ -0,0 +1,116 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultPageLimit is used when a request does not ask for a page size.
+	DefaultPageLimit = 20
+	// MaxPageLimit caps the page size a client can request.
+	MaxPageLimit = 100
+)
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a list ordered by (timestamp, id), usually
+// (created_at, id). Lists ranked by relevance also record the rank.
+type Cursor struct {
+	Rank      float64   `json:"r,omitempty"`
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// Encode returns the opaque string form of c handed to clients.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor produced by Cursor.Encode.
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Page selects one page of a keyset-paginated list.
+type Page struct {
+	Limit int
+	After *Cursor
+}
+
+// ParsePage reads the "limit" and "cursor" query parameters. A missing or
+// out-of-range limit is clamped rather than rejected.
+func ParsePage(limit, cursor string) (Page, error) {
+	page := Page{Limit: DefaultPageLimit}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err == nil && n > 0 {
+			page.Limit = n
+		}
+		if page.Limit > MaxPageLimit {
+			page.Limit = MaxPageLimit
+		}
+	}
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return page, err
+		}
+		page.After = &c
+	}
+	return page, nil
+}
+
+// PageResult is the response envelope for paginated lists.
+type PageResult[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// NewPageResult builds the envelope for items fetched with page.Limit+1 rows:
+// the extra row only signals that another page exists and is dropped.
+func NewPageResult[T any](items []T, page Page, cursorOf func(T) Cursor) PageResult[T] {
+	result := PageResult[T]{Items: items}
+	if result.Items == nil {
+		result.Items = []T{}
+	}
+	if len(items) > page.Limit {
+		result.Items = items[:page.Limit]
+		next := cursorOf(result.Items[page.Limit-1]).Encode()
+		result.NextCursor = &next
+	}
+	return result
+}
+
+// afterCursor restricts q to rows that sort after page.After in
+// (timeCol DESC, idCol DESC) order.
+func afterCursor(q *gorm.DB, timeCol, idCol string, page Page) *gorm.DB {
+	if page.After == nil {
+		return q
+	}
+	return q.Where("("+timeCol+", "+idCol+") < (?, ?)", page.After.CreatedAt, page.After.ID)
+}
+
+// afterCursorAsc is afterCursor for lists in (timeCol ASC, idCol ASC) order.
+func afterCursorAsc(q *gorm.DB, timeCol, idCol string, page Page) *gorm.DB {
+	if page.After == nil {
+		return q
+	}
+	return q.Where("("+timeCol+", "+idCol+") > (?, ?)", page.After.CreatedAt, page.After.ID)
+}
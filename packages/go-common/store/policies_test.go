@@ -0,0 +1,91 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+// insertFollow inserts a follow the way a client would through PostgREST,
+// as actorID, and returns the error.
+func insertFollow(t *testing.T, db *gorm.DB, actorID, followerID, followingID uuid.UUID) error {
+	t.Helper()
+	return apitest.AsUser(t, db, actorID, func(tx *gorm.DB) error {
+		return tx.Exec("INSERT INTO public.follows (follower_id, following_id) VALUES (?, ?)", followerID, followingID).Error
+	})
+}
+
+// Clients may follow public accounts directly. Following a private account
+// takes an approved follow request.
+func TestFollowsInsertPolicy(t *testing.T) {
+	db := apitest.DB(t)
+	fan := apitest.CreateUser(t, db, "fan")
+	other := apitest.CreateUser(t, db, "other")
+	public := apitest.CreateUser(t, db, "public")
+	private := apitest.CreateUser(t, db, "private")
+	if err := db.Model(&store.Profile{}).Where("id = ?", private.ID).Update("is_private", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		follower uuid.UUID
+		target   uuid.UUID
+		allowed  bool
+	}{
+		{"public account", fan.ID, public.ID, true},
+		{"private account", fan.ID, private.ID, false},
+		{"on someone else's behalf", other.ID, public.ID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := insertFollow(t, db, fan.ID, tt.follower, tt.target)
+			if allowed := err == nil; allowed != tt.allowed {
+				t.Errorf("allowed = %v (%v), want %v", allowed, err, tt.allowed)
+			}
+		})
+	}
+}
+
+// A block stops direct follows in both directions.
+func TestFollowsInsertPolicyBlocks(t *testing.T) {
+	db := apitest.DB(t)
+	blocker := apitest.CreateUser(t, db, "blocker")
+	blocked := apitest.CreateUser(t, db, "blocked")
+	block := &store.Block{BlockerID: blocker.ID, BlockedID: blocked.ID}
+	if _, err := store.NewBlockRepo(db).Create(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := insertFollow(t, db, blocked.ID, blocked.ID, blocker.ID); err == nil {
+		t.Error("blocked user followed the blocker")
+	}
+	if err := insertFollow(t, db, blocker.ID, blocker.ID, blocked.ID); err == nil {
+		t.Error("blocker followed the blocked user")
+	}
+}
+
+// Users may edit their own profile but not the counters the triggers keep.
+func TestProfileCountersAreReadOnly(t *testing.T) {
+	db := apitest.DB(t)
+	user := apitest.CreateUser(t, db, "counted")
+
+	update := func(column string) error {
+		return apitest.AsUser(t, db, user.ID, func(tx *gorm.DB) error {
+			return tx.Exec("UPDATE public.profiles SET "+column+" = "+column+" WHERE id = ?", user.ID).Error
+		})
+	}
+	if err := update("full_name"); err != nil {
+		t.Errorf("updating full_name: %v", err)
+	}
+	for _, column := range []string{"followers_count", "following_count", "posts_count"} {
+		if err := update(column); err == nil {
+			t.Errorf("updating %s succeeded", column)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostRepo reads and writes rows in public.posts. Posts are always returned
+// with their author preloaded.
+type PostRepo interface {
+	ByID(ctx context.Context, id uuid.UUID) (*Post, error)
+	ByUser(ctx context.Context, userID uuid.UUID) ([]Post, error)
+	Timeline(ctx context.Context, userID uuid.UUID, page Page) ([]Post, error)
+	// Search returns one page of the posts viewerID may see that match a
+	// full-text query, best match first. See search.go.
+	Search(ctx context.Context, viewerID uuid.UUID, query string, page Page) ([]PostSearchResult, error)
+	// Create inserts post and attaches the uploads attachmentIDs to it in
+	// that order, all or nothing. It returns ErrAttachmentUnavailable if any
+	// of them is not an unattached upload of the post's author.
+	Create(ctx context.Context, post *Post, attachmentIDs ...uuid.UUID) error
+	// UpdateContent replaces a post's content and the link found in it.
+	UpdateContent(ctx context.Context, id uuid.UUID, content string, linkURL *string) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type postRepo struct {
+	db *gorm.DB
+}
+
+// NewPostRepo returns a PostRepo backed by db.
+func NewPostRepo(db *gorm.DB) PostRepo {
+	return &postRepo{db: db}
+}
+
+func (r *postRepo) ByID(ctx context.Context, id uuid.UUID) (*Post, error) {
+	var post Post
+	if err := r.db.WithContext(ctx).Preload("User").First(&post, "id = ?", id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &post, nil
+}
+
+// ByUser returns a user's posts, newest first.
+func (r *postRepo) ByUser(ctx context.Context, userID uuid.UUID) ([]Post, error) {
+	var posts []Post
+	err := r.db.WithContext(ctx).Preload("User").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&posts).Error
+	return posts, err
+}
+
+// Timeline returns one page of the posts of the accounts userID follows
+// together with userID's own posts, newest first. It fetches page.Limit+1 rows
+// so callers can tell whether another page exists (see NewPageResult).
+//
+// The author filter is an IN over the caller's follow list rather than a join,
+// so each author's posts are read from posts_user_id_created_at_idx in order.
+// Authors the caller has muted, or who share a block with the caller, are left
+// out.
+func (r *postRepo) Timeline(ctx context.Context, userID uuid.UUID, page Page) ([]Post, error) {
+	q := r.db.WithContext(ctx).
+		Preload("User").
+		Where("(posts.user_id = ? OR posts.user_id IN (?))", userID,
+			r.db.Model(&Follow{}).Select("following_id").Where("follower_id = ?", userID)).
+		Where("posts.user_id NOT IN (?)",
+			r.db.Model(&Mute{}).Select("muted_id").Where("muter_id = ?", userID)).
+		Where(notBlocked("posts.user_id"), userID, userID)
+	q = afterCursor(q, "posts.created_at", "posts.id", page)
+
+	var posts []Post
+	err := q.Order("posts.created_at DESC, posts.id DESC").
+		Limit(page.Limit + 1).
+		Find(&posts).Error
+	return posts, err
+}
+
+func (r *postRepo) Create(ctx context.Context, post *Post, attachmentIDs ...uuid.UUID) error {
+	if len(attachmentIDs) == 0 {
+		return r.db.WithContext(ctx).Omit(clause.Associations).Create(post).Error
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(post).Error; err != nil {
+			return err
+		}
+		return attach(tx, post.UserID, post.ID, attachmentIDs)
+	})
+}
+
+func (r *postRepo) UpdateContent(ctx context.Context, id uuid.UUID, content string, linkURL *string) error {
+	return r.db.WithContext(ctx).Model(&Post{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"content": content, "link_url": linkURL}).Error
+}
+
+func (r *postRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&Post{}).Error
+}
+
+// PostCursor returns the pagination cursor positioned at post.
+func PostCursor(post Post) Cursor {
+	return Cursor{CreatedAt: post.CreatedAt, ID: post.ID}
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"go-common/username"
+)
+
+// ErrUsernameTaken is returned by Rename when another profile has the
+// username, in any case.
+var ErrUsernameTaken = errors.New("username taken")
+
+// RenameCooldownError is returned by Rename when the profile was renamed too
+// recently to be renamed again.
+type RenameCooldownError struct {
+	Until time.Time // when the next rename is allowed
+}
+
+func (e *RenameCooldownError) Error() string {
+	return fmt.Sprintf("username changed too recently, next change allowed at %s", e.Until.Format(time.RFC3339))
+}
+
+// ProfileRepo reads and updates rows in public.profiles.
+//
+// Usernames are matched regardless of case.
+type ProfileRepo interface {
+	ByID(ctx context.Context, id uuid.UUID) (*Profile, error)
+	ByUsername(ctx context.Context, username string) (*Profile, error)
+	// ByPreviousUsername returns the profile that most recently gave up
+	// username by renaming.
+	ByPreviousUsername(ctx context.Context, username string) (*Profile, error)
+	// ByUsernames returns the profiles among usernames that exist, in no
+	// particular order.
+	ByUsernames(ctx context.Context, usernames []string) ([]Profile, error)
+	UsernameTaken(ctx context.Context, username string) (bool, error)
+	// AvailableUsernames returns the names among usernames that no profile
+	// has, in their original order, using a single query.
+	AvailableUsernames(ctx context.Context, usernames []string) ([]string, error)
+	// Rename sets a profile's username and records the change in
+	// username_history. It returns ErrUsernameTaken if another profile has
+	// the name, and a *RenameCooldownError if the previous rename was less
+	// than cooldown ago. Setting a first username, or changing only the case
+	// of the current one, is neither recorded nor limited.
+	Rename(ctx context.Context, id uuid.UUID, username string, cooldown time.Duration) error
+	// Search returns one page of the profiles matching query, best match
+	// first. See search.go.
+	Search(ctx context.Context, viewerID uuid.UUID, query string, page Page) ([]UserSearchResult, error)
+	Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) error
+	// ReconcileCounters recounts every profile's follower, following and
+	// post counters and returns how many were wrong.
+	ReconcileCounters(ctx context.Context) (int64, error)
+}
+
+type profileRepo struct {
+	db *gorm.DB
+}
+
+// NewProfileRepo returns a ProfileRepo backed by db.
+func NewProfileRepo(db *gorm.DB) ProfileRepo {
+	return &profileRepo{db: db}
+}
+
+func (r *profileRepo) ByID(ctx context.Context, id uuid.UUID) (*Profile, error) {
+	var profile Profile
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&profile).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &profile, nil
+}
+
+func (r *profileRepo) ByUsername(ctx context.Context, username string) (*Profile, error) {
+	var profile Profile
+	if err := r.db.WithContext(ctx).Where("lower(username) = lower(?)", username).First(&profile).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &profile, nil
+}
+
+func (r *profileRepo) ByPreviousUsername(ctx context.Context, username string) (*Profile, error) {
+	latest := r.db.Model(&UsernameChange{}).Select("user_id").
+		Where("lower(old_username) = lower(?)", username).
+		Order("changed_at DESC").Limit(1)
+	var profile Profile
+	if err := r.db.WithContext(ctx).Where("id = (?)", latest).First(&profile).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &profile, nil
+}
+
+func (r *profileRepo) ByUsernames(ctx context.Context, usernames []string) ([]Profile, error) {
+	var profiles []Profile
+	if len(usernames) == 0 {
+		return profiles, nil
+	}
+	folded := make([]string, len(usernames))
+	for i, name := range usernames {
+		folded[i] = username.Fold(name)
+	}
+	err := r.db.WithContext(ctx).Where("lower(username) IN ?", folded).Find(&profiles).Error
+	return profiles, err
+}
+
+func (r *profileRepo) UsernameTaken(ctx context.Context, username string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&Profile{}).Where("lower(username) = lower(?)", username).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *profileRepo) AvailableUsernames(ctx context.Context, usernames []string) ([]string, error) {
+	if len(usernames) == 0 {
+		return []string{}, nil
+	}
+	folded := make([]string, len(usernames))
+	for i, name := range usernames {
+		folded[i] = username.Fold(name)
+	}
+	var taken []string
+	err := r.db.WithContext(ctx).Model(&Profile{}).
+		Where("lower(username) IN ?", folded).Pluck("lower(username)", &taken).Error
+	if err != nil {
+		return nil, err
+	}
+
+	isTaken := make(map[string]bool, len(taken))
+	for _, name := range taken {
+		isTaken[name] = true
+	}
+	available := make([]string, 0, len(usernames))
+	for i, name := range usernames {
+		if !isTaken[folded[i]] {
+			available = append(available, name)
+		}
+	}
+	return available, nil
+}
+
+func (r *profileRepo) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&Profile{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *profileRepo) Rename(ctx context.Context, id uuid.UUID, newName string, cooldown time.Duration) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the row serializes concurrent renames of the same profile,
+		// so the cooldown cannot be raced.
+		var profile Profile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&profile).Error; err != nil {
+			return notFound(err)
+		}
+		if profile.Username == newName {
+			return nil
+		}
+
+		caseOnly := username.Fold(profile.Username) == username.Fold(newName)
+		if !caseOnly {
+			var taken int64
+			err := tx.Model(&Profile{}).Where("lower(username) = lower(?) AND id <> ?", newName, id).Count(&taken).Error
+			if err != nil {
+				return err
+			}
+			if taken > 0 {
+				return ErrUsernameTaken
+			}
+		}
+
+		if profile.Username != "" && !caseOnly {
+			var last []UsernameChange
+			err := tx.Where("user_id = ?", id).Order("changed_at DESC").Limit(1).Find(&last).Error
+			if err != nil {
+				return err
+			}
+			if len(last) > 0 && time.Since(last[0].ChangedAt) < cooldown {
+				return &RenameCooldownError{Until: last[0].ChangedAt.Add(cooldown)}
+			}
+			change := UsernameChange{UserID: id, OldUsername: profile.Username, NewUsername: newName}
+			if err := tx.Create(&change).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&Profile{}).Where("id = ?", id).Update("username", newName).Error
+	})
+	// A concurrent rename to the same name gets past the check above and
+	// trips profiles_username_lower_key instead.
+	if isUniqueViolation(err) {
+		return ErrUsernameTaken
+	}
+	return err
+}
+
+func (r *profileRepo) ReconcileCounters(ctx context.Context) (int64, error) {
+	var fixed int64
+	err := r.db.WithContext(ctx).Raw("SELECT public.reconcile_profile_counters()").Scan(&fixed).Error
+	return fixed, err
+}
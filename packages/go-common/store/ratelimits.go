@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RateLimitRepo counts requests in fixed windows in public.rate_limits, so
+// that limits hold across every instance of a function.
+type RateLimitRepo interface {
+	// Hit counts one request against key and reports how many requests key
+	// has made in the current window, including this one, and when that
+	// window ends. A window starts with the first request after the
+	// previous one ended.
+	Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+	// PurgeExpired deletes the rows of keys starting with keyPrefix whose
+	// window has ended and returns how many were removed. Hit would reset
+	// them anyway; purging keeps clients that never come back from leaving
+	// a row behind forever.
+	PurgeExpired(ctx context.Context, keyPrefix string, window time.Duration) (int64, error)
+}
+
+type rateLimitRepo struct {
+	db *gorm.DB
+}
+
+// NewRateLimitRepo returns a RateLimitRepo backed by db.
+func NewRateLimitRepo(db *gorm.DB) RateLimitRepo {
+	return &rateLimitRepo{db: db}
+}
+
+func (r *rateLimitRepo) Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	var row struct {
+		Count       int
+		WindowStart time.Time
+	}
+	// One row per key, reset in place when its window has passed.
+	err := r.db.WithContext(ctx).Raw(`
+		INSERT INTO public.rate_limits AS rl (key, window_start, count)
+		VALUES (@key, now(), 1)
+		ON CONFLICT (key) DO UPDATE SET
+		  count = CASE WHEN rl.window_start <= now() - @window * interval '1 second' THEN 1 ELSE rl.count + 1 END,
+		  window_start = CASE WHEN rl.window_start <= now() - @window * interval '1 second' THEN now() ELSE rl.window_start END
+		RETURNING count, window_start`,
+		map[string]interface{}{"key": key, "window": window.Seconds()},
+	).Scan(&row).Error
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return row.Count, row.WindowStart.Add(window), nil
+}
+
+func (r *rateLimitRepo) PurgeExpired(ctx context.Context, keyPrefix string, window time.Duration) (int64, error) {
+	res := r.db.WithContext(ctx).Exec(`
+		DELETE FROM public.rate_limits
+		WHERE window_start <= now() - ? * interval '1 second' AND starts_with(key, ?)`,
+		window.Seconds(), keyPrefix)
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,44 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestRateLimitPurgeExpired(t *testing.T) {
+	db := apitest.DB(t)
+	ctx := context.Background()
+	limits := store.NewRateLimitRepo(db)
+
+	keys := []string{"purge-test:stale", "purge-test:fresh", "purge-other:stale"}
+	for _, key := range keys {
+		if _, _, err := limits.Hit(ctx, key, time.Minute); err != nil {
+			t.Fatalf("Hit(%s): %v", key, err)
+		}
+	}
+	err := db.Exec("UPDATE public.rate_limits SET window_start = now() - interval '2 minutes' WHERE key IN ?",
+		[]string{"purge-test:stale", "purge-other:stale"}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := limits.PurgeExpired(ctx, "purge-test:", time.Minute)
+	if err != nil {
+		t.Fatalf("PurgeExpired: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("PurgeExpired removed %d rows, want 1", n)
+	}
+
+	var left []string
+	if err := db.Table("public.rate_limits").Where("key IN ?", keys).Order("key").Pluck("key", &left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || left[0] != "purge-other:stale" || left[1] != "purge-test:fresh" {
+		t.Errorf("rows left = %v, want the fresh key and the other limit's", left)
+	}
+}
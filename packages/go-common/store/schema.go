@@ -0,0 +1,43 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+// SchemaVersion is the newest migration in supabase/migrations that this code
+// depends on. Bump it whenever a migration is added.
+const SchemaVersion = "20261017235930"
+
+// ErrSchemaVersion is returned when the database is missing the migration the
+// code expects.
+var ErrSchemaVersion = errors.New("database schema is out of date")
+
+// schemaCheckEnabled reports whether Connect should verify the schema version.
+// It is off unless CHECK_SCHEMA_VERSION is set, so the check costs nothing by
+// default.
+func schemaCheckEnabled() bool {
+	switch os.Getenv("CHECK_SCHEMA_VERSION") {
+	case "1", "true", "TRUE", "True":
+		return true
+	}
+	return false
+}
+
+// CheckSchemaVersion returns ErrSchemaVersion unless SchemaVersion has been
+// applied by cmd/migrate. A database that is ahead of the code passes.
+func CheckSchemaVersion(db *gorm.DB) error {
+	var applied bool
+	err := db.Raw("SELECT EXISTS (SELECT 1 FROM public.schema_migrations WHERE version = ?)", SchemaVersion).
+		Scan(&applied).Error
+	if err != nil {
+		return fmt.Errorf("%w: reading public.schema_migrations: %v", ErrSchemaVersion, err)
+	}
+	if !applied {
+		return fmt.Errorf("%w: migration %s has not been applied", ErrSchemaVersion, SchemaVersion)
+	}
+	return nil
+}
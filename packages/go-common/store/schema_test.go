@@ -0,0 +1,22 @@
+package store
+
+import (
+	"testing"
+
+	"go-common/migrate"
+)
+
+// SchemaVersion has to follow the migrations, or CHECK_SCHEMA_VERSION would
+// pass against a database missing the newest one.
+func TestSchemaVersionIsLatestMigration(t *testing.T) {
+	migrations, err := migrate.Load("../../../supabase/migrations", "")
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations found")
+	}
+	if latest := migrations[len(migrations)-1].Version; latest != SchemaVersion {
+		t.Errorf("SchemaVersion = %s, want the latest migration %s", SchemaVersion, latest)
+	}
+}
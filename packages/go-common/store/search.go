@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// TextSearchConfig is the Postgres text search configuration used for
+// posts.content_tsv. Queries must use the same one to hit the GIN index.
+const TextSearchConfig = "english"
+
+const (
+	postQuery = "websearch_to_tsquery('" + TextSearchConfig + "', ?)"
+	postRank  = "ts_rank(posts.content_tsv, " + postQuery + ")::float8"
+	// The markers are escaped with the rest of the snippet and then restored,
+	// so snippets are safe to render as HTML.
+	postHeadline = "ts_headline('" + TextSearchConfig + "', posts.content, " + postQuery +
+		", 'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2')"
+	// Private accounts' posts only match for the author and approved
+	// followers. Takes the viewer twice.
+	postVisible = "(posts.user_id = ? OR posts.user_id IN (SELECT id FROM profiles WHERE NOT is_private)" +
+		" OR posts.user_id IN (SELECT following_id FROM follows WHERE follower_id = ?))"
+)
+
+// PostSearchResult is a post matched by a full-text search. Snippet is an
+// HTML-escaped excerpt with the matching terms wrapped in <mark> tags.
+type PostSearchResult struct {
+	Post
+	Rank    float64 `gorm:"->;-:migration" json:"rank"`
+	Snippet string  `gorm:"->;-:migration" json:"snippet"`
+}
+
+func (r *postRepo) Search(ctx context.Context, viewerID uuid.UUID, query string, page Page) ([]PostSearchResult, error) {
+	q := r.db.WithContext(ctx).Model(&Post{}).
+		Preload("User").
+		Select("posts.*, "+postRank+" AS rank, "+postHeadline+" AS snippet", query, query).
+		Where("posts.content_tsv @@ "+postQuery, query).
+		Where(postVisible, viewerID, viewerID).
+		Where(notBlocked("posts.user_id"), viewerID, viewerID)
+	if page.After != nil {
+		q = q.Where("("+postRank+", posts.created_at, posts.id) < (?, ?, ?)",
+			query, page.After.Rank, page.After.CreatedAt, page.After.ID)
+	}
+
+	var results []PostSearchResult
+	err := q.Order("rank DESC, posts.created_at DESC, posts.id DESC").
+		Limit(page.Limit + 1).
+		Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Snippet = escapeHeadline(results[i].Snippet)
+	}
+	return results, nil
+}
+
+var headlineMarks = strings.NewReplacer("&lt;mark&gt;", "<mark>", "&lt;/mark&gt;", "</mark>")
+
+func escapeHeadline(s string) string {
+	return headlineMarks.Replace(html.EscapeString(s))
+}
+
+// PostSearchCursor returns the pagination cursor positioned at result.
+func PostSearchCursor(result PostSearchResult) Cursor {
+	return Cursor{Rank: result.Rank, CreatedAt: result.CreatedAt, ID: result.ID}
+}
+
+// Users are ranked by match tier (exact username or full name, then prefix,
+// then substring or trigram similarity), then by whether the viewer follows
+// them, then by similarity. Each component dominates the ones after it, so the
+// sum orders results the same way and doubles as the cursor rank.
+const (
+	userFollowed = "EXISTS (SELECT 1 FROM follows WHERE follows.follower_id = @viewer AND follows.following_id = profiles.id)"
+	userRank     = "((CASE" +
+		" WHEN lower(profiles.username) = lower(@query) OR lower(profiles.full_name) = lower(@query) THEN 3" +
+		" WHEN profiles.username ILIKE @prefix OR profiles.full_name ILIKE @prefix THEN 2" +
+		" ELSE 1 END) * 4" +
+		" + (CASE WHEN " + userFollowed + " THEN 2 ELSE 0 END)" +
+		" + GREATEST(similarity(profiles.username, @query), similarity(coalesce(profiles.full_name, ''), @query)))::float8"
+	// ILIKE and % are both served by the pg_trgm GIN indexes.
+	userMatch = "profiles.username ILIKE @contains OR profiles.full_name ILIKE @contains" +
+		" OR profiles.username % @query OR profiles.full_name % @query"
+)
+
+// UserSearchResult is a profile matched by a user search. Users who share a
+// block with the viewer are never matched.
+type UserSearchResult struct {
+	Profile
+	IsFollowing bool    `gorm:"->;-:migration" json:"is_following"`
+	Rank        float64 `gorm:"->;-:migration" json:"-"`
+}
+
+func (r *profileRepo) Search(ctx context.Context, viewerID uuid.UUID, query string, page Page) ([]UserSearchResult, error) {
+	literal := escapeLike(query)
+	args := map[string]interface{}{
+		"viewer":   viewerID,
+		"query":    query,
+		"prefix":   literal + "%",
+		"contains": "%" + literal + "%",
+	}
+
+	q := r.db.WithContext(ctx).Model(&Profile{}).
+		Select("profiles.*, "+userFollowed+" AS is_following, "+userRank+" AS rank", args).
+		Where(userMatch, args).
+		Where(notBlocked("profiles.id"), viewerID, viewerID)
+	if page.After != nil {
+		args["after_rank"], args["after_id"] = page.After.Rank, page.After.ID
+		q = q.Where("("+userRank+", profiles.id) < (@after_rank, @after_id)", args)
+	}
+
+	var results []UserSearchResult
+	err := q.Order("rank DESC, profiles.id DESC").
+		Limit(page.Limit + 1).
+		Find(&results).Error
+	return results, err
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// UserSearchCursor returns the pagination cursor positioned at result.
+func UserSearchCursor(result UserSearchResult) Cursor {
+	return Cursor{Rank: result.Rank, ID: result.ID}
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StoryGroup is one author's active stories in a story feed, oldest first so
+// that they play in the order they were posted.
+type StoryGroup struct {
+	User      Profile `json:"user"`
+	Stories   []Story `json:"stories"`
+	HasUnseen bool    `json:"has_unseen"`
+}
+
+// StoryRepo reads and writes rows in public.stories and public.story_views.
+// Reads never return a story past its expires_at, so expiry does not depend on
+// PurgeExpired having run.
+type StoryRepo interface {
+	// ByID returns an active story.
+	ByID(ctx context.Context, id uuid.UUID) (*Story, error)
+	Create(ctx context.Context, story *Story) error
+	// Feed returns the active stories of viewerID and the accounts they follow,
+	// grouped by author. Authors with unseen stories come first, then the most
+	// recently updated.
+	Feed(ctx context.Context, viewerID uuid.UUID) ([]StoryGroup, error)
+	// RecordView marks storyID as seen by viewerID and reports whether this is
+	// the viewer's first view.
+	RecordView(ctx context.Context, storyID, viewerID uuid.UUID) (bool, error)
+	// Viewers returns one page of a story's viewers, most recent first.
+	Viewers(ctx context.Context, storyID uuid.UUID, page Page) ([]StoryView, error)
+	// PurgeExpired deletes stories that expired at or before cutoff, along with
+	// their views, and returns how many stories were removed.
+	PurgeExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type storyRepo struct {
+	db *gorm.DB
+}
+
+// NewStoryRepo returns a StoryRepo backed by db.
+func NewStoryRepo(db *gorm.DB) StoryRepo {
+	return &storyRepo{db: db}
+}
+
+func (r *storyRepo) ByID(ctx context.Context, id uuid.UUID) (*Story, error) {
+	var story Story
+	err := r.db.WithContext(ctx).Preload("User").
+		First(&story, "id = ? AND expires_at > ?", id, time.Now()).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &story, nil
+}
+
+func (r *storyRepo) Create(ctx context.Context, story *Story) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Create(story).Error
+}
+
+func (r *storyRepo) Feed(ctx context.Context, viewerID uuid.UUID) ([]StoryGroup, error) {
+	following := r.db.Model(&Follow{}).Select("following_id").Where("follower_id = ?", viewerID)
+
+	var stories []Story
+	err := r.db.WithContext(ctx).Preload("User").
+		Where("stories.user_id = ? OR stories.user_id IN (?)", viewerID, following).
+		Where("stories.expires_at > ?", time.Now()).
+		Order("stories.user_id, stories.created_at ASC, stories.id ASC").
+		Find(&stories).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(stories) == 0 {
+		return []StoryGroup{}, nil
+	}
+
+	ids := make([]uuid.UUID, len(stories))
+	for i := range stories {
+		ids[i] = stories[i].ID
+	}
+	var viewed []uuid.UUID
+	err = r.db.WithContext(ctx).Model(&StoryView{}).
+		Where("viewer_id = ? AND story_id IN ?", viewerID, ids).
+		Pluck("story_id", &viewed).Error
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[uuid.UUID]bool, len(viewed))
+	for _, id := range viewed {
+		seen[id] = true
+	}
+
+	var groups []StoryGroup
+	for _, story := range stories {
+		// The viewer's own stories count as seen.
+		story.ViewedByMe = seen[story.ID] || story.UserID == viewerID
+		if n := len(groups); n == 0 || groups[n-1].User.ID != story.UserID {
+			groups = append(groups, StoryGroup{User: story.User})
+		}
+		g := &groups[len(groups)-1]
+		g.Stories = append(g.Stories, story)
+		g.HasUnseen = g.HasUnseen || !story.ViewedByMe
+	}
+
+	sortStoryGroups(groups)
+	return groups, nil
+}
+
+// sortStoryGroups orders groups with unseen stories first, then by their
+// newest story.
+func sortStoryGroups(groups []StoryGroup) {
+	newest := func(g StoryGroup) time.Time { return g.Stories[len(g.Stories)-1].CreatedAt }
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if a.HasUnseen != b.HasUnseen {
+			return a.HasUnseen
+		}
+		return newest(a).After(newest(b))
+	})
+}
+
+func (r *storyRepo) RecordView(ctx context.Context, storyID, viewerID uuid.UUID) (bool, error) {
+	view := &StoryView{StoryID: storyID, ViewerID: viewerID}
+	res := r.db.WithContext(ctx).Omit(clause.Associations).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(view)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *storyRepo) Viewers(ctx context.Context, storyID uuid.UUID, page Page) ([]StoryView, error) {
+	q := r.db.WithContext(ctx).Preload("Viewer").Where("story_views.story_id = ?", storyID)
+	q = afterCursor(q, "story_views.viewed_at", "story_views.viewer_id", page)
+
+	var views []StoryView
+	err := q.Order("story_views.viewed_at DESC, story_views.viewer_id DESC").
+		Limit(page.Limit + 1).
+		Find(&views).Error
+	return views, err
+}
+
+func (r *storyRepo) PurgeExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	// story_views rows go with their story through ON DELETE CASCADE.
+	res := r.db.WithContext(ctx).Where("expires_at <= ?", cutoff).Delete(&Story{})
+	return res.RowsAffected, res.Error
+}
+
+// StoryViewCursor returns the pagination cursor positioned at view.
+func StoryViewCursor(view StoryView) Cursor {
+	return Cursor{CreatedAt: view.ViewedAt, ID: view.ViewerID}
+}
@@ -0,0 +1,67 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-common/apitest"
+	"go-common/store"
+)
+
+func TestMain(m *testing.M) { os.Exit(apitest.Main(m)) }
+
+func TestPurgeExpired(t *testing.T) {
+	db := apitest.DB(t)
+	user := apitest.CreateUser(t, db, "purge")
+
+	ctx := context.Background()
+	stories := store.NewStoryRepo(db)
+	now := time.Now()
+	expired := &store.Story{UserID: user.ID, MediaURL: "https://example.com/a.jpg", MediaType: "image",
+		CreatedAt: now.Add(-25 * time.Hour), ExpiresAt: now.Add(-time.Hour)}
+	active := &store.Story{UserID: user.ID, MediaURL: "https://example.com/b.jpg", MediaType: "image",
+		CreatedAt: now, ExpiresAt: now.Add(24 * time.Hour)}
+	for _, s := range []*store.Story{expired, active} {
+		if err := stories.Create(ctx, s); err != nil {
+			t.Fatalf("creating story: %v", err)
+		}
+	}
+	if _, err := stories.RecordView(ctx, expired.ID, user.ID); err != nil {
+		t.Fatalf("recording view: %v", err)
+	}
+
+	// Expired stories are hidden before any purge runs.
+	if _, err := stories.ByID(ctx, expired.ID); err != store.ErrNotFound {
+		t.Errorf("ByID(expired) error = %v, want ErrNotFound", err)
+	}
+	groups, err := stories.Feed(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Feed: %v", err)
+	}
+	if len(groups) != 1 || len(groups[0].Stories) != 1 || groups[0].Stories[0].ID != active.ID {
+		t.Errorf("Feed returned %+v, want only the active story", groups)
+	}
+
+	n, err := stories.PurgeExpired(ctx, now)
+	if err != nil {
+		t.Fatalf("PurgeExpired: %v", err)
+	}
+	if n < 1 {
+		t.Errorf("PurgeExpired removed %d stories, want at least 1", n)
+	}
+
+	var left int64
+	db.Model(&store.Story{}).Where("id IN ?", []uuid.UUID{expired.ID, active.ID}).Count(&left)
+	if left != 1 {
+		t.Errorf("%d of the test stories remain, want 1", left)
+	}
+	var views int64
+	db.Model(&store.StoryView{}).Where("story_id = ?", expired.ID).Count(&views)
+	if views != 0 {
+		t.Errorf("%d views of the purged story remain, want 0", views)
+	}
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultSuggestionLimit is used when a request does not ask for a size.
+	DefaultSuggestionLimit = 10
+	// MaxSuggestionLimit caps the number of suggestions a client can request.
+	MaxSuggestionLimit = 50
+)
+
+// Suggestion is an account the user might want to follow. MutualCount is how
+// many of the accounts the user follows already follow it, and FollowsMe
+// whether it follows the user. Reason explains the suggestion, e.g.
+// "Followed by bob and 2 others".
+type Suggestion struct {
+	Profile
+	MutualCount  int64      `gorm:"->;-:migration" json:"mutual_count"`
+	FollowsMe    bool       `gorm:"->;-:migration" json:"follows_me"`
+	LastPostedAt *time.Time `gorm:"->;-:migration" json:"last_posted_at"`
+	MutualName   *string    `gorm:"->;-:migration" json:"-"`
+	Reason       string     `gorm:"-" json:"reason"`
+}
+
+// SuggestionRepo ranks accounts for a user to follow.
+type SuggestionRepo interface {
+	// For returns up to limit suggestions for userID, best first.
+	For(ctx context.Context, userID uuid.UUID, limit int) ([]Suggestion, error)
+}
+
+type suggestionRepo struct {
+	db *gorm.DB
+}
+
+// NewSuggestionRepo returns a SuggestionRepo backed by db.
+func NewSuggestionRepo(db *gorm.DB) SuggestionRepo {
+	return &suggestionRepo{db: db}
+}
+
+// Candidates are friends of friends (accounts followed by accounts the user
+// follows) and the user's own followers. Each mutual counts one point and
+// following the user counts one more; recent posting breaks ties. Accounts
+// the user already follows or has asked to follow, the user, and anyone who
+// shares a block with the user are left out.
+const suggestionQuery = `
+WITH mine AS (
+  SELECT following_id AS id FROM follows WHERE follower_id = @user
+), mutuals AS (
+  SELECT f.following_id AS id,
+         COUNT(*) AS mutual_count,
+         (array_agg(via.username ORDER BY f.created_at DESC))[1] AS mutual_name
+  FROM follows f
+  JOIN mine ON mine.id = f.follower_id
+  JOIN profiles via ON via.id = f.follower_id
+  GROUP BY f.following_id
+), followers AS (
+  SELECT follower_id AS id FROM follows WHERE following_id = @user
+)
+SELECT profiles.*,
+       COALESCE(mutuals.mutual_count, 0) AS mutual_count,
+       followers.id IS NOT NULL AS follows_me,
+       (SELECT MAX(posts.created_at) FROM posts WHERE posts.user_id = profiles.id) AS last_posted_at,
+       mutuals.mutual_name
+FROM profiles
+LEFT JOIN mutuals ON mutuals.id = profiles.id
+LEFT JOIN followers ON followers.id = profiles.id
+WHERE (mutuals.id IS NOT NULL OR followers.id IS NOT NULL)
+  AND profiles.id <> @user
+  AND profiles.id NOT IN (SELECT id FROM mine)
+  AND profiles.id NOT IN (SELECT target_id FROM follow_requests WHERE requester_id = @user)
+  AND profiles.id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = @user
+                          UNION ALL SELECT blocker_id FROM blocks WHERE blocked_id = @user)
+ORDER BY COALESCE(mutuals.mutual_count, 0) + (CASE WHEN followers.id IS NOT NULL THEN 1 ELSE 0 END) DESC,
+         last_posted_at DESC NULLS LAST,
+         profiles.id
+LIMIT @limit`
+
+func (r *suggestionRepo) For(ctx context.Context, userID uuid.UUID, limit int) ([]Suggestion, error) {
+	var suggestions []Suggestion
+	err := r.db.WithContext(ctx).Raw(suggestionQuery,
+		map[string]interface{}{"user": userID, "limit": limit}).
+		Scan(&suggestions).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range suggestions {
+		suggestions[i].Reason = suggestionReason(&suggestions[i])
+	}
+	return suggestions, nil
+}
+
+// suggestionReason explains why s was suggested, naming the most recent
+// mutual when there is one.
+func suggestionReason(s *Suggestion) string {
+	if s.MutualCount == 0 || s.MutualName == nil {
+		if s.FollowsMe {
+			return "Follows you"
+		}
+		return "Suggested for you"
+	}
+	switch others := s.MutualCount - 1; others {
+	case 0:
+		return "Followed by " + *s.MutualName
+	case 1:
+		return "Followed by " + *s.MutualName + " and 1 other"
+	default:
+		return fmt.Sprintf("Followed by %s and %d others", *s.MutualName, others)
+	}
+}
@@ -0,0 +1,23 @@
+package store
+
+import "testing"
+
+func TestSuggestionReason(t *testing.T) {
+	bob := "bob"
+	tests := []struct {
+		name string
+		s    Suggestion
+		want string
+	}{
+		{"one mutual", Suggestion{MutualCount: 1, MutualName: &bob}, "Followed by bob"},
+		{"two mutuals", Suggestion{MutualCount: 2, MutualName: &bob}, "Followed by bob and 1 other"},
+		{"many mutuals", Suggestion{MutualCount: 3, MutualName: &bob, FollowsMe: true}, "Followed by bob and 2 others"},
+		{"follower only", Suggestion{FollowsMe: true}, "Follows you"},
+		{"neither", Suggestion{}, "Suggested for you"},
+	}
+	for _, tt := range tests {
+		if got := suggestionReason(&tt.s); got != tt.want {
+			t.Errorf("%s: reason = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
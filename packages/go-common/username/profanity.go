@@ -0,0 +1,27 @@
+package username
+
+import "strings"
+
+// profane are matched anywhere in a username once it is folded, stripped of
+// separators and read with common digit substitutions, so "F_u.c-k" and
+// "5h1t" are caught. Words that are also common inside innocent names
+// ("ass" in "class", "cock" in "peacock") are deliberately left out.
+var profane = []string{
+	"asshole", "bitch", "cunt", "fag", "fuck", "nigga", "nigger",
+	"porn", "shit", "slut", "twat", "wank", "whore",
+}
+
+var deleet = strings.NewReplacer(
+	"_", "", "-", "", ".", "",
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t",
+)
+
+func isProfane(name string) bool {
+	plain := deleet.Replace(Fold(name))
+	for _, word := range profane {
+		if strings.Contains(plain, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package username
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MaxCandidates is the most names Candidates returns.
+const MaxCandidates = 12
+
+// Candidates returns valid usernames to offer in place of name, which is
+// unavailable: name and names made from fullName, with and without short
+// suffixes, the numeric ones drawn from rnd. The result never contains name
+// itself and holds no two names that differ only in case. Whether the
+// candidates are taken is for the caller to check.
+func Candidates(name, fullName string, rnd *rand.Rand) []string {
+	var bases []string
+	if base := sanitize(name); base != "" {
+		bases = append(bases, base)
+	}
+	if words := nameWords(fullName); len(words) > 0 {
+		joined := strings.Join(words, "")
+		bases = append(bases, joined, strings.Join(words, "_"), strings.Join(words, "."))
+		if len(words) > 1 {
+			bases = append(bases, words[0][:1]+words[len(words)-1])
+		}
+	}
+
+	seen := map[string]bool{Fold(name): true}
+	var out []string
+	add := func(base, suffix string) {
+		if len(base)+len(suffix) > MaxLength {
+			base = strings.TrimRight(base[:MaxLength-len(suffix)], "_.-")
+		}
+		candidate := base + suffix
+		if len(out) >= MaxCandidates || seen[Fold(candidate)] || Validate(candidate) != nil {
+			return
+		}
+		seen[Fold(candidate)] = true
+		out = append(out, candidate)
+	}
+
+	for _, base := range bases {
+		add(base, "")
+	}
+	for _, base := range bases {
+		add(base, "_")
+		add(base, strconv.Itoa(10+rnd.Intn(90)))
+		add(base, "_"+strconv.Itoa(100+rnd.Intn(900)))
+	}
+	return out
+}
+
+// sanitize drops the characters of name that usernames cannot contain, and
+// any leading separators.
+func sanitize(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+			b.WriteByte(c)
+		case (c == '_' || c == '-' || c == '.') && b.Len() > 0:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// nameWords splits a full name into lowercase ASCII words, dropping accents
+// it cannot represent rather than transliterating them.
+func nameWords(fullName string) []string {
+	var words []string
+	for _, field := range strings.FieldsFunc(fullName, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if word := strings.ToLower(sanitize(field)); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
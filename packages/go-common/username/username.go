@@ -0,0 +1,107 @@
+// Package username holds the rules for usernames, shared by every endpoint
+// that sets or checks one. Usernames keep the case they were chosen with but
+// are unique regardless of case, so they are compared through Fold.
+package username
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	MinLength = 3
+	MaxLength = 20
+
+	// ChangeCooldown is how long a user has to wait between renames.
+	ChangeCooldown = 14 * 24 * time.Hour
+)
+
+// Validation errors returned by Validate. Their messages are fit to show to
+// users.
+var (
+	ErrTooShort     = errors.New("Username must be at least 3 characters")
+	ErrTooLong      = errors.New("Username must be at most 20 characters")
+	ErrInvalidChars = errors.New("Username can only contain letters, digits, '_', '-' and '.', and must start with a letter or digit")
+	ErrReserved     = errors.New("This username is reserved")
+	ErrProfanity    = errors.New("This username is not allowed")
+)
+
+// Reasons a username is unavailable, as reported by /api/check-username.
+const (
+	ReasonTaken        = "taken"
+	ReasonReserved     = "reserved"
+	ReasonTooShort     = "too_short"
+	ReasonTooLong      = "too_long"
+	ReasonInvalidChars = "invalid_chars"
+	ReasonProfanity    = "profanity"
+)
+
+// Reason returns the reason code for an error from Validate, or "" for any
+// other error.
+func Reason(err error) string {
+	switch err {
+	case ErrTooShort:
+		return ReasonTooShort
+	case ErrTooLong:
+		return ReasonTooLong
+	case ErrInvalidChars:
+		return ReasonInvalidChars
+	case ErrReserved:
+		return ReasonReserved
+	case ErrProfanity:
+		return ReasonProfanity
+	}
+	return ""
+}
+
+// reserved are names that collide with routes of the web app and the API, or
+// that would let an account pass for staff. Entries are folded.
+var reserved = map[string]bool{
+	"about": true, "account": true, "admin": true, "administrator": true,
+	"api": true, "app": true, "auth": true, "cirqle": true, "explore": true,
+	"help": true, "home": true, "login": true, "logout": true,
+	"me": true, "messages": true, "moderator": true, "my-profile": true,
+	"notifications": true, "official": true, "privacy": true, "profile": true,
+	"report": true, "root": true, "search": true, "settings": true,
+	"signin": true, "signup": true, "staff": true, "support": true,
+	"system": true, "terms": true,
+}
+
+// Fold returns the form usernames are compared in.
+func Fold(name string) string {
+	return strings.ToLower(name)
+}
+
+// IsReserved reports whether name is one of the reserved usernames.
+func IsReserved(name string) bool {
+	return reserved[Fold(name)]
+}
+
+// Validate checks name against the username rules: MinLength to MaxLength
+// ASCII letters, digits, '_', '-' and '.', starting with a letter or digit,
+// not reserved and not profane. It does not check whether the name is taken.
+func Validate(name string) error {
+	if len(name) < MinLength {
+		return ErrTooShort
+	}
+	if len(name) > MaxLength {
+		return ErrTooLong
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case (c == '_' || c == '-' || c == '.') && i > 0:
+		default:
+			return ErrInvalidChars
+		}
+	}
+	if IsReserved(name) {
+		return ErrReserved
+	}
+	if isProfane(name) {
+		return ErrProfanity
+	}
+	return nil
+}
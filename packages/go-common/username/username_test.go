@@ -0,0 +1,92 @@
+package username
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"alice", nil},
+		{"Alice_99", nil},
+		{"j.doe-2", nil},
+		{"abc", nil},
+		{strings.Repeat("a", MaxLength), nil},
+		{"ab", ErrTooShort},
+		{"", ErrTooShort},
+		{strings.Repeat("a", MaxLength+1), ErrTooLong},
+		{"_alice", ErrInvalidChars},
+		{".well-known", ErrInvalidChars},
+		{"ali ce", ErrInvalidChars},
+		{"alice!", ErrInvalidChars},
+		{"ålice", ErrInvalidChars},
+		{"settings", ErrReserved},
+		{"API", ErrReserved},
+		{"Search", ErrReserved},
+	}
+	for _, tt := range tests {
+		if got := Validate(tt.name); got != tt.want {
+			t.Errorf("Validate(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateProfanity(t *testing.T) {
+	for _, name := range []string{"fuckface", "F_u.c-k", "5h1t_happens", "xXwh0reXx"} {
+		if got := Validate(name); got != ErrProfanity {
+			t.Errorf("Validate(%q) = %v, want ErrProfanity", name, got)
+		}
+	}
+	for _, name := range []string{"classic", "peacock", "grape_juice", "shiitake"} {
+		if got := Validate(name); got != nil {
+			t.Errorf("Validate(%q) = %v, want nil", name, got)
+		}
+	}
+}
+
+func TestReason(t *testing.T) {
+	if got := Reason(Validate("no spaces")); got != ReasonInvalidChars {
+		t.Errorf("Reason = %q, want %q", got, ReasonInvalidChars)
+	}
+	if got := Reason(nil); got != "" {
+		t.Errorf("Reason(nil) = %q", got)
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	got := Candidates("Jane", "Jane Q. Doe-Smith", rnd)
+	if len(got) == 0 || len(got) > MaxCandidates {
+		t.Fatalf("%d candidates: %v", len(got), got)
+	}
+	seen := map[string]bool{"jane": true}
+	for _, c := range got {
+		if err := Validate(c); err != nil {
+			t.Errorf("candidate %q: %v", c, err)
+		}
+		if seen[Fold(c)] {
+			t.Errorf("candidate %q repeated or equal to the requested name", c)
+		}
+		seen[Fold(c)] = true
+	}
+	for _, want := range []string{"janeqdoesmith", "jane_q_doe_smith", "jsmith"} {
+		if !seen[want] {
+			t.Errorf("candidates %v are missing %q", got, want)
+		}
+	}
+
+	// Long names are cut to fit their suffix.
+	for _, c := range Candidates(strings.Repeat("a", MaxLength), "", rnd) {
+		if len(c) > MaxLength {
+			t.Errorf("candidate %q is longer than %d", c, MaxLength)
+		}
+	}
+	// Nothing usable comes from a name that is only punctuation.
+	if got := Candidates("!!!", "", rnd); len(got) != 0 {
+		t.Errorf("Candidates(\"!!!\") = %v", got)
+	}
+}
@@ -0,0 +1,4 @@
+-- Serve the timeline's keyset pagination on (created_at, id) per author from an index.
+-- The caller's follow list is read through the existing unique_follow (follower_id, following_id) index.
+CREATE INDEX IF NOT EXISTS posts_user_id_created_at_idx
+  ON public.posts (user_id, created_at DESC, id DESC);
@@ -0,0 +1,10 @@
+-- Threaded replies: a comment may answer another comment on the same post.
+ALTER TABLE public.comments
+  ADD COLUMN parent_id UUID REFERENCES public.comments(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS comments_post_id_created_at_idx
+  ON public.comments (post_id, created_at, id);
+
+CREATE INDEX IF NOT EXISTS comments_parent_id_idx
+  ON public.comments (parent_id)
+  WHERE parent_id IS NOT NULL;
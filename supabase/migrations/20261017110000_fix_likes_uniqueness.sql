@@ -0,0 +1,32 @@
+-- one_like_per_item_per_user is UNIQUE (user_id, post_id, comment_id), but one of
+-- post_id / comment_id is always NULL and NULLs never compare equal, so it does not
+-- stop duplicate likes. Enforce uniqueness per target kind with partial indexes.
+
+-- Drop duplicates that slipped through, keeping the oldest like.
+DELETE FROM public.likes a
+USING public.likes b
+WHERE a.user_id = b.user_id
+  AND a.post_id IS NOT DISTINCT FROM b.post_id
+  AND a.comment_id IS NOT DISTINCT FROM b.comment_id
+  AND (a.created_at, a.id) > (b.created_at, b.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS likes_user_post_key
+  ON public.likes (user_id, post_id)
+  WHERE post_id IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS likes_user_comment_key
+  ON public.likes (user_id, comment_id)
+  WHERE comment_id IS NOT NULL;
+
+-- A like points at exactly one post or one comment.
+ALTER TABLE public.likes
+  ADD CONSTRAINT likes_single_target CHECK (num_nonnulls(post_id, comment_id) = 1) NOT VALID;
+
+-- Per-target counts for like_count.
+CREATE INDEX IF NOT EXISTS likes_post_id_idx
+  ON public.likes (post_id)
+  WHERE post_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS likes_comment_id_idx
+  ON public.likes (comment_id)
+  WHERE comment_id IS NOT NULL;
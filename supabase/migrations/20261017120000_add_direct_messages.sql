@@ -0,0 +1,65 @@
+-- Direct messages: conversations (1:1 or group), their participants and messages.
+
+CREATE TABLE public.conversations (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  title TEXT,
+  is_group BOOLEAN DEFAULT FALSE NOT NULL,
+  -- "<lower user id>:<higher user id>" on 1:1 conversations, NULL on groups, so a
+  -- pair of users always shares a single thread.
+  direct_key TEXT UNIQUE,
+  created_by UUID REFERENCES public.profiles(id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+  last_message_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+CREATE TABLE public.conversation_participants (
+  conversation_id UUID REFERENCES public.conversations(id) ON DELETE CASCADE NOT NULL,
+  user_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  joined_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+  -- Read receipt: every message created at or before this has been read.
+  last_read_at TIMESTAMPTZ,
+  PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE TABLE public.messages (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  conversation_id UUID REFERENCES public.conversations(id) ON DELETE CASCADE NOT NULL,
+  sender_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  content TEXT NOT NULL CHECK (char_length(content) > 0),
+  created_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+-- A user's inbox, and message history pages within a conversation.
+CREATE INDEX IF NOT EXISTS conversation_participants_user_id_idx
+  ON public.conversation_participants (user_id);
+CREATE INDEX IF NOT EXISTS conversations_last_message_at_idx
+  ON public.conversations (last_message_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS messages_conversation_id_created_at_idx
+  ON public.messages (conversation_id, created_at DESC, id DESC);
+
+-- Participant check used by the policies below. SECURITY DEFINER so that the
+-- policy on conversation_participants does not recurse into itself.
+CREATE OR REPLACE FUNCTION public.is_conversation_participant(conversation UUID)
+RETURNS BOOLEAN
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = public
+AS $$
+  SELECT EXISTS (
+    SELECT 1 FROM public.conversation_participants
+    WHERE conversation_id = conversation AND user_id = auth.uid()
+  );
+$$;
+
+ALTER TABLE public.conversations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.conversation_participants ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.messages ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Participants can view their conversations." ON public.conversations FOR SELECT USING (public.is_conversation_participant(id));
+CREATE POLICY "Participants can view conversation members." ON public.conversation_participants FOR SELECT USING (public.is_conversation_participant(conversation_id));
+CREATE POLICY "Users can update their own read receipts." ON public.conversation_participants FOR UPDATE USING (auth.uid() = user_id);
+CREATE POLICY "Participants can view messages." ON public.messages FOR SELECT USING (public.is_conversation_participant(conversation_id));
+CREATE POLICY "Participants can send messages." ON public.messages FOR INSERT WITH CHECK (auth.uid() = sender_id AND public.is_conversation_participant(conversation_id));
+
+ALTER PUBLICATION supabase_realtime ADD TABLE public.messages;
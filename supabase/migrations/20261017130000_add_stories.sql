@@ -0,0 +1,43 @@
+-- Ephemeral stories. Reads filter on expires_at, so a story disappears as soon
+-- as it expires; expired rows are deleted later by StoryRepo.PurgeExpired.
+
+CREATE TABLE public.stories (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  user_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  media_url TEXT NOT NULL,
+  media_type TEXT DEFAULT 'image' NOT NULL CHECK (media_type IN ('image', 'video')),
+  caption TEXT,
+  created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT stories_expiry_after_creation CHECK (expires_at > created_at)
+);
+
+CREATE TABLE public.story_views (
+  story_id UUID REFERENCES public.stories(id) ON DELETE CASCADE NOT NULL,
+  viewer_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  viewed_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+  PRIMARY KEY (story_id, viewer_id)
+);
+
+-- Active stories per author for the feed, and expiry scans for the purge.
+CREATE INDEX IF NOT EXISTS stories_user_id_expires_at_idx
+  ON public.stories (user_id, expires_at);
+CREATE INDEX IF NOT EXISTS stories_expires_at_idx
+  ON public.stories (expires_at);
+CREATE INDEX IF NOT EXISTS story_views_story_id_viewed_at_idx
+  ON public.story_views (story_id, viewed_at DESC, viewer_id DESC);
+
+ALTER TABLE public.stories ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.story_views ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Active stories are viewable by everyone." ON public.stories FOR SELECT USING (expires_at > now());
+CREATE POLICY "Users can insert their own stories." ON public.stories FOR INSERT WITH CHECK (auth.uid() = user_id);
+CREATE POLICY "Users can delete their own stories." ON public.stories FOR DELETE USING (auth.uid() = user_id);
+
+CREATE POLICY "Authors and viewers can see story views." ON public.story_views FOR SELECT USING (
+  auth.uid() = viewer_id
+  OR auth.uid() = (SELECT user_id FROM public.stories WHERE id = story_id)
+);
+CREATE POLICY "Users can record their own story views." ON public.story_views FOR INSERT WITH CHECK (auth.uid() = viewer_id);
+
+ALTER PUBLICATION supabase_realtime ADD TABLE public.stories;
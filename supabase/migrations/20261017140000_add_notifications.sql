@@ -0,0 +1,46 @@
+-- Notifications for follows, likes, comments, replies and mentions. Events with
+-- the same group_key (e.g. every like on one post) fold into a single unread
+-- notification; notification_actors records who is behind it.
+
+CREATE TABLE public.notifications (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  recipient_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  type TEXT NOT NULL CHECK (type IN ('follow', 'like', 'comment', 'reply', 'mention')),
+  post_id UUID REFERENCES public.posts(id) ON DELETE CASCADE,
+  comment_id UUID REFERENCES public.comments(id) ON DELETE CASCADE,
+  group_key TEXT NOT NULL,
+  actor_count INTEGER DEFAULT 1 NOT NULL,
+  read_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+  updated_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+CREATE TABLE public.notification_actors (
+  notification_id UUID REFERENCES public.notifications(id) ON DELETE CASCADE NOT NULL,
+  actor_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+  PRIMARY KEY (notification_id, actor_id)
+);
+
+-- At most one unread notification per group; new events are merged into it.
+CREATE UNIQUE INDEX IF NOT EXISTS notifications_unread_group_key
+  ON public.notifications (recipient_id, group_key)
+  WHERE read_at IS NULL;
+
+-- The notification list, and the unread badge.
+CREATE INDEX IF NOT EXISTS notifications_recipient_id_updated_at_idx
+  ON public.notifications (recipient_id, updated_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS notifications_recipient_id_unread_idx
+  ON public.notifications (recipient_id)
+  WHERE read_at IS NULL;
+
+ALTER TABLE public.notifications ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.notification_actors ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can view their own notifications." ON public.notifications FOR SELECT USING (auth.uid() = recipient_id);
+CREATE POLICY "Users can update their own notifications." ON public.notifications FOR UPDATE USING (auth.uid() = recipient_id);
+CREATE POLICY "Users can view the actors of their own notifications." ON public.notification_actors FOR SELECT USING (
+  auth.uid() = (SELECT recipient_id FROM public.notifications WHERE id = notification_id)
+);
+
+ALTER PUBLICATION supabase_realtime ADD TABLE public.notifications;
@@ -0,0 +1,9 @@
+-- Full-text search over post content. The configuration ('english') must match
+-- store.TextSearchConfig, which builds the queries that use this index.
+
+ALTER TABLE public.posts
+  ADD COLUMN IF NOT EXISTS content_tsv tsvector
+  GENERATED ALWAYS AS (to_tsvector('english', coalesce(content, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS posts_content_tsv_idx
+  ON public.posts USING GIN (content_tsv);
@@ -0,0 +1,10 @@
+-- Trigram indexes for user search. They serve the ILIKE '%q%' substring matches
+-- and the % similarity operator used by ProfileRepo.Search.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS profiles_username_trgm_idx
+  ON public.profiles USING GIN (username gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS profiles_full_name_trgm_idx
+  ON public.profiles USING GIN (full_name gin_trgm_ops);
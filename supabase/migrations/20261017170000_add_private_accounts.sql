@@ -0,0 +1,41 @@
+-- Private accounts: following one takes a request the owner approves, and
+-- only approved followers (rows in follows) see its posts and follow lists.
+
+ALTER TABLE public.profiles
+  ADD COLUMN is_private BOOLEAN DEFAULT false NOT NULL;
+
+CREATE TABLE public.follow_requests (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  requester_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  target_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+  CONSTRAINT unique_follow_request UNIQUE (requester_id, target_id),
+  CONSTRAINT follow_request_not_self CHECK (requester_id <> target_id)
+);
+
+-- A user's pending requests, newest first.
+CREATE INDEX IF NOT EXISTS follow_requests_target_id_created_at_idx
+  ON public.follow_requests (target_id, created_at DESC, id DESC);
+
+ALTER TABLE public.follow_requests ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Requesters and targets can see follow requests." ON public.follow_requests FOR SELECT USING (auth.uid() IN (requester_id, target_id));
+CREATE POLICY "Users can request to follow." ON public.follow_requests FOR INSERT WITH CHECK (auth.uid() = requester_id);
+CREATE POLICY "Requesters and targets can delete follow requests." ON public.follow_requests FOR DELETE USING (auth.uid() IN (requester_id, target_id));
+
+-- Whether the caller may see owner's posts. SECURITY DEFINER so the check
+-- reads profiles and follows regardless of the caller's own policies.
+CREATE FUNCTION public.can_view_posts_of(owner UUID) RETURNS BOOLEAN
+LANGUAGE sql STABLE SECURITY DEFINER SET search_path = '' AS $$
+  SELECT owner = auth.uid()
+    OR NOT EXISTS (SELECT 1 FROM public.profiles WHERE id = owner AND is_private)
+    OR EXISTS (SELECT 1 FROM public.follows WHERE follower_id = auth.uid() AND following_id = owner);
+$$;
+
+DROP POLICY "Public posts are viewable by everyone." ON public.posts;
+CREATE POLICY "Posts are viewable unless the author is private." ON public.posts FOR SELECT USING (public.can_view_posts_of(user_id));
+
+-- Notify the owner of a private account about new requests.
+ALTER TABLE public.notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE public.notifications ADD CONSTRAINT notifications_type_check
+  CHECK (type IN ('follow', 'follow_request', 'like', 'comment', 'reply', 'mention'));
@@ -0,0 +1,52 @@
+-- Blocks and mutes. A block hides each user's posts from the other, removes
+-- any follows between them and stops them following each other again. A mute
+-- only hides the muted user's posts from the muter's timeline.
+
+CREATE TABLE public.blocks (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  blocker_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  blocked_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+  CONSTRAINT unique_block UNIQUE (blocker_id, blocked_id),
+  CONSTRAINT block_not_self CHECK (blocker_id <> blocked_id)
+);
+
+CREATE TABLE public.mutes (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  muter_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  muted_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+  CONSTRAINT unique_mute UNIQUE (muter_id, muted_id),
+  CONSTRAINT mute_not_self CHECK (muter_id <> muted_id)
+);
+
+-- The unique constraints serve lookups by blocker and muter; blocks are also
+-- checked from the blocked side.
+CREATE INDEX IF NOT EXISTS blocks_blocked_id_idx ON public.blocks (blocked_id);
+-- A user's block and mute lists, newest first.
+CREATE INDEX IF NOT EXISTS blocks_blocker_id_created_at_idx
+  ON public.blocks (blocker_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS mutes_muter_id_created_at_idx
+  ON public.mutes (muter_id, created_at DESC, id DESC);
+
+ALTER TABLE public.blocks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE public.mutes ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY "Users can see their own blocks." ON public.blocks FOR SELECT USING (auth.uid() = blocker_id);
+CREATE POLICY "Users can block." ON public.blocks FOR INSERT WITH CHECK (auth.uid() = blocker_id);
+CREATE POLICY "Users can unblock." ON public.blocks FOR DELETE USING (auth.uid() = blocker_id);
+
+CREATE POLICY "Users can see their own mutes." ON public.mutes FOR SELECT USING (auth.uid() = muter_id);
+CREATE POLICY "Users can mute." ON public.mutes FOR INSERT WITH CHECK (auth.uid() = muter_id);
+CREATE POLICY "Users can unmute." ON public.mutes FOR DELETE USING (auth.uid() = muter_id);
+
+-- Blocks hide posts in both directions.
+CREATE OR REPLACE FUNCTION public.can_view_posts_of(owner UUID) RETURNS BOOLEAN
+LANGUAGE sql STABLE SECURITY DEFINER SET search_path = '' AS $$
+  SELECT owner = auth.uid() OR (
+    NOT EXISTS (SELECT 1 FROM public.blocks
+                WHERE (blocker_id = owner AND blocked_id = auth.uid())
+                   OR (blocker_id = auth.uid() AND blocked_id = owner))
+    AND (NOT EXISTS (SELECT 1 FROM public.profiles WHERE id = owner AND is_private)
+         OR EXISTS (SELECT 1 FROM public.follows WHERE follower_id = auth.uid() AND following_id = owner)));
+$$;
@@ -0,0 +1,6 @@
+-- Follower and following lists are paged most recently followed first, keyed
+-- on (created_at, id). One index per direction serves each list in order.
+CREATE INDEX IF NOT EXISTS follows_following_id_created_at_idx
+  ON public.follows (following_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS follows_follower_id_created_at_idx
+  ON public.follows (follower_id, created_at DESC, id DESC);
@@ -0,0 +1,75 @@
+-- Denormalised follower, following and post counts on profiles, kept up to
+-- date by triggers so reading them costs nothing. If they ever drift,
+-- public.reconcile_profile_counters() recounts them (see cmd/reconcile).
+
+ALTER TABLE public.profiles
+  ADD COLUMN followers_count BIGINT DEFAULT 0 NOT NULL,
+  ADD COLUMN following_count BIGINT DEFAULT 0 NOT NULL,
+  ADD COLUMN posts_count BIGINT DEFAULT 0 NOT NULL;
+
+-- SECURITY DEFINER so the counters are updated on both profiles whatever the
+-- caller's own update policy allows.
+CREATE FUNCTION public.update_follow_counters() RETURNS TRIGGER
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = '' AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    UPDATE public.profiles SET followers_count = followers_count + 1 WHERE id = NEW.following_id;
+    UPDATE public.profiles SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+  ELSE
+    UPDATE public.profiles SET followers_count = GREATEST(followers_count - 1, 0) WHERE id = OLD.following_id;
+    UPDATE public.profiles SET following_count = GREATEST(following_count - 1, 0) WHERE id = OLD.follower_id;
+  END IF;
+  RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER follows_update_counters
+  AFTER INSERT OR DELETE ON public.follows
+  FOR EACH ROW EXECUTE FUNCTION public.update_follow_counters();
+
+CREATE FUNCTION public.update_post_counters() RETURNS TRIGGER
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = '' AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    UPDATE public.profiles SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+  ELSE
+    UPDATE public.profiles SET posts_count = GREATEST(posts_count - 1, 0) WHERE id = OLD.user_id;
+  END IF;
+  RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER posts_update_counters
+  AFTER INSERT OR DELETE ON public.posts
+  FOR EACH ROW EXECUTE FUNCTION public.update_post_counters();
+
+-- Recounts every profile's counters and returns how many profiles were wrong.
+CREATE FUNCTION public.reconcile_profile_counters() RETURNS INTEGER
+LANGUAGE plpgsql SECURITY DEFINER SET search_path = '' AS $$
+DECLARE
+  fixed INTEGER;
+BEGIN
+  WITH actual AS (
+    SELECT p.id,
+           (SELECT COUNT(*) FROM public.follows WHERE following_id = p.id) AS followers,
+           (SELECT COUNT(*) FROM public.follows WHERE follower_id = p.id) AS following,
+           (SELECT COUNT(*) FROM public.posts WHERE user_id = p.id) AS posts
+    FROM public.profiles p
+  )
+  UPDATE public.profiles p
+  SET followers_count = actual.followers,
+      following_count = actual.following,
+      posts_count = actual.posts
+  FROM actual
+  WHERE p.id = actual.id
+    AND (p.followers_count, p.following_count, p.posts_count)
+        IS DISTINCT FROM (actual.followers, actual.following, actual.posts);
+  GET DIAGNOSTICS fixed = ROW_COUNT;
+  RETURN fixed;
+END;
+$$;
+
+REVOKE EXECUTE ON FUNCTION public.reconcile_profile_counters() FROM PUBLIC;
+
+-- Backfill.
+SELECT public.reconcile_profile_counters();
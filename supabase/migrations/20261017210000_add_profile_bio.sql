@@ -0,0 +1,4 @@
+-- A short free-text bio shown on profiles. The API enforces the same limit
+-- with a friendlier error.
+ALTER TABLE public.profiles
+  ADD COLUMN bio TEXT CONSTRAINT profiles_bio_length CHECK (char_length(bio) <= 160);
@@ -0,0 +1,25 @@
+-- Usernames are unique regardless of case, and renames are recorded so that
+-- links to an old username can be redirected to the new one.
+--
+-- Creating the index fails if two profiles already share a username up to
+-- case; rename one of them first.
+CREATE UNIQUE INDEX IF NOT EXISTS profiles_username_lower_key
+  ON public.profiles (lower(username));
+
+CREATE TABLE public.username_history (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  user_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  old_username TEXT NOT NULL,
+  new_username TEXT NOT NULL,
+  changed_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+-- Redirect lookups by old username, and the rename cooldown by user.
+CREATE INDEX IF NOT EXISTS username_history_old_username_idx
+  ON public.username_history (lower(old_username), changed_at DESC);
+CREATE INDEX IF NOT EXISTS username_history_user_id_changed_at_idx
+  ON public.username_history (user_id, changed_at DESC);
+
+-- Only the API writes the history; old usernames were public anyway.
+ALTER TABLE public.username_history ENABLE ROW LEVEL SECURITY;
+CREATE POLICY "Username history is viewable by everyone." ON public.username_history FOR SELECT USING (true);
@@ -0,0 +1,11 @@
+-- Request counters for rate-limited endpoints, one row per key (an endpoint
+-- and a client IP, say) holding the count for its current window. Only the
+-- API reads and writes them.
+
+CREATE TABLE public.rate_limits (
+  key TEXT PRIMARY KEY,
+  window_start TIMESTAMPTZ DEFAULT now() NOT NULL,
+  count INTEGER DEFAULT 0 NOT NULL
+);
+
+ALTER TABLE public.rate_limits ENABLE ROW LEVEL SECURITY;
@@ -0,0 +1,34 @@
+-- Images and videos attached to posts. They are uploaded first, with post_id
+-- NULL, and attached when the post is created; a post holds at most four,
+-- ordered by position.
+
+CREATE TABLE public.media_attachments (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  user_id UUID REFERENCES public.profiles(id) ON DELETE CASCADE NOT NULL,
+  post_id UUID REFERENCES public.posts(id) ON DELETE CASCADE,
+  position SMALLINT DEFAULT 0 NOT NULL,
+  type TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  storage_key TEXT NOT NULL,
+  url TEXT NOT NULL,
+  thumbnail_key TEXT,
+  thumbnail_url TEXT,
+  width INTEGER NOT NULL,
+  height INTEGER NOT NULL,
+  alt_text TEXT DEFAULT '' NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT now() NOT NULL,
+  CONSTRAINT media_attachments_type CHECK (type IN ('image', 'video')),
+  CONSTRAINT media_attachments_position CHECK (position BETWEEN 0 AND 3),
+  CONSTRAINT media_attachments_alt_text_length CHECK (char_length(alt_text) <= 1000),
+  CONSTRAINT unique_attachment_position UNIQUE (post_id, position)
+);
+
+-- The unique constraint serves loading a post's attachments; this finds a
+-- user's uploads.
+CREATE INDEX IF NOT EXISTS media_attachments_user_id_idx ON public.media_attachments (user_id);
+
+-- Only the API writes attachments. Attached ones are as visible as their
+-- post; unattached uploads only to their owner.
+ALTER TABLE public.media_attachments ENABLE ROW LEVEL SECURITY;
+CREATE POLICY "Attachments are viewable with their post." ON public.media_attachments FOR SELECT
+  USING (CASE WHEN post_id IS NULL THEN auth.uid() = user_id ELSE public.can_view_posts_of(user_id) END);
@@ -0,0 +1,20 @@
+-- Link previews. A post records the normalised form of the first URL in its
+-- content in link_url, and the preview of that URL is cached once in
+-- link_previews, however many posts share it. Failed fetches are cached too
+-- (failed), so a broken link is not fetched again for every post.
+
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS link_url TEXT;
+
+CREATE TABLE public.link_previews (
+  url TEXT PRIMARY KEY,
+  title TEXT DEFAULT '' NOT NULL,
+  description TEXT DEFAULT '' NOT NULL,
+  image_url TEXT DEFAULT '' NOT NULL,
+  site_name TEXT DEFAULT '' NOT NULL,
+  failed BOOLEAN DEFAULT false NOT NULL,
+  fetched_at TIMESTAMPTZ DEFAULT now() NOT NULL
+);
+
+-- Only the API writes previews; they describe public pages.
+ALTER TABLE public.link_previews ENABLE ROW LEVEL SECURITY;
+CREATE POLICY "Link previews are viewable by everyone." ON public.link_previews FOR SELECT USING (true);
@@ -0,0 +1,4 @@
+-- Rate limit rows outlive their window until a request purges them; the
+-- purge looks them up by window_start.
+CREATE INDEX IF NOT EXISTS rate_limits_window_start_idx
+  ON public.rate_limits (window_start);
@@ -0,0 +1,5 @@
+-- Uploads that never make it into a post are purged once they are old
+-- enough; the purge looks them up by created_at.
+CREATE INDEX IF NOT EXISTS media_attachments_unattached_created_at_idx
+  ON public.media_attachments (created_at)
+  WHERE post_id IS NULL;
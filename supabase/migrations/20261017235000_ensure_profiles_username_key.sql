@@ -0,0 +1,14 @@
+-- supabase_schema.sql declares username UNIQUE, which creates
+-- profiles_username_key, so databases loaded from it adopt 20250802110318
+-- instead of running it. This adds the constraint wherever it is missing.
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_constraint
+    WHERE conrelid = 'public.profiles'::regclass AND conname = 'profiles_username_key'
+  ) THEN
+    ALTER TABLE public.profiles
+    ADD CONSTRAINT profiles_username_key UNIQUE (username);
+  END IF;
+END
+$$;
@@ -0,0 +1,6 @@
+-- Each upload purges only its uploader's stale unattached uploads, so look
+-- them up by user as well as by created_at.
+DROP INDEX IF EXISTS public.media_attachments_unattached_created_at_idx;
+CREATE INDEX IF NOT EXISTS media_attachments_unattached_user_created_at_idx
+  ON public.media_attachments (user_id, created_at)
+  WHERE post_id IS NULL;
@@ -0,0 +1,9 @@
+-- can_view_posts_of lets every row in follows see a private account's posts,
+-- so clients may only follow public accounts directly. Following a private
+-- account takes an approved follow request, and approvals go through the
+-- API, which connects as the table owner and is not bound by this policy.
+DROP POLICY "Users can insert their own follows." ON public.follows;
+CREATE POLICY "Users can follow public accounts." ON public.follows FOR INSERT WITH CHECK (
+  auth.uid() = follower_id
+  AND NOT EXISTS (SELECT 1 FROM public.profiles WHERE id = following_id AND is_private)
+);
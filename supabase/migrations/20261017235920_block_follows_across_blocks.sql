@@ -0,0 +1,17 @@
+-- Blocks prevent new follows in both directions. api/follow checks this, but
+-- a follow inserted directly would still let the blocked user see the
+-- blocker's posts through can_view_posts_of. Users only see their own rows
+-- in blocks, so the check goes through a SECURITY DEFINER function.
+CREATE FUNCTION public.is_blocked_between(a UUID, b UUID) RETURNS BOOLEAN
+LANGUAGE sql STABLE SECURITY DEFINER SET search_path = '' AS $$
+  SELECT EXISTS (SELECT 1 FROM public.blocks
+                 WHERE (blocker_id = a AND blocked_id = b)
+                    OR (blocker_id = b AND blocked_id = a));
+$$;
+
+DROP POLICY "Users can follow public accounts." ON public.follows;
+CREATE POLICY "Users can follow public accounts." ON public.follows FOR INSERT WITH CHECK (
+  auth.uid() = follower_id
+  AND NOT EXISTS (SELECT 1 FROM public.profiles WHERE id = following_id AND is_private)
+  AND NOT public.is_blocked_between(follower_id, following_id)
+);
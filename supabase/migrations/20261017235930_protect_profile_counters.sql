@@ -0,0 +1,18 @@
+-- The counters are only written by the SECURITY DEFINER triggers, but the
+-- profiles update policy lets users change any column of their own row.
+-- Revoking UPDATE on the counter columns alone does nothing while the table
+-- grant stands, so UPDATE is regranted on every other column instead. A
+-- column added later needs its own GRANT UPDATE for clients to edit it.
+DO $$
+DECLARE
+  writable TEXT;
+BEGIN
+  SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position) INTO writable
+  FROM information_schema.columns
+  WHERE table_schema = 'public' AND table_name = 'profiles'
+    AND column_name NOT IN ('followers_count', 'following_count', 'posts_count');
+
+  REVOKE UPDATE ON public.profiles FROM anon, authenticated;
+  EXECUTE format('GRANT UPDATE (%s) ON public.profiles TO anon, authenticated', writable);
+END
+$$;
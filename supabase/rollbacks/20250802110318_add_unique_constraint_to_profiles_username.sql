@@ -0,0 +1 @@
+ALTER TABLE public.profiles DROP CONSTRAINT IF EXISTS profiles_username_key;
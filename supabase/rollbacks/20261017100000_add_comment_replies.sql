@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS public.comments_parent_id_idx;
+DROP INDEX IF EXISTS public.comments_post_id_created_at_idx;
+-- Replies become top-level comments.
+ALTER TABLE public.comments DROP COLUMN IF EXISTS parent_id;
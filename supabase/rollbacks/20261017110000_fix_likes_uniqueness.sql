@@ -0,0 +1,6 @@
+-- Duplicates removed by the migration are not restored.
+DROP INDEX IF EXISTS public.likes_comment_id_idx;
+DROP INDEX IF EXISTS public.likes_post_id_idx;
+ALTER TABLE public.likes DROP CONSTRAINT IF EXISTS likes_single_target;
+DROP INDEX IF EXISTS public.likes_user_comment_key;
+DROP INDEX IF EXISTS public.likes_user_post_key;
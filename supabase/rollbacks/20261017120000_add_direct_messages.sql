@@ -0,0 +1,5 @@
+ALTER PUBLICATION supabase_realtime DROP TABLE public.messages;
+DROP TABLE IF EXISTS public.messages;
+DROP TABLE IF EXISTS public.conversation_participants;
+DROP TABLE IF EXISTS public.conversations;
+DROP FUNCTION IF EXISTS public.is_conversation_participant(UUID);
@@ -0,0 +1,3 @@
+ALTER PUBLICATION supabase_realtime DROP TABLE public.stories;
+DROP TABLE IF EXISTS public.story_views;
+DROP TABLE IF EXISTS public.stories;
@@ -0,0 +1,3 @@
+ALTER PUBLICATION supabase_realtime DROP TABLE public.notifications;
+DROP TABLE IF EXISTS public.notification_actors;
+DROP TABLE IF EXISTS public.notifications;
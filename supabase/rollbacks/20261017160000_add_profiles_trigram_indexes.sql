@@ -0,0 +1,3 @@
+-- pg_trgm is left installed; other objects may use it.
+DROP INDEX IF EXISTS public.profiles_full_name_trgm_idx;
+DROP INDEX IF EXISTS public.profiles_username_trgm_idx;
@@ -0,0 +1,9 @@
+DELETE FROM public.notifications WHERE type = 'follow_request';
+ALTER TABLE public.notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE public.notifications ADD CONSTRAINT notifications_type_check
+  CHECK (type IN ('follow', 'like', 'comment', 'reply', 'mention'));
+DROP POLICY IF EXISTS "Posts are viewable unless the author is private." ON public.posts;
+CREATE POLICY "Public posts are viewable by everyone." ON public.posts FOR SELECT USING (TRUE);
+DROP FUNCTION IF EXISTS public.can_view_posts_of(UUID);
+DROP TABLE IF EXISTS public.follow_requests;
+ALTER TABLE public.profiles DROP COLUMN IF EXISTS is_private;
@@ -0,0 +1,8 @@
+CREATE OR REPLACE FUNCTION public.can_view_posts_of(owner UUID) RETURNS BOOLEAN
+LANGUAGE sql STABLE SECURITY DEFINER SET search_path = '' AS $$
+  SELECT owner = auth.uid()
+    OR NOT EXISTS (SELECT 1 FROM public.profiles WHERE id = owner AND is_private)
+    OR EXISTS (SELECT 1 FROM public.follows WHERE follower_id = auth.uid() AND following_id = owner);
+$$;
+DROP TABLE IF EXISTS public.mutes;
+DROP TABLE IF EXISTS public.blocks;
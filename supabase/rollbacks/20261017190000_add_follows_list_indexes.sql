@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.follows_follower_id_created_at_idx;
+DROP INDEX IF EXISTS public.follows_following_id_created_at_idx;
@@ -0,0 +1,9 @@
+DROP FUNCTION IF EXISTS public.reconcile_profile_counters();
+DROP TRIGGER IF EXISTS posts_update_counters ON public.posts;
+DROP FUNCTION IF EXISTS public.update_post_counters();
+DROP TRIGGER IF EXISTS follows_update_counters ON public.follows;
+DROP FUNCTION IF EXISTS public.update_follow_counters();
+ALTER TABLE public.profiles
+  DROP COLUMN IF EXISTS posts_count,
+  DROP COLUMN IF EXISTS following_count,
+  DROP COLUMN IF EXISTS followers_count;
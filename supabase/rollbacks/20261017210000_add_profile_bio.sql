@@ -0,0 +1 @@
+ALTER TABLE public.profiles DROP COLUMN IF EXISTS bio;
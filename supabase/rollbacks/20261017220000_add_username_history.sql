@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.username_history;
+DROP INDEX IF EXISTS public.profiles_username_lower_key;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.link_previews;
+ALTER TABLE public.posts DROP COLUMN IF EXISTS link_url;
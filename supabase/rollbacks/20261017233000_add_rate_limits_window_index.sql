@@ -0,0 +1 @@
+DROP INDEX IF EXISTS public.rate_limits_window_start_idx;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS public.media_attachments_unattached_created_at_idx;
@@ -0,0 +1,2 @@
+-- Nothing to undo: profiles_username_key belongs to supabase_schema.sql and
+-- 20250802110318, whose rollback drops it.
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS public.media_attachments_unattached_user_created_at_idx;
+CREATE INDEX IF NOT EXISTS media_attachments_unattached_created_at_idx
+  ON public.media_attachments (created_at)
+  WHERE post_id IS NULL;
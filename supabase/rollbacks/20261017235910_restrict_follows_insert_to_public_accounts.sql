@@ -0,0 +1,2 @@
+DROP POLICY IF EXISTS "Users can follow public accounts." ON public.follows;
+CREATE POLICY "Users can insert their own follows." ON public.follows FOR INSERT WITH CHECK (auth.uid() = follower_id);
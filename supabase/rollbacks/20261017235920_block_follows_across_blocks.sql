@@ -0,0 +1,6 @@
+DROP POLICY IF EXISTS "Users can follow public accounts." ON public.follows;
+CREATE POLICY "Users can follow public accounts." ON public.follows FOR INSERT WITH CHECK (
+  auth.uid() = follower_id
+  AND NOT EXISTS (SELECT 1 FROM public.profiles WHERE id = following_id AND is_private)
+);
+DROP FUNCTION IF EXISTS public.is_blocked_between(UUID, UUID);
@@ -0,0 +1,13 @@
+DO $$
+DECLARE
+  writable TEXT;
+BEGIN
+  SELECT string_agg(quote_ident(column_name), ', ' ORDER BY ordinal_position) INTO writable
+  FROM information_schema.columns
+  WHERE table_schema = 'public' AND table_name = 'profiles'
+    AND column_name NOT IN ('followers_count', 'following_count', 'posts_count');
+
+  EXECUTE format('REVOKE UPDATE (%s) ON public.profiles FROM anon, authenticated', writable);
+  GRANT UPDATE ON public.profiles TO anon, authenticated;
+END
+$$;